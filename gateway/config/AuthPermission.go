@@ -12,6 +12,23 @@ var (
 
 type AuthPermitAll struct {
 	PermitALL []interface{}
+	// 访问各服务的令牌必须具备的权限范围，key 为服务名
+	ServiceScope map[string]string
+	// 各服务在认证服务注册的客户端 ID，key 为服务名
+	ServiceAudience map[string]string
+}
+
+// RequiredScope 返回访问服务所需的权限范围，未配置时返回空字符串
+func RequiredScope(serviceName string) string {
+	return AuthPermitConfig.ServiceScope[serviceName]
+}
+
+// Audience 返回服务作为令牌受众时的客户端 ID，未配置时与服务名相同
+func Audience(serviceName string) string {
+	if audience, ok := AuthPermitConfig.ServiceAudience[serviceName]; ok {
+		return audience
+	}
+	return serviceName
 }
 
 func Match(str string) bool {
@@ -37,7 +37,7 @@ func Router(zipTracer *zipkin.Tracer, fbMsg string, logger log.Logger) http.Hand
 	}
 }
 
-func preFilter(r *http.Request) bool {
+func preFilter(r *http.Request, serviceName string) bool {
 	reqPath := r.URL.Path
 	if reqPath == "" {
 		return false
@@ -51,13 +51,32 @@ func preFilter(r *http.Request) bool {
 		return false
 	}
 	oathClient, _ := client.NewOAuthClient("oauth", nil, nil)
+	audience := config.Audience(serviceName)
 	resp, remoteErr := oathClient.CheckToken(context.Background(), nil, &pb.CheckTokenRequest{
-		Token: authToken,
+		Token:    authToken,
+		Audience: audience,
 	})
-	if remoteErr != nil || resp == nil {
+	if remoteErr != nil || resp == nil || !resp.IsValidToken {
 		return false
 	}
-	return true
+	// 令牌交换签发的令牌只能访问其受众服务
+	if resp.Audience != "" && resp.Audience != audience {
+		return false
+	}
+	return hasScope(resp.Scope, config.RequiredScope(serviceName))
+}
+
+// hasScope 校验令牌是否具备访问服务所需的权限范围
+func hasScope(scopes []string, requiredScope string) bool {
+	if requiredScope == "" {
+		return true
+	}
+	for _, scope := range scopes {
+		if scope == requiredScope {
+			return true
+		}
+	}
+	return false
 }
 
 func (router HystrixRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -69,14 +88,14 @@ func (router HystrixRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var err error
-	if reqPath == "" || !preFilter(r) {
+	//按照分隔符'/'对路径进行分解，获取服务名称serviceName
+	pathArray := strings.Split(reqPath, "/")
+	if len(pathArray) < 2 || !preFilter(r, pathArray[1]) {
 		err = errors.New("illegal request!")
 		w.WriteHeader(403)
 		w.Write([]byte(err.Error()))
 		return
 	}
-	//按照分隔符'/'对路径进行分解，获取服务名称serviceName
-	pathArray := strings.Split(reqPath, "/")
 	serviceName := pathArray[1]
 
 	if _, ok := router.svcMap.Load(serviceName); !ok {
@@ -14,6 +14,48 @@ const kConfigType = "CONFIG_TYPE"
 
 var ZipkinTracer *zipkin.Tracer
 var Logger log.Logger
+var JwtConfig JwtConf
+var LoginConfig LoginConf
+var RateLimitConfig RateLimitConf
+
+// JWT 签名配置，未配置非对称密钥时使用 Secret 做 HS256 签名，Secret 没有默认值
+type JwtConf struct {
+	Secret    string
+	ActiveKid string
+	Keys      []JwtKeyConf
+	// 对外的服务地址，必须是绝对 URL，discovery 元数据中的端点地址以此为前缀
+	Issuer string
+	// 迁移期间是否接受旧格式的令牌
+	LegacyClaims bool
+}
+
+type JwtKeyConf struct {
+	Kid  string
+	Alg  string
+	Path string
+}
+
+// 限流配置，Endpoints 的 key 为端点名，Clients 的 key 为 client_id，客户端的配置优先
+type RateLimitConf struct {
+	Default   RateLimitRule
+	Endpoints map[string]RateLimitRule
+	Clients   map[string]RateLimitRule
+}
+
+// 每秒请求数与突发容量
+type RateLimitRule struct {
+	Rate  float64
+	Burst int
+}
+
+// 密码登录失败的锁定配置，时间单位为秒
+type LoginConf struct {
+	MaxUserFailures int
+	MaxIpFailures   int
+	BaseLockout     int
+	MaxLockout      int
+	FailureWindow   int
+}
 
 func init() {
 	Logger = log.NewLogfmtLogger(os.Stderr)
@@ -28,6 +70,21 @@ func init() {
 	if err := conf.Sub("mysql", &conf.MysqlConfig); err != nil {
 		Logger.Log("Fail to parse mysql", err)
 	}
+	if err := conf.Sub("redis", &conf.Redis); err != nil {
+		Logger.Log("Fail to parse redis", err)
+	}
+	if err := conf.Sub("jwt", &JwtConfig); err != nil {
+		Logger.Log("Fail to parse jwt", err)
+	}
+	if err := conf.Sub("login", &LoginConfig); err != nil {
+		Logger.Log("Fail to parse login", err)
+	}
+	if err := conf.Sub("ratelimit", &RateLimitConfig); err != nil {
+		Logger.Log("Fail to parse ratelimit", err)
+	}
+	if err := conf.Sub("proxy", &conf.Proxy); err != nil {
+		Logger.Log("Fail to parse proxy", err)
+	}
 	if err := conf.Sub("trace", &conf.TraceConfig); err != nil {
 		Logger.Log("Fail to parse trace", err)
 	}
@@ -38,6 +95,21 @@ func init() {
 
 func initDefault() {
 	viper.SetDefault(kConfigType, "yaml")
+	// 网关的校验令牌请求量远大于登录请求，默认分别限流
+	RateLimitConfig = RateLimitConf{
+		Default: RateLimitRule{Rate: 100, Burst: 100},
+		Endpoints: map[string]RateLimitRule{
+			"check-token":      {Rate: 1000, Burst: 1000},
+			"grpc-check-token": {Rate: 1000, Burst: 1000},
+		},
+	}
+	LoginConfig = LoginConf{
+		MaxUserFailures: 5,
+		MaxIpFailures:   50,
+		BaseLockout:     60,
+		MaxLockout:      3600,
+		FailureWindow:   900,
+	}
 }
 
 func initTracer(zipkinURL string) {
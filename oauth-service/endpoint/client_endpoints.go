@@ -0,0 +1,131 @@
+package endpoint
+
+import (
+	"SecondKill/oauth-service/model"
+	"SecondKill/oauth-service/service"
+	"context"
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ClientRequest 注册或修改客户端时提交的信息
+type ClientRequest struct {
+	ClientId                    string   `json:"client_id"`
+	ClientSecret                string   `json:"client_secret,omitempty"`
+	AccessTokenValiditySeconds  int      `json:"access_token_validity_seconds"`
+	RefreshTokenValiditySeconds int      `json:"refresh_token_validity_seconds"`
+	RegisteredRedirectUri       string   `json:"registered_redirect_uri"`
+	AuthorizedGrantTypes        []string `json:"authorized_grant_types"`
+	Scope                       []string `json:"scope"`
+	Public                      bool     `json:"public"`
+}
+
+func (req *ClientRequest) clientDetails() *model.ClientDetails {
+	return &model.ClientDetails{
+		ClientId:                    req.ClientId,
+		ClientSecret:                req.ClientSecret,
+		AccessTokenValiditySeconds:  req.AccessTokenValiditySeconds,
+		RefreshTokenValiditySeconds: req.RefreshTokenValiditySeconds,
+		RegisteredRedirectUri:       req.RegisteredRedirectUri,
+		AuthorizedGrantTypes:        req.AuthorizedGrantTypes,
+		Scope:                       req.Scope,
+		Public:                      req.Public,
+	}
+}
+
+// ClientResponse 管理接口返回的客户端信息，不包含密钥哈希
+type ClientResponse struct {
+	ClientId string `json:"client_id"`
+	// 只在注册时返回一次密钥明文
+	ClientSecret                string   `json:"client_secret,omitempty"`
+	AccessTokenValiditySeconds  int      `json:"access_token_validity_seconds"`
+	RefreshTokenValiditySeconds int      `json:"refresh_token_validity_seconds"`
+	RegisteredRedirectUri       string   `json:"registered_redirect_uri"`
+	AuthorizedGrantTypes        []string `json:"authorized_grant_types"`
+	Scope                       []string `json:"scope"`
+	Public                      bool     `json:"public"`
+	Disabled                    bool     `json:"disabled"`
+}
+
+func NewClientResponse(clientDetails *model.ClientDetails) ClientResponse {
+	return ClientResponse{
+		ClientId:                    clientDetails.ClientId,
+		AccessTokenValiditySeconds:  clientDetails.AccessTokenValiditySeconds,
+		RefreshTokenValiditySeconds: clientDetails.RefreshTokenValiditySeconds,
+		RegisteredRedirectUri:       clientDetails.RegisteredRedirectUri,
+		AuthorizedGrantTypes:        clientDetails.AuthorizedGrantTypes,
+		Scope:                       clientDetails.Scope,
+		Public:                      clientDetails.Public,
+		Disabled:                    clientDetails.Disabled,
+	}
+}
+
+type DisableClientRequest struct {
+	ClientId string
+}
+
+type DisableClientResponse struct {
+	Success bool `json:"success"`
+}
+
+type ListClientsRequest struct {
+	Offset int
+	Limit  int
+}
+
+type ListClientsResponse struct {
+	Clients []ClientResponse `json:"clients"`
+}
+
+func MakeCreateClientEndpoint(clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*ClientRequest)
+		clientDetails := req.clientDetails()
+		clientSecret, err := clientService.CreateClientDetails(ctx, clientDetails)
+		if err != nil {
+			return nil, err
+		}
+		resp := NewClientResponse(clientDetails)
+		resp.ClientSecret = clientSecret
+		return resp, nil
+	}
+}
+
+func MakeUpdateClientEndpoint(clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*ClientRequest)
+		clientDetails := req.clientDetails()
+		if err = clientService.UpdateClientDetails(ctx, clientDetails); err != nil {
+			return nil, err
+		}
+		return NewClientResponse(clientDetails), nil
+	}
+}
+
+// MakeDisableClientEndpoint 停用客户端并吊销其已签发的令牌，吊销失败时可重复调用
+func MakeDisableClientEndpoint(clientService service.ClientDetailsService, tokenService service.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*DisableClientRequest)
+		if err = clientService.DisableClientDetails(ctx, req.ClientId); err != nil {
+			return nil, err
+		}
+		if err = tokenService.RevokeClientTokens(req.ClientId); err != nil {
+			return nil, err
+		}
+		return DisableClientResponse{Success: true}, nil
+	}
+}
+
+func MakeListClientsEndpoint(clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*ListClientsRequest)
+		clientDetailsList, err := clientService.ListClientDetails(ctx, req.Offset, req.Limit)
+		if err != nil {
+			return nil, err
+		}
+		clients := make([]ClientResponse, 0, len(clientDetailsList))
+		for _, clientDetails := range clientDetailsList {
+			clients = append(clients, NewClientResponse(clientDetails))
+		}
+		return ListClientsResponse{Clients: clients}, nil
+	}
+}
@@ -0,0 +1,123 @@
+package endpoint
+
+import (
+	"SecondKill/oauth-service/model"
+	"SecondKill/oauth-service/service"
+	"context"
+	"github.com/go-kit/kit/endpoint"
+	"net/url"
+	"time"
+)
+
+type DeviceAuthorizationRequest struct {
+	Scope string
+	// 用于生成验证页地址
+	OpenIdConfigRequest
+}
+
+// DeviceAuthorizationResponse RFC 8628 3.2 节定义的响应
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// MakeDeviceAuthorizationEndpoint 为没有输入设备的终端签发设备码和用户码
+func MakeDeviceAuthorizationEndpoint(deviceService *service.DeviceAuthorizationService, tokenEnhancer service.TokenEnhancer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*DeviceAuthorizationRequest)
+		client := ctx.Value(OAuth2ClientDetailsKey).(*model.ClientDetails)
+		if !client.IsGrantTypeAuthorized(service.DeviceCodeGrantType) {
+			return nil, service.ErrUnauthorizedClient
+		}
+		scope, err := service.ResolveScope(req.Scope, client.Scope)
+		if err != nil {
+			return nil, err
+		}
+		code, err := deviceService.CreateDeviceCode(client, scope)
+		if err != nil {
+			return nil, err
+		}
+		_, baseUrl := resolveIssuer(tokenEnhancer)
+		verificationUri := req.endpointUrl(baseUrl, DeviceVerificationRoute)
+		userCode := service.FormatUserCode(code.UserCode)
+		return DeviceAuthorizationResponse{
+			DeviceCode:              code.DeviceCode,
+			UserCode:                userCode,
+			VerificationUri:         verificationUri,
+			VerificationUriComplete: appendQuery(verificationUri, url.Values{"user_code": {userCode}}),
+			ExpiresIn:               int64(time.Until(*code.ExpriesTime).Seconds()),
+			Interval:                code.Interval,
+		}, nil
+	}
+}
+
+type DeviceVerificationRequest struct {
+	UserCode string
+	Username string
+	Password string
+	// approve 或 deny
+	Action string
+	// GET 请求展示验证页，POST 请求提交用户的授权结果
+	Submit bool
+	// 提交的表单携带了与 cookie 一致的 CSRF 令牌
+	CsrfVerified bool
+}
+
+type DeviceVerificationResponse struct {
+	Request *DeviceVerificationRequest
+	// 用户码有效时展示申请授权的客户端和权限范围
+	ClientId string
+	Scope    []string
+	// 授权完成后展示的提示，为空时展示验证页
+	Message string
+	Error   string
+	// 由 transport 层在展示验证页时填写
+	CsrfToken string
+}
+
+// MakeDeviceVerificationEndpoint 用户在手机或电脑上输入设备展示的用户码，登录后批准或拒绝设备的授权请求
+func MakeDeviceVerificationEndpoint(deviceService *service.DeviceAuthorizationService, userDetailsService service.UserDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*DeviceVerificationRequest)
+		resp := DeviceVerificationResponse{Request: req}
+		if req.UserCode == "" {
+			return resp, nil
+		}
+		code, err := deviceService.ReadPendingUserCode(req.UserCode)
+		if err != nil {
+			resp.Error = service.ErrInvalidUserCode.Error()
+			return resp, nil
+		}
+		resp.ClientId = code.ClientId
+		resp.Scope = code.Scope
+		if !req.Submit {
+			return resp, nil
+		}
+		if !req.CsrfVerified {
+			resp.Error = "页面已过期，请刷新后重新提交"
+			return resp, nil
+		}
+		// 批准和拒绝都需要登录，避免他人拿到用户码后替用户拒绝
+		userDetails, err := userDetailsService.GetUserDetailByUserName(ctx, req.Username, req.Password)
+		if err != nil {
+			resp.Error = loginError(err)
+			return resp, nil
+		}
+		if req.Action == "deny" {
+			if err = deviceService.Deny(req.UserCode); err != nil {
+				resp.Error = service.ErrInvalidUserCode.Error()
+				return resp, nil
+			}
+			return DeviceVerificationResponse{Message: "已拒绝设备的授权请求"}, nil
+		}
+		if err = deviceService.Approve(req.UserCode, userDetails); err != nil {
+			resp.Error = service.ErrInvalidUserCode.Error()
+			return resp, nil
+		}
+		return DeviceVerificationResponse{Message: "授权成功，请返回设备继续操作"}, nil
+	}
+}
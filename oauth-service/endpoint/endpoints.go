@@ -4,42 +4,112 @@ import (
 	"SecondKill/oauth-service/model"
 	"SecondKill/oauth-service/service"
 	"context"
-	"errors"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	OAuth2DetailsKey       = "OAuth2Details"
-	OAuth2ClientDetailsKey = "OAuth2ClientDetails"
-	OAuth2ErrorKey         = "OAuth2Error"
+	OAuth2DetailsKey           = "OAuth2Details"
+	OAuth2ClientDetailsKey     = "OAuth2ClientDetails"
+	OAuth2ClientCredentialsKey = "OAuth2ClientCredentials"
+	OAuth2AccessTokenKey       = "OAuth2AccessToken"
 )
 
 var (
-	ErrInvalidClientRequest = errors.New("invalid client message")
-	ErrInvalidUserRequest   = errors.New("invalid user message")
-	ErrNotPermit            = errors.New("not permit")
+	ErrInvalidClientRequest = service.NewOAuth2Error(service.ErrorCodeInvalidClient, "client authentication failed")
 )
 
 // CalculateEndpoint define endpoint
 type OAuth2Endpoints struct {
-	TokenEndpoint          endpoint.Endpoint
-	CheckTokenEndpoint     endpoint.Endpoint
-	GRPCCheckTokenEndpoint endpoint.Endpoint
-	HealthCheckEndpoint    endpoint.Endpoint
+	TokenEndpoint               endpoint.Endpoint
+	AuthorizeEndpoint           endpoint.Endpoint
+	JwksEndpoint                endpoint.Endpoint
+	RevokeTokenEndpoint         endpoint.Endpoint
+	IntrospectEndpoint          endpoint.Endpoint
+	UserInfoEndpoint            endpoint.Endpoint
+	OpenIdConfigEndpoint        endpoint.Endpoint
+	ServerMetadataEndpoint      endpoint.Endpoint
+	RotateSecretEndpoint        endpoint.Endpoint
+	CreateClientEndpoint        endpoint.Endpoint
+	UpdateClientEndpoint        endpoint.Endpoint
+	DisableClientEndpoint       endpoint.Endpoint
+	ListClientsEndpoint         endpoint.Endpoint
+	UnlockLoginEndpoint         endpoint.Endpoint
+	RevokeUserTokensEndpoint    endpoint.Endpoint
+	DeviceAuthorizationEndpoint endpoint.Endpoint
+	DeviceVerificationEndpoint  endpoint.Endpoint
+	CheckTokenEndpoint          endpoint.Endpoint
+	GRPCCheckTokenEndpoint      endpoint.Endpoint
+	HealthCheckEndpoint         endpoint.Endpoint
 }
 
-func MakeClientAuthorizationMiddleware(logger log.Logger) endpoint.Middleware {
+// ClientCredentials transport 层从请求中读取的客户端凭证，尚未校验
+type ClientCredentials struct {
+	ClientId     string
+	ClientSecret string
+	// 公开客户端只携带 client_id，没有密钥
+	Public bool
+}
+
+// MakeClientAuthorizationMiddleware 校验 transport 层放入上下文的客户端凭证，
+// 放在限流中间件之内执行，被限流的请求不会计算密钥哈希
+func MakeClientAuthorizationMiddleware(clientService service.ClientDetailsService, logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-			if err, ok := ctx.Value(OAuth2ErrorKey).(error); ok {
-				return nil, err
-			}
-			if _, ok := ctx.Value(OAuth2ClientDetailsKey).(*model.ClientDetails); !ok {
+			credentials, ok := ctx.Value(OAuth2ClientCredentialsKey).(*ClientCredentials)
+			if !ok {
 				return nil, ErrInvalidClientRequest
 			}
-			return next(ctx, request)
+			var clientDetails *model.ClientDetails
+			if credentials.Public {
+				// 公开客户端没有密钥，由 PKCE 保证授权码只能被发起授权的客户端兑换
+				if clientDetails, err = clientService.GetClientDetailById(ctx, credentials.ClientId); err == nil && !clientDetails.Public {
+					err = ErrInvalidClientRequest
+				}
+			} else {
+				clientDetails, err = clientService.GetClientDetailByClientId(ctx, credentials.ClientId, credentials.ClientSecret)
+			}
+			if err != nil {
+				return nil, clientAuthorizationError(err)
+			}
+			return next(context.WithValue(ctx, OAuth2ClientDetailsKey, clientDetails), request)
+		}
+	}
+}
+
+// clientAuthorizationError 客户端不存在、密钥错误或已停用统一返回 invalid_client，避免暴露客户端是否存在
+func clientAuthorizationError(err error) error {
+	if _, ok := err.(*service.OAuth2Error); ok || err == model.ErrClientNotFound {
+		return ErrInvalidClientRequest
+	}
+	return err
+}
+
+// MakeAdminAuthorizationMiddleware 后台接口要求请求携带具备 admin 范围的访问令牌，
+// admin 范围来自客户端注册，代表用户的令牌还要求用户具备管理员权限
+func MakeAdminAuthorizationMiddleware(tokenService service.TokenService, logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			tokenValue, ok := ctx.Value(OAuth2AccessTokenKey).(string)
+			if !ok || tokenValue == "" {
+				return nil, service.ErrInvalidAccessToken
+			}
+			oauth2Details, err := tokenService.GetOAuth2DetailsByAccessToken(tokenValue)
+			if err != nil {
+				return nil, service.ErrInvalidAccessToken
+			}
+			if !oauth2Details.HasScope(service.AdminScope) ||
+				(oauth2Details.User != nil && !oauth2Details.User.HasAuthority(service.AdminAuthority)) {
+				logger.Log("admin access denied", oauth2Details.Client.ClientId)
+				return nil, service.ErrAdminRequired
+			}
+			return next(context.WithValue(ctx, OAuth2DetailsKey, oauth2Details), request)
 		}
 	}
 }
@@ -51,27 +121,152 @@ type TokenRequest struct {
 
 type TokenResponse struct {
 	AccessToken *model.OAuth2Token `json:"access_token"`
-	Error       string             `json:"error"`
+	// RFC 8693 2.2.1 节要求令牌交换的响应返回签发的令牌类型
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
 }
 
 func MakeTokenEndPoint(svc service.TokenGranter, clientService service.ClientDetailsService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(*TokenRequest)
 		token, err := svc.Grant(ctx, req.GrantType, ctx.Value(OAuth2ClientDetailsKey).(*model.ClientDetails), req.Reader)
-		var errString = ""
+		// 错误交给 transport 按 RFC 6749 5.2 节的格式返回
 		if err != nil {
-			errString = err.Error()
+			return nil, err
 		}
-		return TokenResponse{
+		resp := TokenResponse{
 			AccessToken: token,
-			Error:       errString,
+		}
+		if req.GrantType == service.TokenExchangeGrantType {
+			resp.IssuedTokenType = service.AccessTokenType
+		}
+		return resp, nil
+	}
+}
+
+type AuthorizeRequest struct {
+	ResponseType string
+	ClientId     string
+	RedirectUri  string
+	State        string
+	Scope        string
+	Nonce        string
+	// PKCE 参数
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Username            string
+	Password            string
+	// GET 请求展示登录页，POST 请求提交用户凭证
+	Submit bool
+	// 提交的表单携带了与 cookie 一致的 CSRF 令牌
+	CsrfVerified bool
+}
+
+type AuthorizeResponse struct {
+	// 授权完成后跳转的地址，携带授权码或错误信息
+	RedirectUri string
+	// 需要用户登录时展示登录页
+	ShowLogin bool
+	Request   *AuthorizeRequest
+	Error     string
+	// 由 transport 层在展示登录页时填写
+	CsrfToken string
+}
+
+// MakeAuthorizeEndpoint 授权码模式的授权端点，用户在此登录后为客户端签发一次性授权码
+func MakeAuthorizeEndpoint(clientService service.ClientDetailsService, userDetailsService service.UserDetailsService, codeService service.AuthorizationCodeService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*AuthorizeRequest)
+		clientDetails, err := clientService.GetClientDetailById(ctx, req.ClientId)
+		if err != nil {
+			return AuthorizeResponse{Error: service.ErrClientMessage.Error()}, nil
+		}
+		// 客户端和重定向地址校验失败时不能跳转，避免开放重定向
+		redirectUri, err := service.ResolveRedirectUri(clientDetails, req.RedirectUri)
+		if err != nil {
+			return AuthorizeResponse{Error: err.Error()}, nil
+		}
+		if req.ResponseType != "code" {
+			return AuthorizeResponse{
+				RedirectUri: appendQuery(redirectUri, url.Values{"error": {service.ErrorCodeUnsupportedResponseType}, "state": {req.State}}),
+			}, nil
+		}
+		if !clientDetails.IsGrantTypeAuthorized("authorization_code") {
+			return AuthorizeResponse{
+				RedirectUri: appendQuery(redirectUri, url.Values{"error": {service.ErrorCodeUnauthorizedClient}, "state": {req.State}}),
+			}, nil
+		}
+		scope, err := service.ResolveScope(req.Scope, clientDetails.Scope)
+		if err != nil {
+			return AuthorizeResponse{
+				RedirectUri: appendQuery(redirectUri, url.Values{"error": {service.ErrorCodeInvalidScope}, "state": {req.State}}),
+			}, nil
+		}
+		if err = service.ValidateCodeChallenge(clientDetails, req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+			return AuthorizeResponse{
+				RedirectUri: appendQuery(redirectUri, url.Values{"error": {service.ErrorCodeInvalidRequest}, "error_description": {err.Error()}, "state": {req.State}}),
+			}, nil
+		}
+		if !req.Submit {
+			return AuthorizeResponse{ShowLogin: true, Request: req}, nil
+		}
+		// 先校验 CSRF 令牌再认证，跨站提交的表单不会消耗登录失败次数，也不能替用户完成授权
+		if !req.CsrfVerified {
+			return AuthorizeResponse{ShowLogin: true, Request: req, Error: "页面已过期，请刷新后重新提交"}, nil
+		}
+		userDetails, err := userDetailsService.GetUserDetailByUserName(ctx, req.Username, req.Password)
+		if err != nil {
+			return AuthorizeResponse{ShowLogin: true, Request: req, Error: loginError(err)}, nil
+		}
+		code, err := codeService.CreateAuthorizationCode(&model.AuthorizationCode{
+			ClientId:    clientDetails.ClientId,
+			RedirectUri: req.RedirectUri,
+			User:        userDetails,
+			Scope:       scope,
+			Nonce:       req.Nonce,
+			// 授权码绑定 code_challenge，只有持有 code_verifier 的客户端才能兑换
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+		})
+		if err != nil {
+			return AuthorizeResponse{
+				RedirectUri: appendQuery(redirectUri, url.Values{"error": {service.ErrorCodeServerError}, "state": {req.State}}),
+			}, nil
+		}
+		return AuthorizeResponse{
+			RedirectUri: appendQuery(redirectUri, url.Values{"code": {code.Code}, "state": {req.State}}),
 		}, nil
 	}
 }
 
+// loginError 登录页展示的错误，账号锁定时提示稍后重试，其余错误不区分用户名不存在和密码错误
+func loginError(err error) string {
+	if err == service.ErrLoginLocked {
+		return err.Error()
+	}
+	return service.ErrInvalidUsernameAndPasswordRequest.Error()
+}
+
+func appendQuery(redirectUri string, values url.Values) string {
+	if values.Get("state") == "" {
+		values.Del("state")
+	}
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	query := u.Query()
+	for key, value := range values {
+		query[key] = value
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 type CheckTokenRequest struct {
 	Token         string
 	ClientDetails model.ClientDetails
+	// 调用方服务的客户端 ID，令牌限定了受众时必须与之一致
+	Audience string
 }
 
 type CheckTokenResponse struct {
@@ -83,6 +278,10 @@ func MakeCheckTokenEndpoint(svc service.TokenService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(*CheckTokenRequest)
 		tokenDetail, err := svc.GetOAuth2DetailsByAccessToken(req.Token)
+		// 令牌交换签发的令牌只能在其受众服务使用
+		if err == nil && !tokenDetail.IsIntendedFor(req.Audience) {
+			tokenDetail, err = nil, service.ErrTokenAudienceMismatch
+		}
 		var errString = ""
 		if err != nil {
 			errString = err.Error()
@@ -94,6 +293,337 @@ func MakeCheckTokenEndpoint(svc service.TokenService) endpoint.Endpoint {
 	}
 }
 
+type IntrospectRequest struct {
+	Token         string
+	TokenTypeHint string
+}
+
+// IntrospectResponse RFC 7662 格式的令牌信息，令牌无效时只返回 active=false
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	// RFC 8693 4.1 节，令牌交换签发的令牌的调用方
+	Act *model.Actor `json:"act,omitempty"`
+}
+
+// MakeIntrospectEndpoint 按 RFC 7662 返回访问令牌的状态，供网关和第三方库校验令牌
+func MakeIntrospectEndpoint(svc service.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*IntrospectRequest)
+		accessToken, err := svc.ReadAccessToken(req.Token)
+		if err != nil || accessToken.IsExpired() {
+			return IntrospectResponse{Active: false}, nil
+		}
+		oauth2Details, err := svc.GetOAuth2DetailsByAccessToken(req.Token)
+		if err != nil {
+			return IntrospectResponse{Active: false}, nil
+		}
+		resp := IntrospectResponse{
+			Active:    true,
+			Scope:     strings.Join(oauth2Details.Scope, " "),
+			ClientId:  oauth2Details.Client.ClientId,
+			TokenType: "Bearer",
+			Sub:       oauth2Details.Client.ClientId,
+			Aud:       oauth2Details.Client.ClientId,
+			Jti:       accessToken.TokenId,
+			Act:       oauth2Details.Actor,
+		}
+		if oauth2Details.Audience != "" {
+			resp.Aud = oauth2Details.Audience
+		}
+		if accessToken.ExpriesTime != nil {
+			resp.Exp = accessToken.ExpriesTime.Unix()
+		}
+		if oauth2Details.User != nil {
+			resp.Username = oauth2Details.User.Username
+			resp.Sub = strconv.FormatInt(oauth2Details.User.UserId, 10)
+		}
+		return resp, nil
+	}
+}
+
+type RevokeTokenRequest struct {
+	Token         string
+	TokenTypeHint string
+}
+
+type RevokeTokenResponse struct {
+}
+
+// MakeRevokeTokenEndpoint 按 RFC 7009 吊销令牌，客户端只能吊销签发给自己的令牌
+func MakeRevokeTokenEndpoint(svc service.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*RevokeTokenRequest)
+		clientDetails := ctx.Value(OAuth2ClientDetailsKey).(*model.ClientDetails)
+		if err := svc.RevokeToken(req.Token, req.TokenTypeHint, clientDetails); err != nil {
+			return nil, err
+		}
+		return RevokeTokenResponse{}, nil
+	}
+}
+
+// MakeJwksEndpoint 公开令牌签名公钥，HS256 签名时返回空集合
+func MakeJwksEndpoint(tokenEnhancer service.TokenEnhancer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if provider, ok := tokenEnhancer.(service.KeySetProvider); ok {
+			return provider.KeySet(), nil
+		}
+		return &service.JSONWebKeySet{Keys: []service.JSONWebKey{}}, nil
+	}
+}
+
+type UserInfoRequest struct {
+	Token string
+}
+
+// MakeUserInfoEndpoint OIDC 的 userinfo 端点，返回访问令牌所属用户的声明
+func MakeUserInfoEndpoint(svc service.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*UserInfoRequest)
+		oauth2Details, err := svc.GetOAuth2DetailsByAccessToken(req.Token)
+		if err != nil {
+			return nil, service.ErrInvalidAccessToken
+		}
+		if oauth2Details.User == nil || !oauth2Details.HasScope(service.OpenIdScope) {
+			return nil, service.ErrInsufficientScope
+		}
+		return service.NewUserInfo(oauth2Details.User), nil
+	}
+}
+
+// 路由名称，与元数据中对应的字段名一致，transport 按名称查找实际注册的路径
+const (
+	AuthorizationRoute = "authorization_endpoint"
+	TokenRoute         = "token_endpoint"
+	RevocationRoute    = "revocation_endpoint"
+	IntrospectionRoute = "introspection_endpoint"
+	UserInfoRoute      = "userinfo_endpoint"
+	JwksRoute          = "jwks_uri"
+	// RFC 8628 设备授权端点和用户输入用户码的验证页
+	DeviceAuthorizationRoute = "device_authorization_endpoint"
+	DeviceVerificationRoute  = "verification_uri"
+)
+
+// 生成元数据时每次读取的客户端数量
+const scopesPageSize = 100
+
+type OpenIdConfigRequest struct {
+	// 路由名称到路径的映射，由 transport 根据已注册的路由生成
+	Routes map[string]string
+}
+
+// endpointUrl 未注册的路由返回空字符串，元数据中省略该字段
+func (req *OpenIdConfigRequest) endpointUrl(baseUrl string, route string) string {
+	if path, ok := req.Routes[route]; ok {
+		return baseUrl + path
+	}
+	return ""
+}
+
+// resolveIssuer 各端点的地址以配置的 issuer 为前缀，不信任请求头中的 Host 和 X-Forwarded-Proto，
+// issuer 在启动时已校验为绝对 URL
+func resolveIssuer(tokenEnhancer service.TokenEnhancer) (issuer string, baseUrl string) {
+	if idTokenIssuer, ok := tokenEnhancer.(service.IdTokenIssuer); ok {
+		issuer = idTokenIssuer.Issuer()
+	}
+	return issuer, strings.TrimSuffix(issuer, "/")
+}
+
+// scopesSupported 返回已注册客户端的全部权限范围，签发 id_token 时加上 openid
+func scopesSupported(ctx context.Context, clientService service.ClientDetailsService, tokenEnhancer service.TokenEnhancer) ([]string, error) {
+	registered := make(map[string]bool)
+	if _, ok := tokenEnhancer.(service.IdTokenIssuer); ok {
+		registered[service.OpenIdScope] = true
+	}
+	for offset := 0; ; offset += scopesPageSize {
+		clientDetailsList, err := clientService.ListClientDetails(ctx, offset, scopesPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, clientDetails := range clientDetailsList {
+			if clientDetails.Disabled {
+				continue
+			}
+			for _, scope := range clientDetails.Scope {
+				registered[scope] = true
+			}
+		}
+		if len(clientDetailsList) < scopesPageSize {
+			break
+		}
+	}
+	scopes := make([]string, 0, len(registered))
+	for scope := range registered {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+func grantTypesSupported(tokenGranter service.TokenGranter) []string {
+	if provider, ok := tokenGranter.(service.GrantTypeProvider); ok {
+		return provider.GrantTypes()
+	}
+	return []string{}
+}
+
+// MakeOpenIdConfigEndpoint 生成 OIDC discovery 文档
+func MakeOpenIdConfigEndpoint(tokenGranter service.TokenGranter, tokenEnhancer service.TokenEnhancer, clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*OpenIdConfigRequest)
+		issuer, baseUrl := resolveIssuer(tokenEnhancer)
+		scopes, err := scopesSupported(ctx, clientService, tokenEnhancer)
+		if err != nil {
+			return nil, err
+		}
+		signingAlgorithms := []string{}
+		if idTokenIssuer, ok := tokenEnhancer.(service.IdTokenIssuer); ok {
+			signingAlgorithms = idTokenIssuer.SigningAlgorithms()
+		}
+		return &service.OpenIdConfiguration{
+			Issuer:                            issuer,
+			AuthorizationEndpoint:             req.endpointUrl(baseUrl, AuthorizationRoute),
+			TokenEndpoint:                     req.endpointUrl(baseUrl, TokenRoute),
+			UserInfoEndpoint:                  req.endpointUrl(baseUrl, UserInfoRoute),
+			JwksUri:                           req.endpointUrl(baseUrl, JwksRoute),
+			RevocationEndpoint:                req.endpointUrl(baseUrl, RevocationRoute),
+			IntrospectionEndpoint:             req.endpointUrl(baseUrl, IntrospectionRoute),
+			ScopesSupported:                   scopes,
+			ResponseTypesSupported:            []string{"code"},
+			CodeChallengeMethodsSupported:     []string{service.CodeChallengeMethodS256},
+			GrantTypesSupported:               grantTypesSupported(tokenGranter),
+			SubjectTypesSupported:             []string{"public"},
+			IdTokenSigningAlgValuesSupported:  signingAlgorithms,
+			TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "none"},
+			ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "preferred_username", "authorities"},
+		}, nil
+	}
+}
+
+// MakeAuthorizationServerMetadataEndpoint 按 RFC 8414 生成授权服务器元数据，授权类型取自已注册的令牌授予者
+func MakeAuthorizationServerMetadataEndpoint(tokenGranter service.TokenGranter, tokenEnhancer service.TokenEnhancer, clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*OpenIdConfigRequest)
+		issuer, baseUrl := resolveIssuer(tokenEnhancer)
+		scopes, err := scopesSupported(ctx, clientService, tokenEnhancer)
+		if err != nil {
+			return nil, err
+		}
+		grantTypes := grantTypesSupported(tokenGranter)
+		responseTypes := []string{}
+		var codeChallengeMethods []string
+		for _, grantType := range grantTypes {
+			if grantType == "authorization_code" {
+				responseTypes = append(responseTypes, "code")
+				codeChallengeMethods = []string{service.CodeChallengeMethodS256}
+			}
+		}
+		return &service.AuthorizationServerMetadata{
+			Issuer:                                    issuer,
+			AuthorizationEndpoint:                     req.endpointUrl(baseUrl, AuthorizationRoute),
+			TokenEndpoint:                             req.endpointUrl(baseUrl, TokenRoute),
+			JwksUri:                                   req.endpointUrl(baseUrl, JwksRoute),
+			ScopesSupported:                           scopes,
+			ResponseTypesSupported:                    responseTypes,
+			GrantTypesSupported:                       grantTypes,
+			TokenEndpointAuthMethodsSupported:         []string{"client_secret_basic", "none"},
+			RevocationEndpoint:                        req.endpointUrl(baseUrl, RevocationRoute),
+			RevocationEndpointAuthMethodsSupported:    []string{"client_secret_basic", "none"},
+			IntrospectionEndpoint:                     req.endpointUrl(baseUrl, IntrospectionRoute),
+			IntrospectionEndpointAuthMethodsSupported: []string{"client_secret_basic"},
+			DeviceAuthorizationEndpoint:               req.endpointUrl(baseUrl, DeviceAuthorizationRoute),
+			CodeChallengeMethodsSupported:             codeChallengeMethods,
+		}, nil
+	}
+}
+
+type RotateSecretRequest struct {
+	ClientId    string
+	GracePeriod time.Duration
+}
+
+type RotateSecretResponse struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// 旧密钥失效的时间戳，秒
+	PreviousSecretExpiresAt int64 `json:"previous_secret_expires_at"`
+}
+
+// MakeRotateSecretEndpoint 轮换客户端密钥，新密钥只在响应中返回一次
+func MakeRotateSecretEndpoint(clientService service.ClientDetailsService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*RotateSecretRequest)
+		clientSecret, previousSecretExpiresAt, err := clientService.RotateClientSecret(ctx, req.ClientId, req.GracePeriod)
+		if err != nil {
+			return nil, err
+		}
+		return RotateSecretResponse{
+			ClientId:                req.ClientId,
+			ClientSecret:            clientSecret,
+			PreviousSecretExpiresAt: previousSecretExpiresAt.Unix(),
+		}, nil
+	}
+}
+
+// UnlockLoginRequest 至少指定用户名或 IP 之一
+type UnlockLoginRequest struct {
+	Username string
+	Ip       string
+}
+
+type UnlockLoginResponse struct {
+	Success bool `json:"success"`
+}
+
+// MakeUnlockLoginEndpoint 解除密码登录失败导致的锁定
+func MakeUnlockLoginEndpoint(loginAttemptGuard *service.LoginAttemptGuard) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*UnlockLoginRequest)
+		if req.Username != "" {
+			if err = loginAttemptGuard.Unlock(service.LoginAttemptUser, req.Username); err != nil {
+				return nil, err
+			}
+		}
+		if req.Ip != "" {
+			if err = loginAttemptGuard.Unlock(service.LoginAttemptIp, req.Ip); err != nil {
+				return nil, err
+			}
+		}
+		return UnlockLoginResponse{Success: true}, nil
+	}
+}
+
+// RevokeUserTokensRequest ClientId 为空时吊销用户在全部客户端的令牌
+type RevokeUserTokensRequest struct {
+	UserId   int64
+	ClientId string
+}
+
+type RevokeUserTokensResponse struct {
+	Success bool `json:"success"`
+	// 从存储中删除的令牌数量，自包含的 JWT 通过吊销时间失效，不计入该数量
+	Revoked int `json:"revoked"`
+}
+
+// MakeRevokeUserTokensEndpoint 用户重置密码或被风控标记后吊销其全部令牌
+func MakeRevokeUserTokensEndpoint(svc service.TokenService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*RevokeUserTokensRequest)
+		revoked, err := svc.RevokeUserTokens(req.UserId, req.ClientId)
+		if err != nil {
+			return nil, err
+		}
+		return RevokeUserTokensResponse{Success: true, Revoked: revoked}, nil
+	}
+}
+
 // HealthRequest 健康检查请求结构
 type HealthRequest struct{}
 
@@ -8,6 +8,7 @@ import (
 	"SecondKill/oauth-service/transport"
 	"SecondKill/pb"
 	"SecondKill/pkg/bootstrap"
+	"SecondKill/pkg/common"
 	"SecondKill/pkg/config"
 	register "SecondKill/pkg/discover"
 	"SecondKill/pkg/mysql"
@@ -15,18 +16,22 @@ import (
 	"flag"
 	"fmt"
 	kitzipkin "github.com/go-kit/kit/tracing/zipkin"
+	"github.com/go-redis/redis"
 	"google.golang.org/grpc"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
-	"golang.org/x/time/rate"
 	"google.golang.org/grpc/metadata"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 )
 
+// 客户端认证结果的缓存时间，停用客户端或轮换密钥后其他实例最多延迟该时间生效
+const clientAuthenticationCacheTTL = 30 * time.Second
+
 func main() {
 	var (
 		servicePort = flag.String("service.port", bootstrap.HttpConfig.Port, "service port")
@@ -42,46 +47,137 @@ func main() {
 		clientDetailsService service.ClientDetailsService
 		srv                  service.Service
 	)
-	ratebucket := rate.NewLimiter(rate.Every(time.Second*1), 100)
+	rateLimiter := newRateLimiter()
 	srv = service.NewCommentService()
-	tokenEnhancer = service.NewJwtTokenEnhancer("secret")
-	tokenStore = service.NewJwtTokenStore(tokenEnhancer.(*service.JwtTokenEnhancer))
-	tokenService = service.NewTokenService(tokenStore, tokenEnhancer)
-	userDetailsService = service.NewRemoteUserDetailService()
-	clientDetailsService = service.NewMysqlClientDetailsService()
+	tokenEnhancer = newTokenEnhancer()
+	config.Redis.RedisConn = redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Host,
+		Password: config.Redis.Password,
+		DB:       config.Redis.Db,
+	})
+	tokenStore = service.NewRedisTokenStore(config.Redis.RedisConn)
+	tokenDenylist := service.NewRedisTokenDenylist(config.Redis.RedisConn)
+	tokenService = service.NewTokenService(tokenStore, tokenEnhancer, tokenDenylist, service.NewLogAuditLogger(localconfig.Logger))
+	clientDetailsService = service.NewCachingClientDetailsService(service.NewMysqlClientDetailsService(), clientAuthenticationCacheTTL)
+	clientIpResolver := newClientIpResolver()
+	// 所有校验密码的入口共用登录失败限制
+	loginAttemptGuard := newLoginAttemptGuard()
+	userDetailsService = service.NewLoginAttemptUserDetailsService(service.NewRemoteUserDetailService(), loginAttemptGuard)
 	passWordGranter := service.NewUsernamePasswordTokenGranter("password", userDetailsService, tokenService)
 	refreshGranter := service.NewRefreshGranter("refresh_token", userDetailsService, tokenService)
+	authorizationCodeService := service.NewRedisAuthorizationCodeService(config.Redis.RedisConn)
+	authorizationCodeGranter := service.NewAuthorizationCodeTokenGranter("authorization_code", authorizationCodeService, tokenService)
+	clientCredentialsGranter := service.NewClientCredentialsTokenGranter("client_credentials", tokenService)
+	deviceAuthorizationService := service.NewDeviceAuthorizationService(service.NewRedisDeviceCodeStore(config.Redis.RedisConn))
+	deviceCodeGranter := service.NewDeviceCodeTokenGranter(service.DeviceCodeGrantType, deviceAuthorizationService, tokenService)
+	tokenExchangeGranter := service.NewTokenExchangeGranter(service.TokenExchangeGrantType, tokenEnhancer, clientDetailsService, tokenService)
 	tokenGranter = service.NewComposeTokenGrante(map[string]service.TokenGranter{
-		"password":      passWordGranter,
-		"refresh_token": refreshGranter,
+		"password":                     passWordGranter,
+		"refresh_token":                refreshGranter,
+		"authorization_code":           authorizationCodeGranter,
+		"client_credentials":           clientCredentialsGranter,
+		service.DeviceCodeGrantType:    deviceCodeGranter,
+		service.TokenExchangeGrantType: tokenExchangeGranter,
 	})
 	tokenEndpoint := endpoint.MakeTokenEndPoint(tokenGranter, clientDetailsService)
-	tokenEndpoint = endpoint.MakeClientAuthorizationMiddleware(localconfig.Logger)(tokenEndpoint)
-	tokenEndpoint = plugins.NewTokenBucketLimitterWithBuildIn(ratebucket)(tokenEndpoint)
+	tokenEndpoint = endpoint.MakeClientAuthorizationMiddleware(clientDetailsService, localconfig.Logger)(tokenEndpoint)
+	tokenEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "token")(tokenEndpoint)
 	tokenEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "token-endpoint")(tokenEndpoint)
 
+	authorizeEndpoint := endpoint.MakeAuthorizeEndpoint(clientDetailsService, userDetailsService, authorizationCodeService)
+	authorizeEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "authorize")(authorizeEndpoint)
+	authorizeEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "authorize-endpoint")(authorizeEndpoint)
+
+	deviceAuthorizationEndpoint := endpoint.MakeDeviceAuthorizationEndpoint(deviceAuthorizationService, tokenEnhancer)
+	deviceAuthorizationEndpoint = endpoint.MakeClientAuthorizationMiddleware(clientDetailsService, localconfig.Logger)(deviceAuthorizationEndpoint)
+	deviceAuthorizationEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "device-authorization")(deviceAuthorizationEndpoint)
+	deviceAuthorizationEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "device-authorization-endpoint")(deviceAuthorizationEndpoint)
+
+	deviceVerificationEndpoint := endpoint.MakeDeviceVerificationEndpoint(deviceAuthorizationService, userDetailsService)
+	deviceVerificationEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "device-verification")(deviceVerificationEndpoint)
+	deviceVerificationEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "device-verification-endpoint")(deviceVerificationEndpoint)
+
 	checkEndpoint := endpoint.MakeCheckTokenEndpoint(tokenService)
-	checkEndpoint = endpoint.MakeClientAuthorizationMiddleware(localconfig.Logger)(checkEndpoint)
-	checkEndpoint = plugins.NewTokenBucketLimitterWithBuildIn(ratebucket)(checkEndpoint)
+	checkEndpoint = endpoint.MakeClientAuthorizationMiddleware(clientDetailsService, localconfig.Logger)(checkEndpoint)
+	checkEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "check-token")(checkEndpoint)
 	checkEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "check-endpoint")(checkEndpoint)
 
 	gRPCCheckTokenEndpoint := endpoint.MakeCheckTokenEndpoint(tokenService)
-	gRPCCheckTokenEndpoint = plugins.NewTokenBucketLimitterWithBuildIn(ratebucket)(gRPCCheckTokenEndpoint)
+	gRPCCheckTokenEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "grpc-check-token")(gRPCCheckTokenEndpoint)
 	gRPCCheckTokenEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "grpc-check-endpoint")(gRPCCheckTokenEndpoint)
 
+	revokeEndpoint := endpoint.MakeRevokeTokenEndpoint(tokenService)
+	revokeEndpoint = endpoint.MakeClientAuthorizationMiddleware(clientDetailsService, localconfig.Logger)(revokeEndpoint)
+	revokeEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "revoke")(revokeEndpoint)
+	revokeEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "revoke-endpoint")(revokeEndpoint)
+
+	introspectEndpoint := endpoint.MakeIntrospectEndpoint(tokenService)
+	introspectEndpoint = endpoint.MakeClientAuthorizationMiddleware(clientDetailsService, localconfig.Logger)(introspectEndpoint)
+	introspectEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "introspect")(introspectEndpoint)
+	introspectEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "introspect-endpoint")(introspectEndpoint)
+
+	jwksEndpoint := endpoint.MakeJwksEndpoint(tokenEnhancer)
+
+	userInfoEndpoint := endpoint.MakeUserInfoEndpoint(tokenService)
+	userInfoEndpoint = plugins.NewRateLimitMiddleware(rateLimiter, "userinfo")(userInfoEndpoint)
+	userInfoEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "userinfo-endpoint")(userInfoEndpoint)
+
+	openIdConfigEndpoint := endpoint.MakeOpenIdConfigEndpoint(tokenGranter, tokenEnhancer, clientDetailsService)
+	serverMetadataEndpoint := endpoint.MakeAuthorizationServerMetadataEndpoint(tokenGranter, tokenEnhancer, clientDetailsService)
+
+	//客户端管理的Endpoint，要求 admin 范围的访问令牌
+	adminMiddleware := endpoint.MakeAdminAuthorizationMiddleware(tokenService, localconfig.Logger)
+	rotateSecretEndpoint := endpoint.MakeRotateSecretEndpoint(clientDetailsService)
+	rotateSecretEndpoint = adminMiddleware(rotateSecretEndpoint)
+	rotateSecretEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "rotate-secret-endpoint")(rotateSecretEndpoint)
+	createClientEndpoint := endpoint.MakeCreateClientEndpoint(clientDetailsService)
+	createClientEndpoint = adminMiddleware(createClientEndpoint)
+	createClientEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "create-client-endpoint")(createClientEndpoint)
+	updateClientEndpoint := endpoint.MakeUpdateClientEndpoint(clientDetailsService)
+	updateClientEndpoint = adminMiddleware(updateClientEndpoint)
+	updateClientEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "update-client-endpoint")(updateClientEndpoint)
+	disableClientEndpoint := endpoint.MakeDisableClientEndpoint(clientDetailsService, tokenService)
+	disableClientEndpoint = adminMiddleware(disableClientEndpoint)
+	disableClientEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "disable-client-endpoint")(disableClientEndpoint)
+	listClientsEndpoint := endpoint.MakeListClientsEndpoint(clientDetailsService)
+	listClientsEndpoint = adminMiddleware(listClientsEndpoint)
+	listClientsEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "list-clients-endpoint")(listClientsEndpoint)
+	unlockLoginEndpoint := endpoint.MakeUnlockLoginEndpoint(loginAttemptGuard)
+	unlockLoginEndpoint = adminMiddleware(unlockLoginEndpoint)
+	unlockLoginEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "unlock-login-endpoint")(unlockLoginEndpoint)
+	revokeUserTokensEndpoint := endpoint.MakeRevokeUserTokensEndpoint(tokenService)
+	revokeUserTokensEndpoint = adminMiddleware(revokeUserTokensEndpoint)
+	revokeUserTokensEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "revoke-user-tokens-endpoint")(revokeUserTokensEndpoint)
+
 	//创建健康检查的Endpoint
 	healthEndpoint := endpoint.MakeHealthCheckEndpoint(srv)
 	healthEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "health-endpoint")(healthEndpoint)
 	endpts := endpoint.OAuth2Endpoints{
-		TokenEndpoint:          tokenEndpoint,
-		CheckTokenEndpoint:     checkEndpoint,
-		HealthCheckEndpoint:    healthEndpoint,
-		GRPCCheckTokenEndpoint: gRPCCheckTokenEndpoint,
+		TokenEndpoint:               tokenEndpoint,
+		AuthorizeEndpoint:           authorizeEndpoint,
+		JwksEndpoint:                jwksEndpoint,
+		RevokeTokenEndpoint:         revokeEndpoint,
+		IntrospectEndpoint:          introspectEndpoint,
+		UserInfoEndpoint:            userInfoEndpoint,
+		OpenIdConfigEndpoint:        openIdConfigEndpoint,
+		ServerMetadataEndpoint:      serverMetadataEndpoint,
+		RotateSecretEndpoint:        rotateSecretEndpoint,
+		CreateClientEndpoint:        createClientEndpoint,
+		UpdateClientEndpoint:        updateClientEndpoint,
+		DisableClientEndpoint:       disableClientEndpoint,
+		ListClientsEndpoint:         listClientsEndpoint,
+		UnlockLoginEndpoint:         unlockLoginEndpoint,
+		RevokeUserTokensEndpoint:    revokeUserTokensEndpoint,
+		DeviceAuthorizationEndpoint: deviceAuthorizationEndpoint,
+		DeviceVerificationEndpoint:  deviceVerificationEndpoint,
+		CheckTokenEndpoint:          checkEndpoint,
+		HealthCheckEndpoint:         healthEndpoint,
+		GRPCCheckTokenEndpoint:      gRPCCheckTokenEndpoint,
 	}
 	ctx := context.Background()
 	errChan := make(chan error)
 	//创建http.Handler
-	r := transport.MakeHttpHandler(ctx, endpts, tokenService, clientDetailsService, localconfig.ZipkinTracer, localconfig.Logger)
+	r := transport.MakeHttpHandler(ctx, endpts, tokenService, clientIpResolver, localconfig.ZipkinTracer, localconfig.Logger)
 
 	// http server
 	go func() {
@@ -107,7 +203,7 @@ func main() {
 		parentSpan := tr.StartSpan("test")
 		b3.InjectGRPC(&md)(parentSpan.Context())
 		ctx := metadata.NewIncomingContext(context.Background(), md)
-		handler := transport.NewGRPCServer(ctx, endpts, serverTracer)
+		handler := transport.NewGRPCServer(ctx, endpts, clientIpResolver, serverTracer)
 		gRPCServer := grpc.NewServer()
 		pb.RegisterOAuthServiceServer(gRPCServer, handler)
 		errChan <- gRPCServer.Serve(listener)
@@ -123,3 +219,95 @@ func main() {
 	register.DeRegister()
 	fmt.Println(error)
 }
+
+// newRateLimiter 按配置为每个端点和客户端创建独立的令牌桶
+func newRateLimiter() *plugins.RateLimiter {
+	rateLimitConfig := localconfig.RateLimitConfig
+	toRateLimit := func(rule localconfig.RateLimitRule) plugins.RateLimit {
+		return plugins.RateLimit{Rate: rule.Rate, Burst: rule.Burst}
+	}
+	endpointLimits := make(map[string]plugins.RateLimit)
+	for endpointName, rule := range rateLimitConfig.Endpoints {
+		endpointLimits[endpointName] = toRateLimit(rule)
+	}
+	clientLimits := make(map[string]plugins.RateLimit)
+	for clientId, rule := range rateLimitConfig.Clients {
+		clientLimits[clientId] = toRateLimit(rule)
+	}
+	return plugins.NewRateLimiter(toRateLimit(rateLimitConfig.Default), endpointLimits, clientLimits)
+}
+
+// newClientIpResolver 受信代理配置有误时拒绝启动，避免错误地信任或忽略 X-Forwarded-For
+func newClientIpResolver() *common.ClientIpResolver {
+	clientIpResolver, err := common.NewClientIpResolver(config.Proxy.TrustedProxies)
+	if err != nil {
+		localconfig.Logger.Log("Fail to parse trusted proxies", err)
+		os.Exit(1)
+	}
+	return clientIpResolver
+}
+
+// newLoginAttemptGuard 登录失败次数保存在 redis，redis 不可用时降级到本地内存
+func newLoginAttemptGuard() *service.LoginAttemptGuard {
+	loginConfig := localconfig.LoginConfig
+	store := service.NewFallbackLoginAttemptStore(service.NewRedisLoginAttemptStore(config.Redis.RedisConn),
+		service.NewInMemoryLoginAttemptStore(), localconfig.Logger)
+	userPolicy := service.LoginAttemptPolicy{
+		MaxFailures:   loginConfig.MaxUserFailures,
+		BaseLockout:   time.Duration(loginConfig.BaseLockout) * time.Second,
+		MaxLockout:    time.Duration(loginConfig.MaxLockout) * time.Second,
+		FailureWindow: time.Duration(loginConfig.FailureWindow) * time.Second,
+	}
+	ipPolicy := userPolicy
+	ipPolicy.MaxFailures = loginConfig.MaxIpFailures
+	return service.NewLoginAttemptGuard(store, userPolicy, ipPolicy, service.NewPrometheusLoginAttemptMetrics(), localconfig.Logger)
+}
+
+// newTokenEnhancer 配置了非对称密钥时使用 RS256/ES256 签名，否则使用 HS256
+func newTokenEnhancer() service.TokenEnhancer {
+	var tokenEnhancer service.TokenEnhancer
+	if len(localconfig.JwtConfig.Keys) == 0 {
+		// HS256 签名必须显式配置密钥
+		if localconfig.JwtConfig.Secret == "" {
+			localconfig.Logger.Log("Fail to create token enhancer", "jwt secret is required when no signing keys are configured")
+			os.Exit(1)
+		}
+		tokenEnhancer = service.NewJwtTokenEnhancer(localconfig.JwtConfig.Secret)
+	} else {
+		tokenEnhancer = newAsymmetricTokenEnhancer()
+	}
+	jwtTokenEnhancer := tokenEnhancer.(*service.JwtTokenEnhancer)
+	// 元数据和设备验证页的地址取自 issuer，不能根据请求头推断
+	if issuer, err := url.Parse(localconfig.JwtConfig.Issuer); err != nil || !issuer.IsAbs() || issuer.Host == "" {
+		localconfig.Logger.Log("Fail to create token enhancer", "jwt issuer must be an absolute url")
+		os.Exit(1)
+	}
+	jwtTokenEnhancer.SetIssuer(localconfig.JwtConfig.Issuer)
+	if localconfig.JwtConfig.LegacyClaims {
+		// 旧格式的令牌使用 Secret 签名，未配置时无法校验
+		if localconfig.JwtConfig.Secret == "" {
+			localconfig.Logger.Log("Fail to create token enhancer", "jwt secret is required to accept legacy claims")
+			os.Exit(1)
+		}
+		jwtTokenEnhancer.AcceptLegacyClaims(localconfig.JwtConfig.Secret)
+	}
+	return tokenEnhancer
+}
+
+func newAsymmetricTokenEnhancer() service.TokenEnhancer {
+	var signingKeys []*service.SigningKey
+	for _, keyConf := range localconfig.JwtConfig.Keys {
+		signingKey, err := service.LoadSigningKey(keyConf.Kid, keyConf.Alg, keyConf.Path)
+		if err != nil {
+			localconfig.Logger.Log("Fail to load signing key", keyConf.Kid, "err", err)
+			os.Exit(1)
+		}
+		signingKeys = append(signingKeys, signingKey)
+	}
+	tokenEnhancer, err := service.NewJwtTokenEnhancerWithKeys(localconfig.JwtConfig.ActiveKid, signingKeys...)
+	if err != nil {
+		localconfig.Logger.Log("Fail to create token enhancer", err)
+		os.Exit(1)
+	}
+	return tokenEnhancer
+}
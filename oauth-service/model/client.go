@@ -2,14 +2,28 @@ package model
 
 import (
 	"SecondKill/pkg/mysql"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"github.com/gohouse/gorose/v2"
+	"golang.org/x/crypto/bcrypt"
 	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
 )
 
 type ClientDetails struct {
 	// Client 标识
-	ClientId     string
+	ClientId string
+	// 客户端密钥的 bcrypt 哈希，历史数据可能仍是明文
 	ClientSecret string
+	// 轮换密钥后，旧密钥在宽限期内仍然可用
+	PreviousClientSecret    string
+	PreviousSecretExpiresAt *time.Time
 	// 访问令牌有效时间，秒
 	AccessTokenValiditySeconds int
 	// 刷新令牌有效时间，秒
@@ -18,10 +32,76 @@ type ClientDetails struct {
 	RegisteredRedirectUri string
 	// 可以使用的授权类型
 	AuthorizedGrantTypes []string
+	// 可以申请的权限范围
+	Scope []string
+	// 公开客户端（移动端、单页应用）没有密钥，授权码模式必须使用 PKCE
+	Public bool
+	// 停用的客户端无法通过认证，也无法申请令牌
+	Disabled bool
 }
 
 func (clientDetails *ClientDetails) IsMatch(clientId string, clientSecret string) bool {
-	return clientId == clientDetails.ClientId && clientSecret == clientDetails.ClientSecret
+	if clientId != clientDetails.ClientId {
+		return false
+	}
+	if matchClientSecret(clientDetails.ClientSecret, clientSecret) {
+		return true
+	}
+	// 宽限期内旧密钥仍然有效
+	return clientDetails.PreviousSecretExpiresAt != nil &&
+		clientDetails.PreviousSecretExpiresAt.After(time.Now()) &&
+		matchClientSecret(clientDetails.PreviousClientSecret, clientSecret)
+}
+
+// IsSecretHashed 判断当前密钥是否已经以哈希形式存储
+func (clientDetails *ClientDetails) IsSecretHashed() bool {
+	return isHashedSecret(clientDetails.ClientSecret)
+}
+
+// HashClientSecret 使用 bcrypt 计算客户端密钥的哈希
+func HashClientSecret(clientSecret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(clientSecret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func isHashedSecret(storedSecret string) bool {
+	return strings.HasPrefix(storedSecret, "$2a$") ||
+		strings.HasPrefix(storedSecret, "$2b$") ||
+		strings.HasPrefix(storedSecret, "$2y$")
+}
+
+// matchClientSecret 哈希密钥使用 bcrypt 校验，明文密钥使用常量时间比较
+func matchClientSecret(storedSecret string, clientSecret string) bool {
+	if storedSecret == "" || clientSecret == "" {
+		return false
+	}
+	if isHashedSecret(storedSecret) {
+		return bcrypt.CompareHashAndPassword([]byte(storedSecret), []byte(clientSecret)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(storedSecret), []byte(clientSecret)) == 1
+}
+
+// IsGrantTypeAuthorized 判断客户端是否注册了该授权类型
+func (clientDetails *ClientDetails) IsGrantTypeAuthorized(grantType string) bool {
+	for _, authorizedGrantType := range clientDetails.AuthorizedGrantTypes {
+		if authorizedGrantType == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScopeAuthorized 判断客户端是否注册了该权限范围
+func (clientDetails *ClientDetails) IsScopeAuthorized(scope string) bool {
+	for _, registeredScope := range clientDetails.Scope {
+		if registeredScope == scope {
+			return true
+		}
+	}
+	return false
 }
 
 type ClientDetailsModel struct {
@@ -38,33 +118,84 @@ func (p *ClientDetailsModel) getTableName() string {
 func (p *ClientDetailsModel) GetClientDetailsByClientId(clientId string) (*ClientDetails, error) {
 	conn := mysql.DB()
 	if result, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
-		"client+id": clientId,
+		"client_id": clientId,
 	}).First(); err == nil {
-		var authorizedGrantTypes []string
-		_ = json.Unmarshal([]byte(result["authorized_grant_types"].(string)), &authorizedGrantTypes)
-		return &ClientDetails{
-			ClientId:                    result["client_id"].(string),
-			ClientSecret:                result["client_secret"].(string),
-			AccessTokenValiditySeconds:  int(result["access_token_validity_seconds"].(int64)),
-			RefreshTokenValiditySeconds: int(result["refresh_token_validity_seconds"].(int64)),
-			RegisteredRedirectUri:       result["registered_redirect_uri"].(string),
-			AuthorizedGrantTypes:        authorizedGrantTypes,
-		}, nil
+		if len(result) == 0 {
+			return nil, ErrClientNotFound
+		}
+		return toClientDetails(result), nil
 	} else {
 		return nil, err
 	}
 }
 
+// ListClientDetails 按 client_id 排序分页查询客户端
+func (p *ClientDetailsModel) ListClientDetails(offset int, limit int) ([]*ClientDetails, error) {
+	conn := mysql.DB()
+	result, err := conn.Table(p.getTableName()).Order("client_id").Offset(offset).Limit(limit).Get()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return nil, err
+	}
+	clientDetailsList := make([]*ClientDetails, 0, len(result))
+	for _, row := range result {
+		clientDetailsList = append(clientDetailsList, toClientDetails(row))
+	}
+	return clientDetailsList, nil
+}
+
+func toClientDetails(result gorose.Data) *ClientDetails {
+	var authorizedGrantTypes []string
+	_ = json.Unmarshal([]byte(result["authorized_grant_types"].(string)), &authorizedGrantTypes)
+	var scope []string
+	if scopeString, ok := result["scope"].(string); ok {
+		_ = json.Unmarshal([]byte(scopeString), &scope)
+	}
+	public, _ := result["public"].(int64)
+	disabled, _ := result["disabled"].(int64)
+	previousClientSecret, _ := result["previous_client_secret"].(string)
+	var previousSecretExpiresAt *time.Time
+	if expiresAt, ok := result["previous_secret_expires_at"].(int64); ok && expiresAt > 0 {
+		expiresTime := time.Unix(expiresAt, 0)
+		previousSecretExpiresAt = &expiresTime
+	}
+	return &ClientDetails{
+		ClientId:                    result["client_id"].(string),
+		ClientSecret:                result["client_secret"].(string),
+		AccessTokenValiditySeconds:  int(result["access_token_validity_seconds"].(int64)),
+		RefreshTokenValiditySeconds: int(result["refresh_token_validity_seconds"].(int64)),
+		RegisteredRedirectUri:       result["registered_redirect_uri"].(string),
+		AuthorizedGrantTypes:        authorizedGrantTypes,
+		Scope:                       scope,
+		Public:                      public == 1,
+		Disabled:                    disabled == 1,
+		PreviousClientSecret:        previousClientSecret,
+		PreviousSecretExpiresAt:     previousSecretExpiresAt,
+	}
+}
+
+// CreateClientDetails 保存客户端信息，明文密钥先转换为 bcrypt 哈希
 func (p *ClientDetailsModel) CreateClientDetails(clientDetails *ClientDetails) error {
+	if clientDetails.ClientSecret != "" && !clientDetails.IsSecretHashed() {
+		secretHash, err := HashClientSecret(clientDetails.ClientSecret)
+		if err != nil {
+			return err
+		}
+		clientDetails.ClientSecret = secretHash
+	}
 	conn := mysql.DB()
 	grantTypeString, _ := json.Marshal(clientDetails.AuthorizedGrantTypes)
+	scopeString, _ := json.Marshal(clientDetails.Scope)
 	_, err := conn.Table(p.getTableName()).Data(map[string]interface{}{
 		"client_id":                      clientDetails.ClientId,
 		"client_secret":                  clientDetails.ClientSecret,
 		"access_token_validity_seconds":  clientDetails.AccessTokenValiditySeconds,
-		"refresh_token_validity_seconds": clientDetails.RegisteredRedirectUri,
+		"refresh_token_validity_seconds": clientDetails.RefreshTokenValiditySeconds,
 		"registered_redirect_uri":        clientDetails.RegisteredRedirectUri,
 		"authorized_grant_types":         grantTypeString,
+		"scope":                          scopeString,
+		"public":                         clientDetails.Public,
+		"disabled":                       clientDetails.Disabled,
 	}).Insert()
 	if err != nil {
 		log.Printf("Error : %v", err)
@@ -72,3 +203,61 @@ func (p *ClientDetailsModel) CreateClientDetails(clientDetails *ClientDetails) e
 	}
 	return nil
 }
+
+// UpdateClientSecret 更新客户端密钥，previousClientSecret 为空时清除旧密钥
+func (p *ClientDetailsModel) UpdateClientSecret(clientId string, clientSecret string, previousClientSecret string, previousSecretExpiresAt *time.Time) error {
+	var expiresAt int64
+	if previousSecretExpiresAt != nil {
+		expiresAt = previousSecretExpiresAt.Unix()
+	}
+	conn := mysql.DB()
+	_, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
+		"client_id": clientId,
+	}).Data(map[string]interface{}{
+		"client_secret":              clientSecret,
+		"previous_client_secret":     previousClientSecret,
+		"previous_secret_expires_at": expiresAt,
+	}).Update()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateClientDetails 更新客户端的令牌有效期、重定向地址、授权类型和权限范围，不修改密钥
+func (p *ClientDetailsModel) UpdateClientDetails(clientDetails *ClientDetails) error {
+	conn := mysql.DB()
+	grantTypeString, _ := json.Marshal(clientDetails.AuthorizedGrantTypes)
+	scopeString, _ := json.Marshal(clientDetails.Scope)
+	_, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
+		"client_id": clientDetails.ClientId,
+	}).Data(map[string]interface{}{
+		"access_token_validity_seconds":  clientDetails.AccessTokenValiditySeconds,
+		"refresh_token_validity_seconds": clientDetails.RefreshTokenValiditySeconds,
+		"registered_redirect_uri":        clientDetails.RegisteredRedirectUri,
+		"authorized_grant_types":         grantTypeString,
+		"scope":                          scopeString,
+		"public":                         clientDetails.Public,
+	}).Update()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetClientDisabled 停用或重新启用客户端
+func (p *ClientDetailsModel) SetClientDisabled(clientId string, disabled bool) error {
+	conn := mysql.DB()
+	_, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
+		"client_id": clientId,
+	}).Data(map[string]interface{}{
+		"disabled": disabled,
+	}).Update()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+type AuthorizationCode struct {
+	Code string
+	// 授权码绑定的客户端和重定向地址
+	ClientId    string
+	RedirectUri string
+	// 授权的用户
+	User  *UserDetails
+	Scope []string
+	// OIDC 授权请求中的 nonce
+	Nonce string
+	// PKCE 的 code_challenge，兑换授权码时需提供对应的 code_verifier
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpriesTime         *time.Time
+}
+
+func (code *AuthorizationCode) IsExpired() bool {
+	return code.ExpriesTime != nil &&
+		code.ExpriesTime.Before(time.Now())
+}
+
+const (
+	DeviceCodeStatusPending  = "pending"
+	DeviceCodeStatusApproved = "approved"
+	DeviceCodeStatusDenied   = "denied"
+)
+
+// DeviceCode RFC 8628 设备授权请求，设备凭 DeviceCode 轮询，用户在其他设备上输入 UserCode 完成授权
+type DeviceCode struct {
+	DeviceCode string
+	// 不含分隔符的大写用户码
+	UserCode string
+	ClientId string
+	Scope    []string
+	// 用户批准后填写
+	User   *UserDetails
+	Status string
+	// 设备两次轮询之间的最小间隔，单位秒
+	Interval    int
+	ExpriesTime *time.Time
+}
+
+func (code *DeviceCode) IsExpired() bool {
+	return code.ExpriesTime != nil &&
+		code.ExpriesTime.Before(time.Now())
+}
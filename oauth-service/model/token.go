@@ -8,6 +8,16 @@ type OAuth2Token struct {
 	TokenType   string
 	TokenValue  string
 	ExpriesTime *time.Time
+	// 令牌唯一标识，对应 JWT 的 jti
+	TokenId string
+	// 令牌被授予的权限范围
+	Scope []string
+	// 刷新令牌所属的家族，轮换后的刷新令牌沿用同一个家族
+	FamilyId string
+	// 申请 openid 范围时签发的 OIDC id_token
+	IdToken string `json:",omitempty"`
+	// 签发时间，早于用户的吊销时间的令牌视为已吊销
+	IssuedAt *time.Time `json:",omitempty"`
 }
 
 func (oauth2token *OAuth2Token) IsExpired() bool {
@@ -18,4 +28,55 @@ func (oauth2token *OAuth2Token) IsExpired() bool {
 type OAuth2Details struct {
 	Client *ClientDetails
 	User   *UserDetails
+	// 本次授权的权限范围，是客户端注册范围的子集
+	Scope []string
+	// OIDC 授权请求中的 nonce，只写入本次签发的 id_token，不持久化
+	Nonce string `json:"-"`
+	// 令牌的受众，为空时为客户端 ID
+	Audience string `json:",omitempty"`
+	// 令牌交换签发的令牌中代表用户调用下游服务的一方
+	Actor *Actor `json:",omitempty"`
+	// 令牌交换签发的令牌记录用户最初授权的客户端，按该客户端吊销时一并失效
+	OriginClientId string `json:",omitempty"`
+	// 令牌交换签发的令牌不能晚于原令牌过期，只作用于本次签发，不持久化
+	NotAfter *time.Time `json:"-"`
+}
+
+// Actor RFC 8693 4.1 节的 act 声明，多次交换时外层为最近一次的调用方，内层为之前的调用方
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// Depth 返回 act 声明嵌套的层数
+func (actor *Actor) Depth() int {
+	depth := 0
+	for ; actor != nil; actor = actor.Actor {
+		depth++
+	}
+	return depth
+}
+
+// ClientIds 返回令牌关联的客户端，令牌交换签发的令牌还包括最初授权的客户端
+func (oauth2Details *OAuth2Details) ClientIds() []string {
+	clientIds := []string{oauth2Details.Client.ClientId}
+	if oauth2Details.OriginClientId != "" && oauth2Details.OriginClientId != oauth2Details.Client.ClientId {
+		clientIds = append(clientIds, oauth2Details.OriginClientId)
+	}
+	return clientIds
+}
+
+// HasScope 判断本次授权是否包含该权限范围
+func (oauth2Details *OAuth2Details) HasScope(scope string) bool {
+	for _, s := range oauth2Details.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIntendedFor 判断令牌能否被该服务接受，未限定受众的令牌可以被任意服务接受
+func (oauth2Details *OAuth2Details) IsIntendedFor(audience string) bool {
+	return oauth2Details.Audience == "" || oauth2Details.Audience == audience
 }
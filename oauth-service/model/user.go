@@ -11,3 +11,13 @@ type UserDetails struct {
 func (userDetail *UserDetails) IsMatch(username string, password string) bool {
 	return userDetail.Username == username && userDetail.Password == password
 }
+
+// HasAuthority 判断用户是否具备该权限
+func (userDetail *UserDetails) HasAuthority(authority string) bool {
+	for _, a := range userDetail.Authorities {
+		if a == authority {
+			return true
+		}
+	}
+	return false
+}
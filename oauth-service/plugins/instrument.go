@@ -1,18 +1,126 @@
 package plugins
 
 import (
+	"SecondKill/oauth-service/endpoint"
+	"SecondKill/oauth-service/model"
+	"SecondKill/oauth-service/service"
 	"context"
 	"errors"
-	"github.com/go-kit/kit/endpoint"
+	kitendpoint "github.com/go-kit/kit/endpoint"
 	"golang.org/x/time/rate"
+	"math"
+	"strings"
+	"sync"
+	"time"
 )
 
 var ErrLimitExceed = errors.New("Rate limit exceed!")
 
-func NewTokenBucketLimitterWithBuildIn(bkt *rate.Limiter) endpoint.Middleware {
-	return func(next endpoint.Endpoint) endpoint.Endpoint {
+// RateLimit 每秒生成 Rate 个令牌，桶容量为 Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// 令牌桶超过该数量时清理已经回满的令牌桶，按 IP 限流时桶的数量随来源增长
+const maxRateLimiters = 100000
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	// 令牌桶从空到满需要的时间，超过该时间未使用的桶与新建的桶等价
+	refill time.Duration
+}
+
+// RateLimiter 按端点和 client_id 分别维护令牌桶，避免某个端点或客户端的流量挤占其他请求
+type RateLimiter struct {
+	mutex          sync.Mutex
+	limiters       map[string]*rateLimiterEntry
+	defaultLimit   RateLimit
+	endpointLimits map[string]RateLimit
+	clientLimits   map[string]RateLimit
+}
+
+// NewRateLimiter 客户端的限制优先于端点的限制，都未配置时使用 defaultLimit
+func NewRateLimiter(defaultLimit RateLimit, endpointLimits map[string]RateLimit, clientLimits map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limiters:       make(map[string]*rateLimiterEntry),
+		defaultLimit:   defaultLimit,
+		endpointLimits: endpointLimits,
+		clientLimits:   clientLimits,
+	}
+}
+
+// Allow clientId 为空的匿名请求按来源 IP 分别使用端点的令牌桶，避免单个来源耗尽整个端点的配额
+func (limiter *RateLimiter) Allow(endpointName string, clientId string, clientIp string) bool {
+	key := endpointName + "|client:" + clientId
+	if clientId == "" {
+		key = endpointName + "|ip:" + clientIp
+	}
+	now := time.Now()
+	limiter.mutex.Lock()
+	entry, ok := limiter.limiters[key]
+	if !ok {
+		if len(limiter.limiters) >= maxRateLimiters {
+			limiter.removeRefilled(now)
+		}
+		limit := limiter.limitFor(endpointName, clientId)
+		entry = &rateLimiterEntry{
+			limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+			refill:  refillDuration(limit),
+		}
+		limiter.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	limiter.mutex.Unlock()
+	return entry.limiter.AllowN(now, 1)
+}
+
+func (limiter *RateLimiter) removeRefilled(now time.Time) {
+	for key, entry := range limiter.limiters {
+		if now.Sub(entry.lastSeen) >= entry.refill {
+			delete(limiter.limiters, key)
+		}
+	}
+}
+
+func refillDuration(limit RateLimit) time.Duration {
+	if limit.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+}
+
+func (limiter *RateLimiter) limitFor(endpointName string, clientId string) RateLimit {
+	limit := limiter.defaultLimit
+	if endpointLimit, ok := limiter.endpointLimits[endpointName]; ok {
+		limit = endpointLimit
+	}
+	// 配置文件的 key 不区分大小写
+	if clientLimit, ok := limiter.clientLimits[strings.ToLower(clientId)]; ok && clientId != "" {
+		limit = clientLimit
+	}
+	if limit.Burst < 1 {
+		limit.Burst = int(limit.Rate)
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+	}
+	return limit
+}
+
+// NewRateLimitMiddleware 放在客户端认证之外执行，被限流的请求不会计算密钥哈希。
+// 携带客户端凭证的请求按 client_id 限流，其余请求按 transport 层解析出的终端用户 IP 限流
+func NewRateLimitMiddleware(limiter *RateLimiter, endpointName string) kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-			if !bkt.Allow() {
+			var clientId string
+			if clientDetails, ok := ctx.Value(endpoint.OAuth2ClientDetailsKey).(*model.ClientDetails); ok {
+				clientId = clientDetails.ClientId
+			} else if credentials, ok := ctx.Value(endpoint.OAuth2ClientCredentialsKey).(*endpoint.ClientCredentials); ok {
+				clientId = credentials.ClientId
+			}
+			if !limiter.Allow(endpointName, clientId, service.ClientIpFromContext(ctx)) {
 				return nil, ErrLimitExceed
 			}
 			return next(ctx, request)
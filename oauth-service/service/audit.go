@@ -0,0 +1,50 @@
+package service
+
+import (
+	"github.com/go-kit/kit/log"
+	"time"
+)
+
+const (
+	// 已轮换的刷新令牌被再次使用，整个令牌家族已被吊销
+	AuditRefreshTokenReused = "refresh_token_reused"
+	// 管理员吊销了用户的全部令牌，ClientId 不为空时只吊销该客户端的令牌
+	AuditUserTokensRevoked = "user_tokens_revoked"
+	// 客户端被停用，其已签发的令牌全部失效
+	AuditClientTokensRevoked = "client_tokens_revoked"
+)
+
+// AuditEvent 令牌相关的安全审计事件
+type AuditEvent struct {
+	Type     string
+	ClientId string
+	// 客户端模式的令牌没有用户
+	UserId   int64
+	FamilyId string
+	Time     time.Time
+}
+
+type AuditLogger interface {
+	Audit(event *AuditEvent)
+}
+
+// LogAuditLogger 将审计事件写入日志，可替换为写入消息队列或数据库的实现
+type LogAuditLogger struct {
+	logger log.Logger
+}
+
+func NewLogAuditLogger(logger log.Logger) AuditLogger {
+	return &LogAuditLogger{
+		logger: logger,
+	}
+}
+
+func (auditLogger *LogAuditLogger) Audit(event *AuditEvent) {
+	auditLogger.logger.Log(
+		"audit", event.Type,
+		"client_id", event.ClientId,
+		"user_id", event.UserId,
+		"family_id", event.FamilyId,
+		"time", event.Time.Format(time.RFC3339),
+	)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"net/http"
+	"time"
+)
+
+const (
+	authorizationCodeKeyPrefix = "oauth:code:"
+	// 授权码有效时间，RFC 6749 建议不超过 10 分钟
+	authorizationCodeValidity = 5 * time.Minute
+	// 只支持 RFC 7636 的 S256 方式，plain 方式无法防止授权码被截获后使用
+	CodeChallengeMethodS256 = "S256"
+)
+
+var (
+	ErrInvalidAuthorizationCode = NewOAuth2Error(ErrorCodeInvalidGrant, "invalid authorization code")
+	ErrMissingAuthorizationCode = NewOAuth2Error(ErrorCodeInvalidRequest, "code is required")
+	ErrRedirectUriMismatch      = NewOAuth2Error(ErrorCodeInvalidGrant, "redirect_uri does not match the authorization request")
+	ErrCodeChallengeRequired    = NewOAuth2Error(ErrorCodeInvalidRequest, "public clients must use PKCE with the S256 code challenge method")
+	ErrCodeChallengeMethod      = NewOAuth2Error(ErrorCodeInvalidRequest, "code challenge method must be S256")
+	ErrInvalidCodeVerifier      = NewOAuth2Error(ErrorCodeInvalidGrant, "code verifier does not match the code challenge")
+)
+
+type AuthorizationCodeService interface {
+	// 为已登录的用户签发授权码，code 中需填写客户端、重定向地址、用户和授权范围
+	CreateAuthorizationCode(code *model.AuthorizationCode) (*model.AuthorizationCode, error)
+	// 兑换授权码，每个授权码只能使用一次
+	ConsumeAuthorizationCode(code string) (*model.AuthorizationCode, error)
+}
+
+type RedisAuthorizationCodeService struct {
+	client *redis.Client
+}
+
+func NewRedisAuthorizationCodeService(client *redis.Client) AuthorizationCodeService {
+	return &RedisAuthorizationCodeService{
+		client: client,
+	}
+}
+
+func (codeService *RedisAuthorizationCodeService) CreateAuthorizationCode(code *model.AuthorizationCode) (*model.AuthorizationCode, error) {
+	codeValue, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	userDetails := *code.User
+	userDetails.Password = ""
+	expiredTime := time.Now().Add(authorizationCodeValidity)
+	code.Code = codeValue
+	code.User = &userDetails
+	code.ExpriesTime = &expiredTime
+	data, err := json.Marshal(code)
+	if err != nil {
+		return nil, err
+	}
+	if err = codeService.client.Set(authorizationCodeKeyPrefix+codeValue, data, authorizationCodeValidity).Err(); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func (codeService *RedisAuthorizationCodeService) ConsumeAuthorizationCode(codeValue string) (*model.AuthorizationCode, error) {
+	key := authorizationCodeKeyPrefix + codeValue
+	pipe := codeService.client.TxPipeline()
+	get := pipe.Get(key)
+	pipe.Del(key)
+	if _, err := pipe.Exec(); err != nil {
+		if err == redis.Nil {
+			return nil, ErrInvalidAuthorizationCode
+		}
+		return nil, err
+	}
+	data, err := get.Bytes()
+	if err != nil {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	code := &model.AuthorizationCode{}
+	if err = json.Unmarshal(data, code); err != nil {
+		return nil, err
+	}
+	if code.IsExpired() {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	return code, nil
+}
+
+type AuthorizationCodeTokenGranter struct {
+	supportGrantType string
+	codeService      AuthorizationCodeService
+	tokenService     TokenService
+}
+
+func NewAuthorizationCodeTokenGranter(grantType string, codeService AuthorizationCodeService, tokenService TokenService) TokenGranter {
+	return &AuthorizationCodeTokenGranter{
+		supportGrantType: grantType,
+		codeService:      codeService,
+		tokenService:     tokenService,
+	}
+}
+
+func (tokenGranter *AuthorizationCodeTokenGranter) Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error) {
+	if grantType != tokenGranter.supportGrantType {
+		return nil, ErrNotSupportGrantType
+	}
+	codeValue := reader.FormValue("code")
+	if codeValue == "" {
+		return nil, ErrMissingAuthorizationCode
+	}
+	code, err := tokenGranter.codeService.ConsumeAuthorizationCode(codeValue)
+	if err != nil {
+		return nil, err
+	}
+	// 授权码只能由申请它的客户端兑换，且重定向地址必须与授权请求中的一致
+	if code.ClientId != client.ClientId {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	if code.RedirectUri != reader.FormValue("redirect_uri") {
+		return nil, ErrRedirectUriMismatch
+	}
+	if code.CodeChallenge != "" || client.Public {
+		if !verifyCodeChallenge(code.CodeChallenge, reader.FormValue("code_verifier")) {
+			return nil, ErrInvalidCodeVerifier
+		}
+	}
+	return tokenGranter.tokenService.CreateAccessToken(&model.OAuth2Details{
+		Client: client,
+		User:   code.User,
+		Scope:  code.Scope,
+		Nonce:  code.Nonce,
+	})
+}
+
+// ValidateCodeChallenge 校验授权请求中的 PKCE 参数，公开客户端必须提供 code_challenge
+func ValidateCodeChallenge(client *model.ClientDetails, codeChallenge string, codeChallengeMethod string) error {
+	if codeChallenge == "" {
+		if client.Public {
+			return ErrCodeChallengeRequired
+		}
+		return nil
+	}
+	if codeChallengeMethod != CodeChallengeMethodS256 {
+		return ErrCodeChallengeMethod
+	}
+	return nil
+}
+
+// verifyCodeChallenge 按 S256 方式校验 BASE64URL(SHA256(code_verifier)) == code_challenge
+func verifyCodeChallenge(codeChallenge string, codeVerifier string) bool {
+	// RFC 7636 4.1 节，code_verifier 长度为 43 到 128 个字符
+	if codeChallenge == "" || len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(codeChallenge)) == 1
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
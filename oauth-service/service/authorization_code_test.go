@@ -0,0 +1,106 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidateCodeChallenge(t *testing.T) {
+	tests := []struct {
+		name                string
+		public              bool
+		codeChallenge       string
+		codeChallengeMethod string
+		wantErr             error
+	}{
+		{name: "confidential client without PKCE", public: false},
+		{name: "public client without PKCE", public: true, wantErr: ErrCodeChallengeRequired},
+		{name: "public client with S256", public: true, codeChallenge: "challenge", codeChallengeMethod: CodeChallengeMethodS256},
+		{name: "plain method", public: true, codeChallenge: "challenge", codeChallengeMethod: "plain", wantErr: ErrCodeChallengeMethod},
+		{name: "missing method", public: false, codeChallenge: "challenge", wantErr: ErrCodeChallengeMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient("app", tt.public)
+			if err := ValidateCodeChallenge(client, tt.codeChallenge, tt.codeChallengeMethod); err != tt.wantErr {
+				t.Fatalf("ValidateCodeChallenge() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodeTokenGranter(t *testing.T) {
+	codeVerifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	tests := []struct {
+		name          string
+		public        bool
+		codeChallenge string
+		// 兑换授权码的客户端，为空时为申请授权码的客户端
+		exchangeClientId string
+		redirectUri      string
+		codeVerifier     string
+		wantErr          error
+	}{
+		{name: "registered redirect uri", redirectUri: "https://app.example.com/callback"},
+		{name: "public client with verifier", public: true, codeChallenge: codeChallenge, redirectUri: "https://app.example.com/callback", codeVerifier: codeVerifier},
+		{name: "public client without verifier", public: true, codeChallenge: codeChallenge, redirectUri: "https://app.example.com/callback", wantErr: ErrInvalidCodeVerifier},
+		{name: "wrong verifier", public: true, codeChallenge: codeChallenge, redirectUri: "https://app.example.com/callback", codeVerifier: strings.Repeat("w", 43), wantErr: ErrInvalidCodeVerifier},
+		{name: "short verifier", codeChallenge: codeChallenge, redirectUri: "https://app.example.com/callback", codeVerifier: "short", wantErr: ErrInvalidCodeVerifier},
+		{name: "public client code without challenge", public: true, redirectUri: "https://app.example.com/callback", codeVerifier: codeVerifier, wantErr: ErrInvalidCodeVerifier},
+		{name: "redirect uri mismatch", redirectUri: "https://evil.example.com/callback", wantErr: ErrRedirectUriMismatch},
+		{name: "code issued to another client", exchangeClientId: "other", redirectUri: "https://app.example.com/callback", wantErr: ErrInvalidAuthorizationCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, _ := newTestTokenService()
+			codeService := newMemoryAuthorizationCodeService()
+			granter := NewAuthorizationCodeTokenGranter("authorization_code", codeService, tokenService)
+			client := newTestClient("app", tt.public, "read")
+			code, err := codeService.CreateAuthorizationCode(&model.AuthorizationCode{
+				ClientId:            client.ClientId,
+				RedirectUri:         client.RegisteredRedirectUri,
+				User:                newTestUser(1, "alice"),
+				Scope:               []string{"read"},
+				CodeChallenge:       tt.codeChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			})
+			if err != nil {
+				t.Fatalf("CreateAuthorizationCode() err = %v", err)
+			}
+			exchangeClient := client
+			if tt.exchangeClientId != "" {
+				exchangeClient = newTestClient(tt.exchangeClientId, tt.public, "read")
+			}
+			form := url.Values{
+				"code":          {code.Code},
+				"redirect_uri":  {tt.redirectUri},
+				"code_verifier": {tt.codeVerifier},
+			}
+			accessToken, err := granter.Grant(context.Background(), "authorization_code", exchangeClient, newFormRequest(form))
+			if err != tt.wantErr {
+				t.Fatalf("Grant() err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && accessToken.TokenValue == "" {
+				t.Fatal("Grant() returned an empty access token")
+			}
+			// 授权码只能兑换一次，无论上次兑换是否成功
+			if _, err = granter.Grant(context.Background(), "authorization_code", exchangeClient, newFormRequest(form)); err != ErrInvalidAuthorizationCode {
+				t.Fatalf("second Grant() err = %v, want %v", err, ErrInvalidAuthorizationCode)
+			}
+		})
+	}
+}
+
+func newFormRequest(form url.Values) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}
@@ -3,15 +3,42 @@ package service
 import (
 	"SecondKill/oauth-service/model"
 	"context"
-	"errors"
+	"crypto/sha256"
+	"crypto/subtle"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// 调用客户端管理等后台接口的令牌必须具备该范围
+	AdminScope = "admin"
+	// 代表用户调用后台接口时，用户还必须具备该权限
+	AdminAuthority = "ROLE_ADMIN"
 )
 
 var (
-	ErrClientMessage = errors.New("invalid client")
+	ErrClientMessage      = NewOAuth2Error(ErrorCodeInvalidClient, "invalid client")
+	ErrInvalidRedirectUri = NewOAuth2Error(ErrorCodeInvalidRequest, "invalid redirect uri")
+	ErrPublicClientSecret = NewOAuth2Error(ErrorCodeInvalidRequest, "public clients have no secret")
+	ErrClientAlreadyExist = NewOAuth2Error(ErrorCodeInvalidClientMetadata, "client_id is already registered")
+	ErrClientDisabled     = NewOAuth2Error(ErrorCodeInvalidClient, "client is disabled")
+	ErrAdminRequired      = NewOAuth2Error(ErrorCodeInsufficientScope, "access token does not grant admin access")
 )
 
 type ClientDetailsService interface {
 	GetClientDetailByClientId(ctx context.Context, clientId string, clientSecret string) (*model.ClientDetails, error)
+	// 根据clientId获取客户端信息，不校验密钥，用于授权端点
+	GetClientDetailById(ctx context.Context, clientId string) (*model.ClientDetails, error)
+	// 生成新的客户端密钥并返回明文，旧密钥在 gracePeriod 内仍然可用
+	RotateClientSecret(ctx context.Context, clientId string, gracePeriod time.Duration) (string, *time.Time, error)
+	// 注册客户端，未指定密钥的非公开客户端自动生成密钥，返回密钥明文
+	CreateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) (string, error)
+	// 修改客户端的令牌有效期、重定向地址、授权类型和权限范围
+	UpdateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) error
+	// 停用客户端，停用后无法再通过认证
+	DisableClientDetails(ctx context.Context, clientId string) error
+	ListClientDetails(ctx context.Context, offset int, limit int) ([]*model.ClientDetails, error)
 }
 
 type MysqlClientDetailsService struct{}
@@ -24,7 +51,11 @@ func (MysqlClientDetailsService) GetClientDetailByClientId(ctx context.Context,
 
 	clientDetailsModel := model.NewClientDetailsModel()
 	if clientDetails, err := clientDetailsModel.GetClientDetailsByClientId(clientId); err == nil {
-		if clientSecret == clientDetails.ClientSecret {
+		if clientDetails.Disabled {
+			return nil, ErrClientDisabled
+		}
+		if clientDetails.IsMatch(clientId, clientSecret) {
+			upgradeClientSecret(clientDetailsModel, clientDetails, clientSecret)
 			return clientDetails, nil
 		} else {
 			return nil, ErrClientMessage
@@ -33,3 +64,218 @@ func (MysqlClientDetailsService) GetClientDetailByClientId(ctx context.Context,
 		return nil, err
 	}
 }
+
+// upgradeClientSecret 历史数据中的明文密钥在校验通过后转换为哈希存储
+func upgradeClientSecret(clientDetailsModel *model.ClientDetailsModel, clientDetails *model.ClientDetails, clientSecret string) {
+	if clientDetails.IsSecretHashed() || clientDetails.ClientSecret != clientSecret {
+		return
+	}
+	secretHash, err := model.HashClientSecret(clientSecret)
+	if err != nil {
+		log.Printf("hash client secret err : %v", err)
+		return
+	}
+	if err = clientDetailsModel.UpdateClientSecret(clientDetails.ClientId, secretHash,
+		clientDetails.PreviousClientSecret, clientDetails.PreviousSecretExpiresAt); err == nil {
+		clientDetails.ClientSecret = secretHash
+	}
+}
+
+func (MysqlClientDetailsService) RotateClientSecret(ctx context.Context, clientId string, gracePeriod time.Duration) (string, *time.Time, error) {
+	clientDetailsModel := model.NewClientDetailsModel()
+	clientDetails, err := clientDetailsModel.GetClientDetailsByClientId(clientId)
+	if err != nil {
+		return "", nil, err
+	}
+	if clientDetails.Public {
+		return "", nil, ErrPublicClientSecret
+	}
+	previousClientSecret := clientDetails.ClientSecret
+	if previousClientSecret != "" && !clientDetails.IsSecretHashed() {
+		if previousClientSecret, err = model.HashClientSecret(previousClientSecret); err != nil {
+			return "", nil, err
+		}
+	}
+	clientSecret, err := randomString(32)
+	if err != nil {
+		return "", nil, err
+	}
+	secretHash, err := model.HashClientSecret(clientSecret)
+	if err != nil {
+		return "", nil, err
+	}
+	expiresTime := time.Now().Add(gracePeriod)
+	if err = clientDetailsModel.UpdateClientSecret(clientId, secretHash, previousClientSecret, &expiresTime); err != nil {
+		return "", nil, err
+	}
+	return clientSecret, &expiresTime, nil
+}
+
+func (MysqlClientDetailsService) GetClientDetailById(ctx context.Context, clientId string) (*model.ClientDetails, error) {
+	clientDetailsModel := model.NewClientDetailsModel()
+	clientDetails, err := clientDetailsModel.GetClientDetailsByClientId(clientId)
+	if err != nil {
+		return nil, err
+	}
+	if clientDetails.Disabled {
+		return nil, ErrClientDisabled
+	}
+	return clientDetails, nil
+}
+
+func (MysqlClientDetailsService) CreateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) (string, error) {
+	if err := validateClientDetails(clientDetails); err != nil {
+		return "", err
+	}
+	clientDetailsModel := model.NewClientDetailsModel()
+	if _, err := clientDetailsModel.GetClientDetailsByClientId(clientDetails.ClientId); err == nil {
+		return "", ErrClientAlreadyExist
+	} else if err != model.ErrClientNotFound {
+		return "", err
+	}
+	clientSecret := clientDetails.ClientSecret
+	if clientDetails.Public {
+		clientSecret = ""
+	} else if clientSecret == "" {
+		var err error
+		if clientSecret, err = randomString(32); err != nil {
+			return "", err
+		}
+	}
+	clientDetails.ClientSecret = clientSecret
+	clientDetails.Disabled = false
+	if err := clientDetailsModel.CreateClientDetails(clientDetails); err != nil {
+		return "", err
+	}
+	return clientSecret, nil
+}
+
+func (MysqlClientDetailsService) UpdateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) error {
+	if err := validateClientDetails(clientDetails); err != nil {
+		return err
+	}
+	clientDetailsModel := model.NewClientDetailsModel()
+	if _, err := clientDetailsModel.GetClientDetailsByClientId(clientDetails.ClientId); err != nil {
+		return err
+	}
+	return clientDetailsModel.UpdateClientDetails(clientDetails)
+}
+
+func (MysqlClientDetailsService) DisableClientDetails(ctx context.Context, clientId string) error {
+	clientDetailsModel := model.NewClientDetailsModel()
+	if _, err := clientDetailsModel.GetClientDetailsByClientId(clientId); err != nil {
+		return err
+	}
+	return clientDetailsModel.SetClientDisabled(clientId, true)
+}
+
+func (MysqlClientDetailsService) ListClientDetails(ctx context.Context, offset int, limit int) ([]*model.ClientDetails, error) {
+	return model.NewClientDetailsModel().ListClientDetails(offset, limit)
+}
+
+// 缓存的客户端认证结果超过该数量时全部清空
+const maxCachedClientAuthentications = 10000
+
+type cachedClientAuthentication struct {
+	secretDigest  [sha256.Size]byte
+	clientDetails *model.ClientDetails
+	expiresAt     time.Time
+}
+
+// CachingClientDetailsService 短时间缓存认证成功的客户端，网关等机器客户端频繁校验令牌时不必每次计算 bcrypt，
+// 本实例修改、停用客户端或轮换密钥时立即清除缓存，其他实例最多在 ttl 后生效
+type CachingClientDetailsService struct {
+	ClientDetailsService
+	ttl    time.Duration
+	mutex  sync.Mutex
+	caches map[string]*cachedClientAuthentication
+}
+
+func NewCachingClientDetailsService(next ClientDetailsService, ttl time.Duration) ClientDetailsService {
+	return &CachingClientDetailsService{
+		ClientDetailsService: next,
+		ttl:                  ttl,
+		caches:               make(map[string]*cachedClientAuthentication),
+	}
+}
+
+func (service *CachingClientDetailsService) GetClientDetailByClientId(ctx context.Context, clientId string, clientSecret string) (*model.ClientDetails, error) {
+	secretDigest := sha256.Sum256([]byte(clientSecret))
+	service.mutex.Lock()
+	cached, ok := service.caches[clientId]
+	service.mutex.Unlock()
+	if ok && cached.expiresAt.After(time.Now()) &&
+		subtle.ConstantTimeCompare(cached.secretDigest[:], secretDigest[:]) == 1 {
+		return cached.clientDetails, nil
+	}
+	clientDetails, err := service.ClientDetailsService.GetClientDetailByClientId(ctx, clientId, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	service.mutex.Lock()
+	if len(service.caches) >= maxCachedClientAuthentications {
+		service.caches = make(map[string]*cachedClientAuthentication)
+	}
+	service.caches[clientId] = &cachedClientAuthentication{
+		secretDigest:  secretDigest,
+		clientDetails: clientDetails,
+		expiresAt:     time.Now().Add(service.ttl),
+	}
+	service.mutex.Unlock()
+	return clientDetails, nil
+}
+
+func (service *CachingClientDetailsService) RotateClientSecret(ctx context.Context, clientId string, gracePeriod time.Duration) (string, *time.Time, error) {
+	defer service.evict(clientId)
+	return service.ClientDetailsService.RotateClientSecret(ctx, clientId, gracePeriod)
+}
+
+func (service *CachingClientDetailsService) UpdateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) error {
+	defer service.evict(clientDetails.ClientId)
+	return service.ClientDetailsService.UpdateClientDetails(ctx, clientDetails)
+}
+
+func (service *CachingClientDetailsService) DisableClientDetails(ctx context.Context, clientId string) error {
+	defer service.evict(clientId)
+	return service.ClientDetailsService.DisableClientDetails(ctx, clientId)
+}
+
+func (service *CachingClientDetailsService) evict(clientId string) {
+	service.mutex.Lock()
+	delete(service.caches, clientId)
+	service.mutex.Unlock()
+}
+
+// validateClientDetails 校验注册或修改客户端时提交的信息
+func validateClientDetails(clientDetails *model.ClientDetails) error {
+	if clientDetails.ClientId == "" {
+		return NewOAuth2Error(ErrorCodeInvalidClientMetadata, "client_id is required")
+	}
+	if clientDetails.AccessTokenValiditySeconds <= 0 || clientDetails.RefreshTokenValiditySeconds < 0 {
+		return NewOAuth2Error(ErrorCodeInvalidClientMetadata, "token validity seconds must be positive")
+	}
+	if len(clientDetails.AuthorizedGrantTypes) == 0 {
+		return NewOAuth2Error(ErrorCodeInvalidClientMetadata, "authorized_grant_types is required")
+	}
+	if clientDetails.IsGrantTypeAuthorized("authorization_code") && clientDetails.RegisteredRedirectUri == "" {
+		return NewOAuth2Error(ErrorCodeInvalidRedirectUri, "registered_redirect_uri is required for authorization_code")
+	}
+	if clientDetails.Public && clientDetails.IsGrantTypeAuthorized("client_credentials") {
+		return NewOAuth2Error(ErrorCodeInvalidClientMetadata, "public clients cannot use client_credentials")
+	}
+	return nil
+}
+
+// ResolveRedirectUri 校验请求中的重定向地址必须与客户端注册的地址一致，未传时使用注册地址
+func ResolveRedirectUri(client *model.ClientDetails, redirectUri string) (string, error) {
+	if client.RegisteredRedirectUri == "" {
+		return "", ErrInvalidRedirectUri
+	}
+	if redirectUri == "" {
+		return client.RegisteredRedirectUri, nil
+	}
+	if redirectUri != client.RegisteredRedirectUri {
+		return "", ErrInvalidRedirectUri
+	}
+	return redirectUri, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientSecretMatch(t *testing.T) {
+	secretHash, err := model.HashClientSecret("secret")
+	if err != nil {
+		t.Fatalf("HashClientSecret() err = %v", err)
+	}
+	previousHash, err := model.HashClientSecret("previous")
+	if err != nil {
+		t.Fatalf("HashClientSecret() err = %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name         string
+		client       *model.ClientDetails
+		clientSecret string
+		want         bool
+		wantHashed   bool
+	}{
+		{name: "hashed secret", client: &model.ClientDetails{ClientId: "app", ClientSecret: secretHash}, clientSecret: "secret", want: true, wantHashed: true},
+		{name: "wrong secret", client: &model.ClientDetails{ClientId: "app", ClientSecret: secretHash}, clientSecret: "wrong", wantHashed: true},
+		{name: "legacy plaintext secret", client: &model.ClientDetails{ClientId: "app", ClientSecret: "secret"}, clientSecret: "secret", want: true},
+		{name: "empty secret never matches", client: &model.ClientDetails{ClientId: "app", ClientSecret: ""}, clientSecret: ""},
+		{
+			name:         "previous secret within grace period",
+			client:       &model.ClientDetails{ClientId: "app", ClientSecret: secretHash, PreviousClientSecret: previousHash, PreviousSecretExpiresAt: &future},
+			clientSecret: "previous", want: true, wantHashed: true,
+		},
+		{
+			name:         "previous secret after grace period",
+			client:       &model.ClientDetails{ClientId: "app", ClientSecret: secretHash, PreviousClientSecret: previousHash, PreviousSecretExpiresAt: &past},
+			clientSecret: "previous", wantHashed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match := tt.client.IsMatch("app", tt.clientSecret); match != tt.want {
+				t.Fatalf("IsMatch() = %v, want %v", match, tt.want)
+			}
+			if hashed := tt.client.IsSecretHashed(); hashed != tt.wantHashed {
+				t.Fatalf("IsSecretHashed() = %v, want %v", hashed, tt.wantHashed)
+			}
+		})
+	}
+}
+
+func TestCachingClientDetailsService(t *testing.T) {
+	tests := []struct {
+		name string
+		// run 返回最后一次认证的错误
+		run                 func(t *testing.T, clientService ClientDetailsService, secret string) error
+		wantErr             error
+		wantAuthentications int
+	}{
+		{
+			name: "repeated authentication is cached",
+			run: func(t *testing.T, clientService ClientDetailsService, secret string) error {
+				clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				_, err := clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				return err
+			},
+			wantAuthentications: 1,
+		},
+		{
+			name: "wrong secret is not served from the cache",
+			run: func(t *testing.T, clientService ClientDetailsService, secret string) error {
+				clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				_, err := clientService.GetClientDetailByClientId(context.Background(), "app", "wrong")
+				return err
+			},
+			wantErr:             ErrClientMessage,
+			wantAuthentications: 2,
+		},
+		{
+			name: "rotation evicts the cache",
+			run: func(t *testing.T, clientService ClientDetailsService, secret string) error {
+				clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				newSecret, _, err := clientService.RotateClientSecret(context.Background(), "app", time.Hour)
+				if err != nil {
+					return err
+				}
+				_, err = clientService.GetClientDetailByClientId(context.Background(), "app", newSecret)
+				return err
+			},
+			wantAuthentications: 2,
+		},
+		{
+			name: "disabled client is rejected at once",
+			run: func(t *testing.T, clientService ClientDetailsService, secret string) error {
+				clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				if err := clientService.DisableClientDetails(context.Background(), "app"); err != nil {
+					return err
+				}
+				_, err := clientService.GetClientDetailByClientId(context.Background(), "app", secret)
+				return err
+			},
+			wantErr:             ErrClientDisabled,
+			wantAuthentications: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretHash, err := model.HashClientSecret("secret")
+			if err != nil {
+				t.Fatalf("HashClientSecret() err = %v", err)
+			}
+			client := newTestClient("app", false, "read")
+			client.ClientSecret = secretHash
+			next := newMemoryClientDetailsService(client)
+			clientService := NewCachingClientDetailsService(next, time.Minute)
+			if err = tt.run(t, clientService, "secret"); err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if next.authentications != tt.wantAuthentications {
+				t.Fatalf("authentications = %d, want %d", next.authentications, tt.wantAuthentications)
+			}
+		})
+	}
+}
+
+func TestValidateClientDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(client *model.ClientDetails)
+		wantErr bool
+	}{
+		{name: "valid client", modify: func(client *model.ClientDetails) {}},
+		{name: "missing client id", modify: func(client *model.ClientDetails) { client.ClientId = "" }, wantErr: true},
+		{name: "non positive access token validity", modify: func(client *model.ClientDetails) { client.AccessTokenValiditySeconds = 0 }, wantErr: true},
+		{name: "authorization code without redirect uri", modify: func(client *model.ClientDetails) { client.RegisteredRedirectUri = "" }, wantErr: true},
+		{
+			name: "public client with client credentials",
+			modify: func(client *model.ClientDetails) {
+				client.Public = true
+				client.AuthorizedGrantTypes = []string{"client_credentials"}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient("app", false, "read")
+			tt.modify(client)
+			if err := validateClientDetails(client); (err != nil) != tt.wantErr {
+				t.Fatalf("validateClientDetails() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
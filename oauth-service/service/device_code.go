@@ -0,0 +1,365 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// 设备码有效时间，用户需在此期间完成授权
+	deviceCodeValidity = 10 * time.Minute
+	// 过期后继续保留一段时间，设备轮询时返回 expired_token 而不是 invalid_grant
+	deviceCodeRetention = 10 * time.Minute
+	// RFC 8628 3.2 节默认的轮询间隔，收到 slow_down 后增加 5 秒
+	defaultDevicePollInterval = 5
+	devicePollIntervalStep    = 5
+	// RFC 8628 6.1 节建议的字符集，去掉元音避免组成单词，8 位约 34 bit 熵
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+	// 生成的用户码与未过期的用户码冲突时重新生成的次数
+	maxUserCodeAttempts = 5
+	// 内存存储的设备码超过该数量时清理已过期的条目
+	maxMemoryDeviceCodes = 100000
+)
+
+var (
+	ErrMissingDeviceCode    = NewOAuth2Error(ErrorCodeInvalidRequest, "device_code is required")
+	ErrInvalidDeviceCode    = NewOAuth2Error(ErrorCodeInvalidGrant, "invalid device code")
+	ErrAuthorizationPending = NewOAuth2Error(ErrorCodeAuthorizationPending, "the user has not yet completed the authorization")
+	ErrSlowDown             = NewOAuth2Error(ErrorCodeSlowDown, "polling too frequently, increase the interval by 5 seconds")
+	ErrDeviceAccessDenied   = NewOAuth2Error(ErrorCodeAccessDenied, "the user denied the authorization request")
+	ErrExpiredDeviceCode    = NewOAuth2Error(ErrorCodeExpiredToken, "device code is expired")
+	ErrInvalidUserCode      = errors.New("invalid or expired user code")
+	ErrUserCodeConflict     = errors.New("user code already exists")
+)
+
+// DeviceCodeStore 保存等待用户授权的设备码，可按设备码和用户码查询
+type DeviceCodeStore interface {
+	// Save 保存新签发的设备码，用户码已被占用时返回 ErrUserCodeConflict
+	Save(code *model.DeviceCode) error
+	// ReadByDeviceCode 不存在时返回 ErrInvalidDeviceCode
+	ReadByDeviceCode(deviceCode string) (*model.DeviceCode, error)
+	// ReadByUserCode 不存在时返回 ErrInvalidUserCode
+	ReadByUserCode(userCode string) (*model.DeviceCode, error)
+	// Update 保存用户的授权结果
+	Update(code *model.DeviceCode) error
+	// IncreaseInterval 只增加轮询间隔，不覆盖并发写入的授权结果
+	IncreaseInterval(deviceCode string, step int) error
+	// Remove 删除设备码，返回设备码删除前是否存在，用于保证每个设备码只能兑换一次
+	Remove(code *model.DeviceCode) (bool, error)
+	// MarkPolled 记录一次轮询，距上次轮询不足 interval 时返回 false
+	MarkPolled(deviceCode string, interval time.Duration) (bool, error)
+}
+
+// deviceCodeTTL 存储中的设备码在过期后再保留 deviceCodeRetention
+func deviceCodeTTL(code *model.DeviceCode) time.Duration {
+	if code.ExpriesTime == nil {
+		return deviceCodeValidity + deviceCodeRetention
+	}
+	return time.Until(*code.ExpriesTime) + deviceCodeRetention
+}
+
+// InMemoryDeviceCodeStore 单实例和测试使用
+type InMemoryDeviceCodeStore struct {
+	mutex       sync.Mutex
+	deviceCodes map[string]*model.DeviceCode
+	userCodes   map[string]string
+	polls       map[string]time.Time
+}
+
+func NewInMemoryDeviceCodeStore() *InMemoryDeviceCodeStore {
+	return &InMemoryDeviceCodeStore{
+		deviceCodes: make(map[string]*model.DeviceCode),
+		userCodes:   make(map[string]string),
+		polls:       make(map[string]time.Time),
+	}
+}
+
+func (store *InMemoryDeviceCodeStore) Save(code *model.DeviceCode) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if len(store.deviceCodes) > maxMemoryDeviceCodes {
+		store.removeExpired(time.Now())
+	}
+	if _, ok := store.userCodes[code.UserCode]; ok {
+		return ErrUserCodeConflict
+	}
+	saved := *code
+	store.deviceCodes[code.DeviceCode] = &saved
+	store.userCodes[code.UserCode] = code.DeviceCode
+	return nil
+}
+
+func (store *InMemoryDeviceCodeStore) ReadByDeviceCode(deviceCode string) (*model.DeviceCode, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	code, ok := store.deviceCodes[deviceCode]
+	if !ok || deviceCodeTTL(code) <= 0 {
+		return nil, ErrInvalidDeviceCode
+	}
+	saved := *code
+	return &saved, nil
+}
+
+func (store *InMemoryDeviceCodeStore) ReadByUserCode(userCode string) (*model.DeviceCode, error) {
+	store.mutex.Lock()
+	deviceCode, ok := store.userCodes[userCode]
+	store.mutex.Unlock()
+	if !ok {
+		return nil, ErrInvalidUserCode
+	}
+	code, err := store.ReadByDeviceCode(deviceCode)
+	if err != nil {
+		return nil, ErrInvalidUserCode
+	}
+	return code, nil
+}
+
+func (store *InMemoryDeviceCodeStore) Update(code *model.DeviceCode) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.deviceCodes[code.DeviceCode]; !ok {
+		return ErrInvalidDeviceCode
+	}
+	saved := *code
+	store.deviceCodes[code.DeviceCode] = &saved
+	return nil
+}
+
+func (store *InMemoryDeviceCodeStore) IncreaseInterval(deviceCode string, step int) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	code, ok := store.deviceCodes[deviceCode]
+	if !ok {
+		return ErrInvalidDeviceCode
+	}
+	code.Interval += step
+	return nil
+}
+
+func (store *InMemoryDeviceCodeStore) Remove(code *model.DeviceCode) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	_, ok := store.deviceCodes[code.DeviceCode]
+	delete(store.deviceCodes, code.DeviceCode)
+	delete(store.userCodes, code.UserCode)
+	delete(store.polls, code.DeviceCode)
+	return ok, nil
+}
+
+func (store *InMemoryDeviceCodeStore) MarkPolled(deviceCode string, interval time.Duration) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	if lastPolled, ok := store.polls[deviceCode]; ok && now.Sub(lastPolled) < interval {
+		return false, nil
+	}
+	store.polls[deviceCode] = now
+	return true, nil
+}
+
+func (store *InMemoryDeviceCodeStore) removeExpired(now time.Time) {
+	for deviceCode, code := range store.deviceCodes {
+		if code.ExpriesTime != nil && code.ExpriesTime.Add(deviceCodeRetention).Before(now) {
+			delete(store.deviceCodes, deviceCode)
+			delete(store.userCodes, code.UserCode)
+			delete(store.polls, deviceCode)
+		}
+	}
+}
+
+// DeviceAuthorizationService 签发设备码，并记录用户在验证页上的授权结果
+type DeviceAuthorizationService struct {
+	store DeviceCodeStore
+}
+
+func NewDeviceAuthorizationService(store DeviceCodeStore) *DeviceAuthorizationService {
+	return &DeviceAuthorizationService{
+		store: store,
+	}
+}
+
+// CreateDeviceCode 为客户端签发设备码和用户码
+func (deviceService *DeviceAuthorizationService) CreateDeviceCode(client *model.ClientDetails, scope []string) (*model.DeviceCode, error) {
+	deviceCodeValue, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	expiredTime := time.Now().Add(deviceCodeValidity)
+	code := &model.DeviceCode{
+		DeviceCode:  deviceCodeValue,
+		ClientId:    client.ClientId,
+		Scope:       scope,
+		Status:      model.DeviceCodeStatusPending,
+		Interval:    defaultDevicePollInterval,
+		ExpriesTime: &expiredTime,
+	}
+	for i := 0; i < maxUserCodeAttempts; i++ {
+		if code.UserCode, err = randomUserCode(); err != nil {
+			return nil, err
+		}
+		if err = deviceService.store.Save(code); err != ErrUserCodeConflict {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// ReadPendingUserCode 查询等待用户授权的设备码，已过期或已处理时返回 ErrInvalidUserCode
+func (deviceService *DeviceAuthorizationService) ReadPendingUserCode(userCode string) (*model.DeviceCode, error) {
+	code, err := deviceService.store.ReadByUserCode(NormalizeUserCode(userCode))
+	if err != nil {
+		return nil, err
+	}
+	if code.IsExpired() || code.Status != model.DeviceCodeStatusPending {
+		return nil, ErrInvalidUserCode
+	}
+	return code, nil
+}
+
+// Approve 用户批准设备的授权请求
+func (deviceService *DeviceAuthorizationService) Approve(userCode string, user *model.UserDetails) error {
+	code, err := deviceService.ReadPendingUserCode(userCode)
+	if err != nil {
+		return err
+	}
+	userDetails := *user
+	userDetails.Password = ""
+	code.User = &userDetails
+	code.Status = model.DeviceCodeStatusApproved
+	return deviceService.store.Update(code)
+}
+
+// Deny 用户拒绝设备的授权请求
+func (deviceService *DeviceAuthorizationService) Deny(userCode string) error {
+	code, err := deviceService.ReadPendingUserCode(userCode)
+	if err != nil {
+		return err
+	}
+	code.Status = model.DeviceCodeStatusDenied
+	return deviceService.store.Update(code)
+}
+
+// Poll 设备轮询授权结果，用户批准后返回设备码并将其删除，此后再次轮询返回 ErrInvalidDeviceCode
+func (deviceService *DeviceAuthorizationService) Poll(deviceCode string, client *model.ClientDetails) (*model.DeviceCode, error) {
+	code, err := deviceService.store.ReadByDeviceCode(deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	// 设备码只能由申请它的客户端兑换
+	if code.ClientId != client.ClientId {
+		return nil, ErrInvalidDeviceCode
+	}
+	if code.IsExpired() {
+		return nil, ErrExpiredDeviceCode
+	}
+	allowed, err := deviceService.store.MarkPolled(code.DeviceCode, time.Duration(code.Interval)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	switch code.Status {
+	case model.DeviceCodeStatusApproved:
+		removed, err := deviceService.store.Remove(code)
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			return nil, ErrInvalidDeviceCode
+		}
+		return code, nil
+	case model.DeviceCodeStatusDenied:
+		if _, err = deviceService.store.Remove(code); err != nil {
+			return nil, err
+		}
+		return nil, ErrDeviceAccessDenied
+	}
+	if !allowed {
+		if err = deviceService.store.IncreaseInterval(code.DeviceCode, devicePollIntervalStep); err != nil {
+			return nil, err
+		}
+		return nil, ErrSlowDown
+	}
+	return nil, ErrAuthorizationPending
+}
+
+// NormalizeUserCode 用户输入的用户码忽略大小写和分隔符
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, userCode)
+}
+
+// FormatUserCode 以 XXXX-XXXX 的形式展示用户码，便于用户输入
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+func randomUserCode() (string, error) {
+	// 拒绝超出字符集整数倍的随机字节，保证每个字符等概率出现
+	limit := byte(256 - 256%len(userCodeCharset))
+	userCode := make([]byte, 0, userCodeLength)
+	b := make([]byte, userCodeLength*2)
+	for len(userCode) < userCodeLength {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if c < limit && len(userCode) < userCodeLength {
+				userCode = append(userCode, userCodeCharset[int(c)%len(userCodeCharset)])
+			}
+		}
+	}
+	return string(userCode), nil
+}
+
+type DeviceCodeTokenGranter struct {
+	supportGrantType string
+	deviceService    *DeviceAuthorizationService
+	tokenService     TokenService
+}
+
+func NewDeviceCodeTokenGranter(grantType string, deviceService *DeviceAuthorizationService, tokenService TokenService) TokenGranter {
+	return &DeviceCodeTokenGranter{
+		supportGrantType: grantType,
+		deviceService:    deviceService,
+		tokenService:     tokenService,
+	}
+}
+
+func (tokenGranter *DeviceCodeTokenGranter) Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error) {
+	if grantType != tokenGranter.supportGrantType {
+		return nil, ErrNotSupportGrantType
+	}
+	deviceCode := reader.FormValue("device_code")
+	if deviceCode == "" {
+		return nil, ErrMissingDeviceCode
+	}
+	code, err := tokenGranter.deviceService.Poll(deviceCode, client)
+	if err != nil {
+		return nil, err
+	}
+	return tokenGranter.tokenService.CreateAccessToken(&model.OAuth2Details{
+		Client: client,
+		User:   code.User,
+		Scope:  code.Scope,
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"testing"
+	"time"
+)
+
+func TestDeviceAuthorizationServicePoll(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare 在轮询前模拟用户操作或之前的轮询
+		prepare      func(t *testing.T, deviceService *DeviceAuthorizationService, store *InMemoryDeviceCodeStore, code *model.DeviceCode)
+		pollClientId string
+		wantErr      error
+		wantInterval int
+	}{
+		{
+			name:         "pending",
+			wantErr:      ErrAuthorizationPending,
+			wantInterval: defaultDevicePollInterval,
+		},
+		{
+			name: "polling too fast",
+			prepare: func(t *testing.T, deviceService *DeviceAuthorizationService, store *InMemoryDeviceCodeStore, code *model.DeviceCode) {
+				if _, err := deviceService.Poll(code.DeviceCode, newTestClient("tv", true)); err != ErrAuthorizationPending {
+					t.Fatalf("first Poll() err = %v, want %v", err, ErrAuthorizationPending)
+				}
+			},
+			wantErr:      ErrSlowDown,
+			wantInterval: defaultDevicePollInterval + devicePollIntervalStep,
+		},
+		{
+			name: "approved",
+			prepare: func(t *testing.T, deviceService *DeviceAuthorizationService, store *InMemoryDeviceCodeStore, code *model.DeviceCode) {
+				if err := deviceService.Approve(FormatUserCode(code.UserCode), newTestUser(1, "alice")); err != nil {
+					t.Fatalf("Approve() err = %v", err)
+				}
+			},
+		},
+		{
+			name: "denied",
+			prepare: func(t *testing.T, deviceService *DeviceAuthorizationService, store *InMemoryDeviceCodeStore, code *model.DeviceCode) {
+				if err := deviceService.Deny(code.UserCode); err != nil {
+					t.Fatalf("Deny() err = %v", err)
+				}
+			},
+			wantErr: ErrDeviceAccessDenied,
+		},
+		{
+			name:         "polled by another client",
+			pollClientId: "other",
+			wantErr:      ErrInvalidDeviceCode,
+		},
+		{
+			name: "expired",
+			prepare: func(t *testing.T, deviceService *DeviceAuthorizationService, store *InMemoryDeviceCodeStore, code *model.DeviceCode) {
+				expiredTime := time.Now().Add(-time.Second)
+				code.ExpriesTime = &expiredTime
+				if err := store.Update(code); err != nil {
+					t.Fatalf("Update() err = %v", err)
+				}
+			},
+			wantErr: ErrExpiredDeviceCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryDeviceCodeStore()
+			deviceService := NewDeviceAuthorizationService(store)
+			client := newTestClient("tv", true, "read")
+			code, err := deviceService.CreateDeviceCode(client, []string{"read"})
+			if err != nil {
+				t.Fatalf("CreateDeviceCode() err = %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, deviceService, store, code)
+			}
+			pollClient := client
+			if tt.pollClientId != "" {
+				pollClient = newTestClient(tt.pollClientId, true, "read")
+			}
+			approved, err := deviceService.Poll(code.DeviceCode, pollClient)
+			if err != tt.wantErr {
+				t.Fatalf("Poll() err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if approved.User == nil || approved.User.UserId != 1 || approved.User.Password != "" {
+					t.Fatalf("Poll() user = %+v, want user 1 without password", approved.User)
+				}
+				// 批准后的设备码只能兑换一次
+				if _, err = deviceService.Poll(code.DeviceCode, pollClient); err != ErrInvalidDeviceCode {
+					t.Fatalf("second Poll() err = %v, want %v", err, ErrInvalidDeviceCode)
+				}
+			}
+			if tt.wantInterval > 0 {
+				saved, err := store.ReadByDeviceCode(code.DeviceCode)
+				if err != nil {
+					t.Fatalf("ReadByDeviceCode() err = %v", err)
+				}
+				if saved.Interval != tt.wantInterval {
+					t.Fatalf("interval = %d, want %d", saved.Interval, tt.wantInterval)
+				}
+			}
+		})
+	}
+}
+
+// approvingDeviceCodeStore 在轮询读取设备码之后执行 onPoll，模拟用户在设备轮询期间完成授权
+type approvingDeviceCodeStore struct {
+	*InMemoryDeviceCodeStore
+	onPoll func()
+}
+
+func (store *approvingDeviceCodeStore) MarkPolled(deviceCode string, interval time.Duration) (bool, error) {
+	if store.onPoll != nil {
+		store.onPoll()
+	}
+	return store.InMemoryDeviceCodeStore.MarkPolled(deviceCode, interval)
+}
+
+func TestDeviceAuthorizationServiceSlowDownKeepsApproval(t *testing.T) {
+	store := &approvingDeviceCodeStore{InMemoryDeviceCodeStore: NewInMemoryDeviceCodeStore()}
+	deviceService := NewDeviceAuthorizationService(store)
+	client := newTestClient("tv", true, "read")
+	code, err := deviceService.CreateDeviceCode(client, []string{"read"})
+	if err != nil {
+		t.Fatalf("CreateDeviceCode() err = %v", err)
+	}
+	if _, err = deviceService.Poll(code.DeviceCode, client); err != ErrAuthorizationPending {
+		t.Fatalf("first Poll() err = %v, want %v", err, ErrAuthorizationPending)
+	}
+	store.onPoll = func() {
+		store.onPoll = nil
+		if err := deviceService.Approve(code.UserCode, newTestUser(1, "alice")); err != nil {
+			t.Fatalf("Approve() err = %v", err)
+		}
+	}
+	if _, err = deviceService.Poll(code.DeviceCode, client); err != ErrSlowDown {
+		t.Fatalf("second Poll() err = %v, want %v", err, ErrSlowDown)
+	}
+	// 增加轮询间隔不会覆盖并发写入的批准结果
+	if _, err = deviceService.Poll(code.DeviceCode, client); err != nil {
+		t.Fatalf("third Poll() err = %v", err)
+	}
+}
+
+func TestUserCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantCode string
+	}{
+		{name: "formatted", input: "BCDF-GHJK", wantCode: "BCDFGHJK"},
+		{name: "lower case with spaces", input: " bcdf ghjk ", wantCode: "BCDFGHJK"},
+		{name: "digits are dropped", input: "BCDF-1GHJK", wantCode: "BCDFGHJK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if userCode := NormalizeUserCode(tt.input); userCode != tt.wantCode {
+				t.Fatalf("NormalizeUserCode(%q) = %q, want %q", tt.input, userCode, tt.wantCode)
+			}
+		})
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		t.Fatalf("randomUserCode() err = %v", err)
+	}
+	if len(userCode) != userCodeLength || NormalizeUserCode(FormatUserCode(userCode)) != userCode {
+		t.Fatalf("randomUserCode() = %q is not a normalized %d character code", userCode, userCodeLength)
+	}
+}
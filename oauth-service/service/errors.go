@@ -0,0 +1,40 @@
+package service
+
+// RFC 6749 5.2 节、RFC 6750 3.1 节、RFC 7591 3.2.2 节、RFC 8628 3.5 节与 RFC 8693 2.2.2 节定义的错误码
+const (
+	ErrorCodeInvalidRequest          = "invalid_request"
+	ErrorCodeInvalidClient           = "invalid_client"
+	ErrorCodeInvalidGrant            = "invalid_grant"
+	ErrorCodeUnauthorizedClient      = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorCodeInvalidScope            = "invalid_scope"
+	ErrorCodeAccessDenied            = "access_denied"
+	ErrorCodeUnsupportedResponseType = "unsupported_response_type"
+	ErrorCodeServerError             = "server_error"
+	ErrorCodeTemporarilyUnavailable  = "temporarily_unavailable"
+	ErrorCodeInvalidToken            = "invalid_token"
+	ErrorCodeInsufficientScope       = "insufficient_scope"
+	ErrorCodeInvalidClientMetadata   = "invalid_client_metadata"
+	ErrorCodeInvalidRedirectUri      = "invalid_redirect_uri"
+	ErrorCodeAuthorizationPending    = "authorization_pending"
+	ErrorCodeSlowDown                = "slow_down"
+	ErrorCodeExpiredToken            = "expired_token"
+	ErrorCodeInvalidTarget           = "invalid_target"
+)
+
+// OAuth2Error 按 RFC 6749 5.2 节的格式返回给客户端的错误，transport 层根据错误码决定 HTTP 状态码和 gRPC 状态码
+type OAuth2Error struct {
+	ErrorCode   string
+	Description string
+}
+
+func NewOAuth2Error(errorCode string, description string) *OAuth2Error {
+	return &OAuth2Error{
+		ErrorCode:   errorCode,
+		Description: description,
+	}
+}
+
+func (e *OAuth2Error) Error() string {
+	return e.Description
+}
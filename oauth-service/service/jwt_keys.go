@@ -0,0 +1,111 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+)
+
+var (
+	ErrNotSupportSigningAlg = errors.New("signing algorithm is not supported")
+	ErrUnknownSigningKey    = errors.New("unknown signing key")
+)
+
+// SigningKey JWT 非对称签名密钥，Kid 写入令牌头部，校验时据此选择公钥
+type SigningKey struct {
+	Kid    string
+	Method jwt.SigningMethod
+	// 轮换下线的密钥可以只配置公钥，仅用于校验已签发的令牌
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// LoadSigningKey 从 PEM 文件加载 RS256 或 ES256 密钥，文件中可以是私钥或公钥
+func LoadSigningKey(kid string, alg string, path string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := &SigningKey{
+		Kid:    kid,
+		Method: jwt.GetSigningMethod(alg),
+	}
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		if privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.PrivateKey, key.PublicKey = privateKey, &privateKey.PublicKey
+		} else if key.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, err
+		}
+	case jwt.SigningMethodES256.Alg():
+		if privateKey, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+			key.PrivateKey, key.PublicKey = privateKey, &privateKey.PublicKey
+		} else if key.PublicKey, err = jwt.ParseECPublicKeyFromPEM(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNotSupportSigningAlg
+	}
+	return key, nil
+}
+
+// JSONWebKey RFC 7517 格式的公钥
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA 公钥
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC 公钥
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySetProvider 提供校验令牌签名所需的公钥集合
+type KeySetProvider interface {
+	KeySet() *JSONWebKeySet
+}
+
+func (key *SigningKey) jsonWebKey() (JSONWebKey, bool) {
+	jwk := JSONWebKey{
+		Kid: key.Kid,
+		Use: "sig",
+		Alg: key.Method.Alg(),
+	}
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.X.Bytes(), size))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(publicKey.Y.Bytes(), size))
+	default:
+		return jwk, false
+	}
+	return jwk, true
+}
+
+// padBytes EC 坐标需要按曲线长度左补零
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+const (
+	LoginAttemptUser = "user"
+	LoginAttemptIp   = "ip"
+	// 内存存储的条目超过该数量时清理已过期的条目
+	maxMemoryLoginAttempts = 100000
+)
+
+var (
+	ErrLoginLocked = NewOAuth2Error(ErrorCodeInvalidGrant, "too many failed login attempts, try again later")
+)
+
+// LoginAttemptPolicy 连续失败 MaxFailures 次后锁定 BaseLockout，此后每次失败锁定时间翻倍，最长 MaxLockout
+type LoginAttemptPolicy struct {
+	MaxFailures int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// 超过该时间没有新的失败，失败次数清零
+	FailureWindow time.Duration
+}
+
+func (policy LoginAttemptPolicy) lockoutDuration(failures int64) time.Duration {
+	exponent := failures - int64(policy.MaxFailures)
+	if exponent < 0 {
+		return 0
+	}
+	if exponent > 30 {
+		return policy.MaxLockout
+	}
+	lockout := policy.BaseLockout << uint(exponent)
+	if lockout <= 0 || lockout > policy.MaxLockout {
+		return policy.MaxLockout
+	}
+	return lockout
+}
+
+// LoginAttemptStore 保存登录失败次数和锁定时间
+type LoginAttemptStore interface {
+	// IncrFailures 失败次数加一并返回累计值
+	IncrFailures(key string, window time.Duration) (int64, error)
+	Lock(key string, until time.Time) error
+	// LockedUntil 未锁定时返回零值
+	LockedUntil(key string) (time.Time, error)
+	Reset(key string) error
+}
+
+type memoryLoginAttempt struct {
+	failures          int64
+	failuresExpiresAt time.Time
+	lockedUntil       time.Time
+}
+
+// InMemoryLoginAttemptStore 单实例使用，也作为 redis 不可用时的降级存储
+type InMemoryLoginAttemptStore struct {
+	mutex    sync.Mutex
+	attempts map[string]*memoryLoginAttempt
+}
+
+func NewInMemoryLoginAttemptStore() *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{
+		attempts: make(map[string]*memoryLoginAttempt),
+	}
+}
+
+func (store *InMemoryLoginAttemptStore) IncrFailures(key string, window time.Duration) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	if len(store.attempts) > maxMemoryLoginAttempts {
+		store.removeExpired(now)
+	}
+	attempt, ok := store.attempts[key]
+	if !ok {
+		attempt = &memoryLoginAttempt{}
+		store.attempts[key] = attempt
+	}
+	if attempt.failuresExpiresAt.Before(now) {
+		attempt.failures = 0
+	}
+	attempt.failures++
+	attempt.failuresExpiresAt = now.Add(window)
+	return attempt.failures, nil
+}
+
+func (store *InMemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	attempt, ok := store.attempts[key]
+	if !ok {
+		attempt = &memoryLoginAttempt{}
+		store.attempts[key] = attempt
+	}
+	attempt.lockedUntil = until
+	return nil
+}
+
+func (store *InMemoryLoginAttemptStore) LockedUntil(key string) (time.Time, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if attempt, ok := store.attempts[key]; ok && attempt.lockedUntil.After(time.Now()) {
+		return attempt.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (store *InMemoryLoginAttemptStore) Reset(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.attempts, key)
+	return nil
+}
+
+func (store *InMemoryLoginAttemptStore) removeExpired(now time.Time) {
+	for key, attempt := range store.attempts {
+		if attempt.failuresExpiresAt.Before(now) && attempt.lockedUntil.Before(now) {
+			delete(store.attempts, key)
+		}
+	}
+}
+
+// FallbackLoginAttemptStore 主存储出错时改用备用存储，保证 redis 故障时仍然限制暴力登录
+type FallbackLoginAttemptStore struct {
+	primary  LoginAttemptStore
+	fallback LoginAttemptStore
+	logger   log.Logger
+}
+
+func NewFallbackLoginAttemptStore(primary LoginAttemptStore, fallback LoginAttemptStore, logger log.Logger) *FallbackLoginAttemptStore {
+	return &FallbackLoginAttemptStore{
+		primary:  primary,
+		fallback: fallback,
+		logger:   logger,
+	}
+}
+
+func (store *FallbackLoginAttemptStore) IncrFailures(key string, window time.Duration) (int64, error) {
+	failures, err := store.primary.IncrFailures(key, window)
+	if err != nil {
+		store.logger.Log("login attempt store", "fallback", "err", err)
+		return store.fallback.IncrFailures(key, window)
+	}
+	return failures, nil
+}
+
+func (store *FallbackLoginAttemptStore) Lock(key string, until time.Time) error {
+	if err := store.primary.Lock(key, until); err != nil {
+		store.logger.Log("login attempt store", "fallback", "err", err)
+		return store.fallback.Lock(key, until)
+	}
+	return nil
+}
+
+// LockedUntil 两个存储中任意一个处于锁定即视为锁定，避免 redis 恢复后丢失故障期间的锁定
+func (store *FallbackLoginAttemptStore) LockedUntil(key string) (time.Time, error) {
+	fallbackLockedUntil, _ := store.fallback.LockedUntil(key)
+	lockedUntil, err := store.primary.LockedUntil(key)
+	if err != nil {
+		store.logger.Log("login attempt store", "fallback", "err", err)
+		return fallbackLockedUntil, nil
+	}
+	if fallbackLockedUntil.After(lockedUntil) {
+		return fallbackLockedUntil, nil
+	}
+	return lockedUntil, nil
+}
+
+func (store *FallbackLoginAttemptStore) Reset(key string) error {
+	if err := store.primary.Reset(key); err != nil {
+		store.logger.Log("login attempt store", "fallback", "err", err)
+	}
+	return store.fallback.Reset(key)
+}
+
+// LoginAttemptMetrics 登录失败与锁定的监控指标，按 kind（user 或 ip）区分
+type LoginAttemptMetrics struct {
+	Failures metrics.Counter
+	Lockouts metrics.Counter
+	// 处于锁定期间被拒绝的登录
+	Rejected metrics.Counter
+	Unlocks  metrics.Counter
+}
+
+func NewPrometheusLoginAttemptMetrics() *LoginAttemptMetrics {
+	newCounter := func(name string, help string) metrics.Counter {
+		return kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "oauth",
+			Subsystem: "login",
+			Name:      name,
+			Help:      help,
+		}, []string{"kind"})
+	}
+	return &LoginAttemptMetrics{
+		Failures: newCounter("failures_total", "Number of failed password logins."),
+		Lockouts: newCounter("lockouts_total", "Number of lockouts caused by failed password logins."),
+		Rejected: newCounter("rejected_total", "Number of password logins rejected during a lockout."),
+		Unlocks:  newCounter("unlocks_total", "Number of lockouts cleared by an administrator."),
+	}
+}
+
+// LoginAttemptGuard 按用户名和来源 IP 统计密码登录失败次数并临时锁定
+type LoginAttemptGuard struct {
+	store      LoginAttemptStore
+	userPolicy LoginAttemptPolicy
+	ipPolicy   LoginAttemptPolicy
+	metrics    *LoginAttemptMetrics
+	logger     log.Logger
+}
+
+func NewLoginAttemptGuard(store LoginAttemptStore, userPolicy LoginAttemptPolicy, ipPolicy LoginAttemptPolicy, loginMetrics *LoginAttemptMetrics, logger log.Logger) *LoginAttemptGuard {
+	if loginMetrics == nil {
+		loginMetrics = &LoginAttemptMetrics{
+			Failures: discard.NewCounter(),
+			Lockouts: discard.NewCounter(),
+			Rejected: discard.NewCounter(),
+			Unlocks:  discard.NewCounter(),
+		}
+	}
+	return &LoginAttemptGuard{
+		store:      store,
+		userPolicy: userPolicy,
+		ipPolicy:   ipPolicy,
+		metrics:    loginMetrics,
+		logger:     logger,
+	}
+}
+
+func loginAttemptKey(kind string, value string) string {
+	return kind + ":" + value
+}
+
+// Check 用户名或来源 IP 处于锁定期间时返回 ErrLoginLocked
+func (guard *LoginAttemptGuard) Check(username string, ip string) error {
+	for kind, value := range map[string]string{LoginAttemptUser: username, LoginAttemptIp: ip} {
+		if value == "" {
+			continue
+		}
+		lockedUntil, err := guard.store.LockedUntil(loginAttemptKey(kind, value))
+		if err != nil {
+			guard.logger.Log("login attempt check", kind, "err", err)
+			continue
+		}
+		if lockedUntil.After(time.Now()) {
+			guard.metrics.Rejected.With("kind", kind).Add(1)
+			return ErrLoginLocked
+		}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次失败，达到阈值后按指数退避锁定
+func (guard *LoginAttemptGuard) RecordFailure(username string, ip string) {
+	guard.recordFailure(LoginAttemptUser, username, guard.userPolicy)
+	guard.recordFailure(LoginAttemptIp, ip, guard.ipPolicy)
+}
+
+func (guard *LoginAttemptGuard) recordFailure(kind string, value string, policy LoginAttemptPolicy) {
+	if value == "" || policy.MaxFailures <= 0 {
+		return
+	}
+	guard.metrics.Failures.With("kind", kind).Add(1)
+	key := loginAttemptKey(kind, value)
+	failures, err := guard.store.IncrFailures(key, policy.FailureWindow)
+	if err != nil {
+		guard.logger.Log("login attempt record", kind, "err", err)
+		return
+	}
+	lockout := policy.lockoutDuration(failures)
+	if lockout <= 0 {
+		return
+	}
+	if err = guard.store.Lock(key, time.Now().Add(lockout)); err != nil {
+		guard.logger.Log("login attempt lock", kind, "err", err)
+		return
+	}
+	guard.metrics.Lockouts.With("kind", kind).Add(1)
+	guard.logger.Log("login_locked", kind, "value", value, "failures", failures, "lockout", lockout)
+}
+
+// RecordSuccess 登录成功后清除该用户名的失败记录，来源 IP 的记录等待自然过期
+func (guard *LoginAttemptGuard) RecordSuccess(username string) {
+	if err := guard.store.Reset(loginAttemptKey(LoginAttemptUser, username)); err != nil {
+		guard.logger.Log("login attempt reset", LoginAttemptUser, "err", err)
+	}
+}
+
+// Unlock 管理员解除用户名或 IP 的锁定并清除失败次数
+func (guard *LoginAttemptGuard) Unlock(kind string, value string) error {
+	if err := guard.store.Reset(loginAttemptKey(kind, value)); err != nil {
+		return err
+	}
+	guard.metrics.Unlocks.With("kind", kind).Add(1)
+	return nil
+}
+
+// LockedUntil 查询用户名或 IP 的锁定截止时间，未锁定时返回零值
+func (guard *LoginAttemptGuard) LockedUntil(kind string, value string) (time.Time, error) {
+	return guard.store.LockedUntil(loginAttemptKey(kind, value))
+}
+
+// LoginAttemptUserDetailsService 在校验用户名密码前后检查和记录失败次数，
+// 密码模式、授权码登录页和设备验证页都通过它校验密码，共用同一套锁定规则
+type LoginAttemptUserDetailsService struct {
+	next  UserDetailsService
+	guard *LoginAttemptGuard
+}
+
+func NewLoginAttemptUserDetailsService(next UserDetailsService, guard *LoginAttemptGuard) UserDetailsService {
+	return &LoginAttemptUserDetailsService{
+		next:  next,
+		guard: guard,
+	}
+}
+
+// GetUserDetailByUserName 来源 IP 取自 transport 层放入上下文的终端用户 IP
+func (service *LoginAttemptUserDetailsService) GetUserDetailByUserName(ctx context.Context, username, password string) (*model.UserDetails, error) {
+	ip := ClientIpFromContext(ctx)
+	if err := service.guard.Check(username, ip); err != nil {
+		return nil, err
+	}
+	userDetails, err := service.next.GetUserDetailByUserName(ctx, username, password)
+	if err != nil {
+		// 用户服务不可用等错误不计入失败次数
+		if isCredentialError(err) {
+			service.guard.RecordFailure(username, ip)
+		}
+		return nil, err
+	}
+	service.guard.RecordSuccess(username)
+	return userDetails, nil
+}
+
+type clientIpContextKey struct{}
+
+// WithClientIp 将 transport 层解析出的终端用户 IP 放入上下文
+func WithClientIp(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIpContextKey{}, ip)
+}
+
+// ClientIpFromContext 无法确定终端用户 IP 时返回空字符串，此时不按 IP 限制
+func ClientIpFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIpContextKey{}).(string)
+	return ip
+}
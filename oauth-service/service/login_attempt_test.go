@@ -0,0 +1,97 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"github.com/go-kit/kit/log"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptPolicyLockoutDuration(t *testing.T) {
+	policy := LoginAttemptPolicy{
+		MaxFailures: 3,
+		BaseLockout: time.Minute,
+		MaxLockout:  10 * time.Minute,
+	}
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 6, want: 8 * time.Minute},
+		{failures: 7, want: 10 * time.Minute},
+		{failures: 100, want: 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if lockout := policy.lockoutDuration(tt.failures); lockout != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.failures, lockout, tt.want)
+		}
+	}
+}
+
+// stubUserDetailsService 只接受 alice/secret
+type stubUserDetailsService struct{}
+
+func (stubUserDetailsService) GetUserDetailByUserName(ctx context.Context, username, password string) (*model.UserDetails, error) {
+	if username != "alice" {
+		return nil, ErrUserNotExit
+	}
+	if password != "secret" {
+		return nil, ErrPassword
+	}
+	return newTestUser(1, username), nil
+}
+
+func TestLoginAttemptUserDetailsService(t *testing.T) {
+	type attempt struct {
+		username string
+		password string
+		ip       string
+	}
+	wrong := func(ip string) attempt { return attempt{username: "alice", password: "wrong", ip: ip} }
+	tests := []struct {
+		name     string
+		attempts []attempt
+		last     attempt
+		wantErr  error
+	}{
+		{name: "correct password", last: attempt{"alice", "secret", "10.0.0.1"}},
+		{name: "below the user threshold", attempts: []attempt{wrong("10.0.0.1"), wrong("10.0.0.2")}, last: attempt{"alice", "secret", "10.0.0.3"}},
+		{name: "user locked after threshold", attempts: []attempt{wrong("10.0.0.1"), wrong("10.0.0.2"), wrong("10.0.0.3")}, last: attempt{"alice", "secret", "10.0.0.4"}, wantErr: ErrLoginLocked},
+		{name: "success resets user failures", attempts: []attempt{wrong("10.0.0.1"), wrong("10.0.0.2"), {"alice", "secret", "10.0.0.3"}, wrong("10.0.0.4")}, last: attempt{"alice", "secret", "10.0.0.5"}},
+		{
+			name: "ip locked across users",
+			attempts: []attempt{
+				{"bob", "x", "10.0.0.9"}, {"carol", "x", "10.0.0.9"}, {"dave", "x", "10.0.0.9"},
+				{"erin", "x", "10.0.0.9"}, {"frank", "x", "10.0.0.9"},
+			},
+			last:    attempt{"alice", "secret", "10.0.0.9"},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name:     "unknown ip is not locked",
+			attempts: []attempt{{"bob", "x", ""}, {"carol", "x", ""}, {"dave", "x", ""}, {"erin", "x", ""}, {"frank", "x", ""}},
+			last:     attempt{"alice", "secret", ""},
+		},
+		{name: "wrong password", last: wrong("10.0.0.1"), wantErr: ErrPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewLoginAttemptGuard(NewInMemoryLoginAttemptStore(),
+				LoginAttemptPolicy{MaxFailures: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, FailureWindow: time.Hour},
+				LoginAttemptPolicy{MaxFailures: 5, BaseLockout: time.Minute, MaxLockout: time.Hour, FailureWindow: time.Hour},
+				nil, log.NewNopLogger())
+			userDetailsService := NewLoginAttemptUserDetailsService(stubUserDetailsService{}, guard)
+			for _, a := range tt.attempts {
+				userDetailsService.GetUserDetailByUserName(WithClientIp(context.Background(), a.ip), a.username, a.password)
+			}
+			_, err := userDetailsService.GetUserDetailByUserName(WithClientIp(context.Background(), tt.last.ip), tt.last.username, tt.last.password)
+			if err != tt.wantErr {
+				t.Fatalf("GetUserDetailByUserName() err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
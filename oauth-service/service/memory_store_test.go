@@ -0,0 +1,443 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryTokenStore 测试使用的令牌存储，行为与 RedisTokenStore 一致
+type memoryTokenStore struct {
+	mutex         sync.Mutex
+	accessTokens  map[string]*storedToken
+	refreshTokens map[string]*storedToken
+	authToAccess  map[string]string
+	usedRefresh   map[string]string
+	families      map[string][2]string
+	compromised   map[string]bool
+	userTokens    map[int64]map[string]bool
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{
+		accessTokens:  make(map[string]*storedToken),
+		refreshTokens: make(map[string]*storedToken),
+		authToAccess:  make(map[string]string),
+		usedRefresh:   make(map[string]string),
+		families:      make(map[string][2]string),
+		compromised:   make(map[string]bool),
+		userTokens:    make(map[int64]map[string]bool),
+	}
+}
+
+func (store *memoryTokenStore) StoreAccessToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.accessTokens[oauth2Token.TokenValue] = &storedToken{Token: oauth2Token, Details: withoutCredentials(oauth2Details)}
+	store.authToAccess[authenticationKey(oauth2Details)] = oauth2Token.TokenValue
+	store.indexUserToken(oauth2Details, userAccessTokenMember+oauth2Token.TokenValue)
+}
+
+func (store *memoryTokenStore) ReadAccessToken(tokenValue string) (*model.OAuth2Token, error) {
+	stored, err := store.read(store.accessTokens, tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+func (store *memoryTokenStore) ReadOAuth2Details(tokenValue string) (*model.OAuth2Details, error) {
+	stored, err := store.read(store.accessTokens, tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Details, nil
+}
+
+func (store *memoryTokenStore) GetAccessToken(oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
+	store.mutex.Lock()
+	tokenValue, ok := store.authToAccess[authenticationKey(oauth2Details)]
+	store.mutex.Unlock()
+	if !ok {
+		return nil, ErrInvalidTokenRequest
+	}
+	return store.ReadAccessToken(tokenValue)
+}
+
+func (store *memoryTokenStore) RemoveAccessToken(tokenValue string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stored, ok := store.accessTokens[tokenValue]
+	if !ok {
+		return
+	}
+	authKey := authenticationKey(stored.Details)
+	if store.authToAccess[authKey] == tokenValue {
+		delete(store.authToAccess, authKey)
+	}
+	delete(store.accessTokens, tokenValue)
+	store.unindexUserToken(stored.Details, userAccessTokenMember+tokenValue)
+}
+
+func (store *memoryTokenStore) StoreRefreshToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refreshTokens[oauth2Token.TokenValue] = &storedToken{Token: oauth2Token, Details: withoutCredentials(oauth2Details)}
+	store.indexUserToken(oauth2Details, userRefreshTokenMember+oauth2Token.TokenValue)
+}
+
+func (store *memoryTokenStore) RemoveRefreshToken(tokenValue string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if stored, ok := store.refreshTokens[tokenValue]; ok {
+		store.unindexUserToken(stored.Details, userRefreshTokenMember+tokenValue)
+	}
+	delete(store.refreshTokens, tokenValue)
+}
+
+func (store *memoryTokenStore) ReadRefreshToken(tokenValue string) (*model.OAuth2Token, error) {
+	stored, err := store.read(store.refreshTokens, tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+func (store *memoryTokenStore) ReadOAuth2DetailsForRefreshToken(tokenValue string) (*model.OAuth2Details, error) {
+	stored, err := store.read(store.refreshTokens, tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Details, nil
+}
+
+func (store *memoryTokenStore) MarkRefreshTokenUsed(refreshToken *model.OAuth2Token) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.usedRefresh[refreshToken.TokenValue]; ok {
+		return false, nil
+	}
+	store.usedRefresh[refreshToken.TokenValue] = refreshToken.FamilyId
+	return true, nil
+}
+
+func (store *memoryTokenStore) ReadUsedRefreshTokenFamily(tokenValue string) (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	familyId, ok := store.usedRefresh[tokenValue]
+	if !ok {
+		return "", ErrInvalidTokenRequest
+	}
+	return familyId, nil
+}
+
+func (store *memoryTokenStore) StoreTokenFamily(familyId string, accessToken *model.OAuth2Token, refreshToken *model.OAuth2Token) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.families[familyId] = [2]string{accessToken.TokenValue, refreshToken.TokenValue}
+}
+
+func (store *memoryTokenStore) ReadTokenFamily(familyId string) (string, string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	family, ok := store.families[familyId]
+	if !ok {
+		return "", "", ErrInvalidTokenRequest
+	}
+	return family[0], family[1], nil
+}
+
+func (store *memoryTokenStore) RemoveTokenFamily(familyId string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.families, familyId)
+}
+
+func (store *memoryTokenStore) MarkTokenFamilyCompromised(familyId string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.compromised[familyId] = true
+	return nil
+}
+
+func (store *memoryTokenStore) IsTokenFamilyCompromised(familyId string) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.compromised[familyId], nil
+}
+
+func (store *memoryTokenStore) ReadUserTokens(userId int64) ([]string, []string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var accessTokens, refreshTokens []string
+	for member := range store.userTokens[userId] {
+		if strings.HasPrefix(member, userAccessTokenMember) {
+			accessTokens = append(accessTokens, strings.TrimPrefix(member, userAccessTokenMember))
+		} else {
+			refreshTokens = append(refreshTokens, strings.TrimPrefix(member, userRefreshTokenMember))
+		}
+	}
+	return accessTokens, refreshTokens, nil
+}
+
+func (store *memoryTokenStore) RemoveUserTokens(userId int64, accessTokens []string, refreshTokens []string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, tokenValue := range accessTokens {
+		delete(store.userTokens[userId], userAccessTokenMember+tokenValue)
+	}
+	for _, tokenValue := range refreshTokens {
+		delete(store.userTokens[userId], userRefreshTokenMember+tokenValue)
+	}
+	return nil
+}
+
+func (store *memoryTokenStore) indexUserToken(oauth2Details *model.OAuth2Details, member string) {
+	if oauth2Details.User == nil {
+		return
+	}
+	members, ok := store.userTokens[oauth2Details.User.UserId]
+	if !ok {
+		members = make(map[string]bool)
+		store.userTokens[oauth2Details.User.UserId] = members
+	}
+	members[member] = true
+}
+
+func (store *memoryTokenStore) unindexUserToken(oauth2Details *model.OAuth2Details, member string) {
+	if oauth2Details == nil || oauth2Details.User == nil {
+		return
+	}
+	delete(store.userTokens[oauth2Details.User.UserId], member)
+}
+
+func (store *memoryTokenStore) read(tokens map[string]*storedToken, tokenValue string) (*storedToken, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stored, ok := tokens[tokenValue]
+	if !ok || stored.Token.IsExpired() {
+		return nil, ErrInvalidTokenRequest
+	}
+	return stored, nil
+}
+
+// memoryTokenDenylist 测试使用的吊销列表
+type memoryTokenDenylist struct {
+	mutex          sync.Mutex
+	denied         map[string]bool
+	revokedUsers   map[string]time.Time
+	revokedClients map[string]bool
+}
+
+func newMemoryTokenDenylist() *memoryTokenDenylist {
+	return &memoryTokenDenylist{
+		denied:         make(map[string]bool),
+		revokedUsers:   make(map[string]time.Time),
+		revokedClients: make(map[string]bool),
+	}
+}
+
+func (denylist *memoryTokenDenylist) RevokeClient(clientId string) error {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	denylist.revokedClients[clientId] = true
+	return nil
+}
+
+func (denylist *memoryTokenDenylist) IsClientRevoked(clientId string) (bool, error) {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	return denylist.revokedClients[clientId], nil
+}
+
+func (denylist *memoryTokenDenylist) Deny(tokenId string, expiresTime *time.Time) error {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	denylist.denied[tokenId] = true
+	return nil
+}
+
+func (denylist *memoryTokenDenylist) IsDenied(tokenId string) (bool, error) {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	return denylist.denied[tokenId], nil
+}
+
+func (denylist *memoryTokenDenylist) RevokeUser(userId int64, clientId string, revokedAt time.Time) error {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	denylist.revokedUsers[revokedUserKey(userId, clientId)] = revokedAt
+	return nil
+}
+
+func (denylist *memoryTokenDenylist) RevokedAfter(userId int64, clientId string) (time.Time, error) {
+	denylist.mutex.Lock()
+	defer denylist.mutex.Unlock()
+	revokedAfter := denylist.revokedUsers[revokedUserKey(userId, "")]
+	if revokedAt := denylist.revokedUsers[revokedUserKey(userId, clientId)]; revokedAt.After(revokedAfter) {
+		revokedAfter = revokedAt
+	}
+	return revokedAfter, nil
+}
+
+// memoryAuthorizationCodeService 测试使用的授权码存储
+type memoryAuthorizationCodeService struct {
+	mutex sync.Mutex
+	codes map[string]*model.AuthorizationCode
+}
+
+func newMemoryAuthorizationCodeService() *memoryAuthorizationCodeService {
+	return &memoryAuthorizationCodeService{
+		codes: make(map[string]*model.AuthorizationCode),
+	}
+}
+
+func (codeService *memoryAuthorizationCodeService) CreateAuthorizationCode(code *model.AuthorizationCode) (*model.AuthorizationCode, error) {
+	codeValue, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	expiredTime := time.Now().Add(authorizationCodeValidity)
+	code.Code = codeValue
+	code.ExpriesTime = &expiredTime
+	codeService.mutex.Lock()
+	defer codeService.mutex.Unlock()
+	codeService.codes[codeValue] = code
+	return code, nil
+}
+
+func (codeService *memoryAuthorizationCodeService) ConsumeAuthorizationCode(codeValue string) (*model.AuthorizationCode, error) {
+	codeService.mutex.Lock()
+	defer codeService.mutex.Unlock()
+	code, ok := codeService.codes[codeValue]
+	delete(codeService.codes, codeValue)
+	if !ok || code.IsExpired() {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	return code, nil
+}
+
+// memoryClientDetailsService 测试使用的客户端存储，记录校验密钥的次数
+type memoryClientDetailsService struct {
+	mutex           sync.Mutex
+	clients         map[string]*model.ClientDetails
+	authentications int
+}
+
+func newMemoryClientDetailsService(clients ...*model.ClientDetails) *memoryClientDetailsService {
+	clientDetailsService := &memoryClientDetailsService{
+		clients: make(map[string]*model.ClientDetails),
+	}
+	for _, client := range clients {
+		clientDetailsService.clients[client.ClientId] = client
+	}
+	return clientDetailsService
+}
+
+func (clientDetailsService *memoryClientDetailsService) GetClientDetailByClientId(ctx context.Context, clientId string, clientSecret string) (*model.ClientDetails, error) {
+	clientDetailsService.mutex.Lock()
+	clientDetailsService.authentications++
+	clientDetailsService.mutex.Unlock()
+	clientDetails, err := clientDetailsService.GetClientDetailById(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+	if !clientDetails.IsMatch(clientId, clientSecret) {
+		return nil, ErrClientMessage
+	}
+	return clientDetails, nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) GetClientDetailById(ctx context.Context, clientId string) (*model.ClientDetails, error) {
+	clientDetailsService.mutex.Lock()
+	defer clientDetailsService.mutex.Unlock()
+	clientDetails, ok := clientDetailsService.clients[clientId]
+	if !ok {
+		return nil, model.ErrClientNotFound
+	}
+	if clientDetails.Disabled {
+		return nil, ErrClientDisabled
+	}
+	return clientDetails, nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) RotateClientSecret(ctx context.Context, clientId string, gracePeriod time.Duration) (string, *time.Time, error) {
+	clientDetailsService.mutex.Lock()
+	defer clientDetailsService.mutex.Unlock()
+	clientDetails, ok := clientDetailsService.clients[clientId]
+	if !ok {
+		return "", nil, model.ErrClientNotFound
+	}
+	clientSecret, err := randomString(32)
+	if err != nil {
+		return "", nil, err
+	}
+	secretHash, err := model.HashClientSecret(clientSecret)
+	if err != nil {
+		return "", nil, err
+	}
+	expiresTime := time.Now().Add(gracePeriod)
+	clientDetails.PreviousClientSecret = clientDetails.ClientSecret
+	clientDetails.PreviousSecretExpiresAt = &expiresTime
+	clientDetails.ClientSecret = secretHash
+	return clientSecret, &expiresTime, nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) CreateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) (string, error) {
+	clientDetailsService.mutex.Lock()
+	defer clientDetailsService.mutex.Unlock()
+	clientDetailsService.clients[clientDetails.ClientId] = clientDetails
+	return clientDetails.ClientSecret, nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) UpdateClientDetails(ctx context.Context, clientDetails *model.ClientDetails) error {
+	clientDetailsService.mutex.Lock()
+	defer clientDetailsService.mutex.Unlock()
+	clientDetailsService.clients[clientDetails.ClientId] = clientDetails
+	return nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) DisableClientDetails(ctx context.Context, clientId string) error {
+	clientDetailsService.mutex.Lock()
+	defer clientDetailsService.mutex.Unlock()
+	clientDetails, ok := clientDetailsService.clients[clientId]
+	if !ok {
+		return model.ErrClientNotFound
+	}
+	clientDetails.Disabled = true
+	return nil
+}
+
+func (clientDetailsService *memoryClientDetailsService) ListClientDetails(ctx context.Context, offset int, limit int) ([]*model.ClientDetails, error) {
+	return nil, ErrNotSupportOperation
+}
+
+// newTestTokenService 使用内存存储和 HS256 签名的令牌服务
+func newTestTokenService() (*DefaultTokenService, *memoryTokenStore, TokenEnhancer) {
+	tokenStore := newMemoryTokenStore()
+	tokenEnhancer := NewJwtTokenEnhancer("test-secret")
+	tokenService := NewTokenService(tokenStore, tokenEnhancer, newMemoryTokenDenylist(), nil).(*DefaultTokenService)
+	return tokenService, tokenStore, tokenEnhancer
+}
+
+func newTestClient(clientId string, public bool, scope ...string) *model.ClientDetails {
+	return &model.ClientDetails{
+		ClientId:                    clientId,
+		AccessTokenValiditySeconds:  3600,
+		RefreshTokenValiditySeconds: 86400,
+		RegisteredRedirectUri:       "https://" + clientId + ".example.com/callback",
+		AuthorizedGrantTypes:        []string{"password", "refresh_token", "authorization_code", TokenExchangeGrantType},
+		Scope:                       scope,
+		Public:                      public,
+	}
+}
+
+func newTestUser(userId int64, username string) *model.UserDetails {
+	return &model.UserDetails{
+		UserId:      userId,
+		Username:    username,
+		Authorities: []string{"ROLE_USER"},
+	}
+}
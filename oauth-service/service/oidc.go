@@ -0,0 +1,112 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"github.com/dgrijalva/jwt-go"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// 申请该范围时按 OpenID Connect 签发 id_token
+	OpenIdScope = "openid"
+)
+
+var (
+	ErrInvalidAccessToken = NewOAuth2Error(ErrorCodeInvalidToken, "access token is invalid or expired")
+	ErrInsufficientScope  = NewOAuth2Error(ErrorCodeInsufficientScope, "access token does not have the openid scope")
+)
+
+// IdTokenIssuer 为申请了 openid 范围的用户令牌签发 id_token
+type IdTokenIssuer interface {
+	IssueIdToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) (string, error)
+	// 令牌的 iss 声明
+	Issuer() string
+	// 签名使用的算法，供 discovery 文档使用
+	SigningAlgorithms() []string
+}
+
+// GrantTypeProvider 列出支持的授权类型，供 discovery 文档使用
+type GrantTypeProvider interface {
+	GrantTypes() []string
+}
+
+func (tokenGranter *ComposeTokenGranter) GrantTypes() []string {
+	grantTypes := make([]string, 0, len(tokenGranter.TokenGrantDict))
+	for grantType := range tokenGranter.TokenGrantDict {
+		grantTypes = append(grantTypes, grantType)
+	}
+	sort.Strings(grantTypes)
+	return grantTypes
+}
+
+// IdTokenClaims OpenID Connect Core 1.0 第 2 节定义的 id_token 声明
+type IdTokenClaims struct {
+	Nonce             string   `json:"nonce,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Authorities       []string `json:"authorities,omitempty"`
+	jwt.StandardClaims
+}
+
+func (enhancer *JwtTokenEnhancer) IssueIdToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) (string, error) {
+	user := oauth2Details.User
+	claims := &IdTokenClaims{
+		Nonce:             oauth2Details.Nonce,
+		PreferredUsername: user.Username,
+		Authorities:       user.Authorities,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.FormatInt(user.UserId, 10),
+			Audience:  oauth2Details.Client.ClientId,
+			ExpiresAt: oauth2Token.ExpriesTime.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    enhancer.issuer,
+		},
+	}
+	return enhancer.signClaims(claims)
+}
+
+func (enhancer *JwtTokenEnhancer) Issuer() string {
+	return enhancer.issuer
+}
+
+func (enhancer *JwtTokenEnhancer) SigningAlgorithms() []string {
+	if activeKey, ok := enhancer.signingKeys[enhancer.activeKid]; ok {
+		return []string{activeKey.Method.Alg()}
+	}
+	return []string{jwt.SigningMethodHS256.Alg()}
+}
+
+// UserInfo /userinfo 返回的用户声明
+type UserInfo struct {
+	Sub               string   `json:"sub"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Authorities       []string `json:"authorities,omitempty"`
+}
+
+func NewUserInfo(user *model.UserDetails) *UserInfo {
+	return &UserInfo{
+		Sub:               strconv.FormatInt(user.UserId, 10),
+		PreferredUsername: user.Username,
+		Authorities:       user.Authorities,
+	}
+}
+
+// OpenIdConfiguration OpenID Connect Discovery 1.0 的提供方元数据
+type OpenIdConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
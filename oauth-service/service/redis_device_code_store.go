@@ -0,0 +1,144 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+const (
+	deviceCodeKeyPrefix = "oauth:device_code:"
+	userCodeKeyPrefix   = "oauth:user_code:"
+	devicePollKeyPrefix = "oauth:device_poll:"
+	// 设备码被并发修改时重新读取的次数
+	maxIncreaseIntervalAttempts = 3
+)
+
+type RedisDeviceCodeStore struct {
+	client *redis.Client
+}
+
+func NewRedisDeviceCodeStore(client *redis.Client) *RedisDeviceCodeStore {
+	return &RedisDeviceCodeStore{
+		client: client,
+	}
+}
+
+func (store *RedisDeviceCodeStore) Save(code *model.DeviceCode) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+	ttl := deviceCodeTTL(code)
+	// 先占用用户码，避免两个设备码共用一个用户码
+	ok, err := store.client.SetNX(userCodeKeyPrefix+code.UserCode, code.DeviceCode, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserCodeConflict
+	}
+	return store.client.Set(deviceCodeKeyPrefix+code.DeviceCode, data, ttl).Err()
+}
+
+func (store *RedisDeviceCodeStore) ReadByDeviceCode(deviceCode string) (*model.DeviceCode, error) {
+	data, err := store.client.Get(deviceCodeKeyPrefix + deviceCode).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidDeviceCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	code := &model.DeviceCode{}
+	if err = json.Unmarshal(data, code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func (store *RedisDeviceCodeStore) ReadByUserCode(userCode string) (*model.DeviceCode, error) {
+	deviceCode, err := store.client.Get(userCodeKeyPrefix + userCode).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	code, err := store.ReadByDeviceCode(deviceCode)
+	if err == ErrInvalidDeviceCode {
+		return nil, ErrInvalidUserCode
+	}
+	return code, err
+}
+
+// Update 只更新仍然存在的设备码，已兑换的设备码不会被重新写入
+func (store *RedisDeviceCodeStore) Update(code *model.DeviceCode) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+	ttl := deviceCodeTTL(code)
+	if ttl <= 0 {
+		return ErrInvalidDeviceCode
+	}
+	ok, err := store.client.SetXX(deviceCodeKeyPrefix+code.DeviceCode, data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidDeviceCode
+	}
+	return nil
+}
+
+// IncreaseInterval 使用 WATCH 保证读取后设备码未被用户的授权结果覆盖，被并发修改时重新读取
+func (store *RedisDeviceCodeStore) IncreaseInterval(deviceCode string, step int) error {
+	key := deviceCodeKeyPrefix + deviceCode
+	for i := 0; i < maxIncreaseIntervalAttempts; i++ {
+		err := store.client.Watch(func(tx *redis.Tx) error {
+			data, err := tx.Get(key).Bytes()
+			if err == redis.Nil {
+				return ErrInvalidDeviceCode
+			}
+			if err != nil {
+				return err
+			}
+			code := &model.DeviceCode{}
+			if err = json.Unmarshal(data, code); err != nil {
+				return err
+			}
+			code.Interval += step
+			ttl := deviceCodeTTL(code)
+			if ttl <= 0 {
+				return ErrInvalidDeviceCode
+			}
+			if data, err = json.Marshal(code); err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+				pipe.Set(key, data, ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
+func (store *RedisDeviceCodeStore) Remove(code *model.DeviceCode) (bool, error) {
+	pipe := store.client.TxPipeline()
+	del := pipe.Del(deviceCodeKeyPrefix + code.DeviceCode)
+	pipe.Del(userCodeKeyPrefix+code.UserCode, devicePollKeyPrefix+code.DeviceCode)
+	if _, err := pipe.Exec(); err != nil {
+		return false, err
+	}
+	return del.Val() > 0, nil
+}
+
+func (store *RedisDeviceCodeStore) MarkPolled(deviceCode string, interval time.Duration) (bool, error) {
+	return store.client.SetNX(devicePollKeyPrefix+deviceCode, 1, interval).Result()
+}
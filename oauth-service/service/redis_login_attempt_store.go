@@ -0,0 +1,54 @@
+package service
+
+import (
+	"github.com/go-redis/redis"
+	"time"
+)
+
+const (
+	loginFailuresKeyPrefix = "oauth:login_failures:"
+	loginLockKeyPrefix     = "oauth:login_lock:"
+)
+
+type RedisLoginAttemptStore struct {
+	client *redis.Client
+}
+
+func NewRedisLoginAttemptStore(client *redis.Client) *RedisLoginAttemptStore {
+	return &RedisLoginAttemptStore{
+		client: client,
+	}
+}
+
+func (store *RedisLoginAttemptStore) IncrFailures(key string, window time.Duration) (int64, error) {
+	pipe := store.client.TxPipeline()
+	incr := pipe.Incr(loginFailuresKeyPrefix + key)
+	pipe.Expire(loginFailuresKeyPrefix+key, window)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (store *RedisLoginAttemptStore) Lock(key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return store.client.Set(loginLockKeyPrefix+key, until.Unix(), ttl).Err()
+}
+
+func (store *RedisLoginAttemptStore) LockedUntil(key string) (time.Time, error) {
+	value, err := store.client.Get(loginLockKeyPrefix + key).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(value, 0), nil
+}
+
+func (store *RedisLoginAttemptStore) Reset(key string) error {
+	return store.client.Del(loginFailuresKeyPrefix+key, loginLockKeyPrefix+key).Err()
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenKeyPrefix   = "oauth:access:"
+	refreshTokenKeyPrefix  = "oauth:refresh:"
+	authToAccessKeyPrefix  = "oauth:auth_to_access:"
+	deniedTokenKeyPrefix   = "oauth:denied:"
+	usedRefreshKeyPrefix   = "oauth:refresh_used:"
+	tokenFamilyKeyPrefix   = "oauth:family:"
+	compromisedKeyPrefix   = "oauth:family_compromised:"
+	userTokensKeyPrefix    = "oauth:user_tokens:"
+	revokedUserKeyPrefix   = "oauth:revoked_user:"
+	revokedClientKeyPrefix = "oauth:revoked_client:"
+	// 停用客户端的记录在令牌有效期上限之外额外保留的时间，远大于客户端认证的缓存时间
+	revokedClientRetention = time.Hour
+	// 用户令牌索引中成员的前缀
+	userAccessTokenMember  = "access:"
+	userRefreshTokenMember = "refresh:"
+)
+
+// 令牌及其对应的客户端和用户信息，序列化后存入redis
+type storedToken struct {
+	Token   *model.OAuth2Token
+	Details *model.OAuth2Details
+}
+
+type RedisTokenStore struct {
+	client *redis.Client
+}
+
+func NewRedisTokenStore(client *redis.Client) *RedisTokenStore {
+	return &RedisTokenStore{
+		client: client,
+	}
+}
+
+func (tokenStore *RedisTokenStore) StoreAccessToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) {
+	ttl, ok := tokenTTL(oauth2Token)
+	if !ok {
+		return
+	}
+	if err := tokenStore.store(accessTokenKeyPrefix+oauth2Token.TokenValue, oauth2Token, oauth2Details, ttl); err != nil {
+		log.Printf("store access token err : %v", err)
+		return
+	}
+	if err := tokenStore.client.Set(authToAccessKeyPrefix+authenticationKey(oauth2Details), oauth2Token.TokenValue, ttl).Err(); err != nil {
+		log.Printf("store authentication key err : %v", err)
+	}
+	tokenStore.indexUserToken(oauth2Details, userAccessTokenMember+oauth2Token.TokenValue, ttl)
+}
+
+func (tokenStore *RedisTokenStore) ReadAccessToken(tokenValue string) (*model.OAuth2Token, error) {
+	stored, err := tokenStore.read(accessTokenKeyPrefix + tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+func (tokenStore *RedisTokenStore) ReadOAuth2Details(tokenValue string) (*model.OAuth2Details, error) {
+	stored, err := tokenStore.read(accessTokenKeyPrefix + tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Details, nil
+}
+
+func (tokenStore *RedisTokenStore) GetAccessToken(oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
+	tokenValue, err := tokenStore.client.Get(authToAccessKeyPrefix + authenticationKey(oauth2Details)).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidTokenRequest
+	} else if err != nil {
+		return nil, err
+	}
+	return tokenStore.ReadAccessToken(tokenValue)
+}
+
+func (tokenStore *RedisTokenStore) RemoveAccessToken(tokenValue string) {
+	stored, err := tokenStore.read(accessTokenKeyPrefix + tokenValue)
+	if err != nil {
+		return
+	}
+	authKey := authToAccessKeyPrefix + authenticationKey(stored.Details)
+	// 只有当前绑定的仍是该令牌时才移除，避免误删新生成的令牌
+	if current, err := tokenStore.client.Get(authKey).Result(); err == nil && current == tokenValue {
+		tokenStore.client.Del(authKey)
+	}
+	tokenStore.client.Del(accessTokenKeyPrefix + tokenValue)
+	tokenStore.unindexUserToken(stored.Details, userAccessTokenMember+tokenValue)
+}
+
+func (tokenStore *RedisTokenStore) StoreRefreshToken(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) {
+	ttl, ok := tokenTTL(oauth2Token)
+	if !ok {
+		return
+	}
+	if err := tokenStore.store(refreshTokenKeyPrefix+oauth2Token.TokenValue, oauth2Token, oauth2Details, ttl); err != nil {
+		log.Printf("store refresh token err : %v", err)
+		return
+	}
+	tokenStore.indexUserToken(oauth2Details, userRefreshTokenMember+oauth2Token.TokenValue, ttl)
+}
+
+func (tokenStore *RedisTokenStore) RemoveRefreshToken(oauth2Token string) {
+	if stored, err := tokenStore.read(refreshTokenKeyPrefix + oauth2Token); err == nil {
+		tokenStore.unindexUserToken(stored.Details, userRefreshTokenMember+oauth2Token)
+	}
+	tokenStore.client.Del(refreshTokenKeyPrefix + oauth2Token)
+}
+
+func (tokenStore *RedisTokenStore) ReadRefreshToken(tokenValue string) (*model.OAuth2Token, error) {
+	stored, err := tokenStore.read(refreshTokenKeyPrefix + tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+func (tokenStore *RedisTokenStore) ReadOAuth2DetailsForRefreshToken(tokenValue string) (*model.OAuth2Details, error) {
+	stored, err := tokenStore.read(refreshTokenKeyPrefix + tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Details, nil
+}
+
+func (tokenStore *RedisTokenStore) MarkRefreshTokenUsed(refreshToken *model.OAuth2Token) (bool, error) {
+	ttl, ok := tokenTTL(refreshToken)
+	if !ok {
+		return false, ErrExpiredToken
+	}
+	// SETNX 保证同一个刷新令牌只能被标记一次
+	return tokenStore.client.SetNX(usedRefreshKeyPrefix+refreshToken.TokenValue, refreshToken.FamilyId, ttl).Result()
+}
+
+func (tokenStore *RedisTokenStore) ReadUsedRefreshTokenFamily(tokenValue string) (string, error) {
+	familyId, err := tokenStore.client.Get(usedRefreshKeyPrefix + tokenValue).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidTokenRequest
+	}
+	return familyId, err
+}
+
+func (tokenStore *RedisTokenStore) StoreTokenFamily(familyId string, accessToken *model.OAuth2Token, refreshToken *model.OAuth2Token) {
+	ttl, ok := tokenTTL(refreshToken)
+	if !ok {
+		return
+	}
+	key := tokenFamilyKeyPrefix + familyId
+	pipe := tokenStore.client.TxPipeline()
+	pipe.HMSet(key, map[string]interface{}{
+		"access":  accessToken.TokenValue,
+		"refresh": refreshToken.TokenValue,
+	})
+	if ttl > 0 {
+		pipe.Expire(key, ttl)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		log.Printf("store token family err : %v", err)
+	}
+}
+
+func (tokenStore *RedisTokenStore) ReadTokenFamily(familyId string) (string, string, error) {
+	values, err := tokenStore.client.HGetAll(tokenFamilyKeyPrefix + familyId).Result()
+	if err != nil {
+		return "", "", err
+	}
+	if len(values) == 0 {
+		return "", "", ErrInvalidTokenRequest
+	}
+	return values["access"], values["refresh"], nil
+}
+
+func (tokenStore *RedisTokenStore) RemoveTokenFamily(familyId string) {
+	tokenStore.client.Del(tokenFamilyKeyPrefix + familyId)
+}
+
+// MarkTokenFamilyCompromised 家族中的令牌以及正在轮换的令牌都在 maxTokenValidity 内过期，标记保留同样的时间
+func (tokenStore *RedisTokenStore) MarkTokenFamilyCompromised(familyId string) error {
+	return tokenStore.client.Set(compromisedKeyPrefix+familyId, 1, maxTokenValidity).Err()
+}
+
+func (tokenStore *RedisTokenStore) IsTokenFamilyCompromised(familyId string) (bool, error) {
+	count, err := tokenStore.client.Exists(compromisedKeyPrefix + familyId).Result()
+	return count > 0, err
+}
+
+// indexUserToken 将用户的令牌加入索引，索引的存活时间不短于其中最晚过期的令牌
+func (tokenStore *RedisTokenStore) indexUserToken(oauth2Details *model.OAuth2Details, member string, ttl time.Duration) {
+	if oauth2Details.User == nil {
+		return
+	}
+	key := userTokensKeyPrefix + strconv.FormatInt(oauth2Details.User.UserId, 10)
+	pipe := tokenStore.client.TxPipeline()
+	pipe.SAdd(key, member)
+	currentTTL := pipe.TTL(key)
+	if _, err := pipe.Exec(); err != nil {
+		log.Printf("index user token err : %v", err)
+		return
+	}
+	if currentTTL.Val() < ttl {
+		tokenStore.client.Expire(key, ttl)
+	}
+}
+
+func (tokenStore *RedisTokenStore) unindexUserToken(oauth2Details *model.OAuth2Details, member string) {
+	if oauth2Details == nil || oauth2Details.User == nil {
+		return
+	}
+	tokenStore.client.SRem(userTokensKeyPrefix+strconv.FormatInt(oauth2Details.User.UserId, 10), member)
+}
+
+func (tokenStore *RedisTokenStore) ReadUserTokens(userId int64) ([]string, []string, error) {
+	members, err := tokenStore.client.SMembers(userTokensKeyPrefix + strconv.FormatInt(userId, 10)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	var accessTokens, refreshTokens []string
+	for _, member := range members {
+		if strings.HasPrefix(member, userAccessTokenMember) {
+			accessTokens = append(accessTokens, strings.TrimPrefix(member, userAccessTokenMember))
+		} else if strings.HasPrefix(member, userRefreshTokenMember) {
+			refreshTokens = append(refreshTokens, strings.TrimPrefix(member, userRefreshTokenMember))
+		}
+	}
+	return accessTokens, refreshTokens, nil
+}
+
+func (tokenStore *RedisTokenStore) RemoveUserTokens(userId int64, accessTokens []string, refreshTokens []string) error {
+	var members []interface{}
+	for _, tokenValue := range accessTokens {
+		members = append(members, userAccessTokenMember+tokenValue)
+	}
+	for _, tokenValue := range refreshTokens {
+		members = append(members, userRefreshTokenMember+tokenValue)
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return tokenStore.client.SRem(userTokensKeyPrefix+strconv.FormatInt(userId, 10), members...).Err()
+}
+
+func (tokenStore *RedisTokenStore) store(key string, oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details, ttl time.Duration) error {
+	data, err := json.Marshal(&storedToken{
+		Token:   oauth2Token,
+		Details: withoutCredentials(oauth2Details),
+	})
+	if err != nil {
+		return err
+	}
+	return tokenStore.client.Set(key, data, ttl).Err()
+}
+
+func (tokenStore *RedisTokenStore) read(key string) (*storedToken, error) {
+	data, err := tokenStore.client.Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidTokenRequest
+	} else if err != nil {
+		return nil, err
+	}
+	stored := &storedToken{}
+	if err = json.Unmarshal(data, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// tokenTTL 根据令牌过期时间计算redis中的存活时间，0 表示永不过期
+func tokenTTL(oauth2Token *model.OAuth2Token) (time.Duration, bool) {
+	if oauth2Token.ExpriesTime == nil {
+		return 0, true
+	}
+	ttl := time.Until(*oauth2Token.ExpriesTime)
+	return ttl, ttl > 0
+}
+
+// authenticationKey 同一客户端、用户和权限范围共享同一个访问令牌，客户端模式的令牌不绑定用户
+func authenticationKey(oauth2Details *model.OAuth2Details) string {
+	key := oauth2Details.Client.ClientId
+	if oauth2Details.User != nil {
+		key += ":" + strconv.FormatInt(oauth2Details.User.UserId, 10)
+	}
+	if len(oauth2Details.Scope) > 0 {
+		scope := append([]string{}, oauth2Details.Scope...)
+		sort.Strings(scope)
+		key += ":" + strings.Join(scope, ",")
+	}
+	// 令牌交换签发的令牌按受众和调用方区分，不与客户端自身申请的令牌共用
+	if oauth2Details.Audience != "" {
+		key += ":aud=" + oauth2Details.Audience
+	}
+	for actor := oauth2Details.Actor; actor != nil; actor = actor.Actor {
+		key += ":act=" + actor.Subject
+	}
+	if oauth2Details.OriginClientId != "" {
+		key += ":origin=" + oauth2Details.OriginClientId
+	}
+	return key
+}
+
+// withoutCredentials 去掉客户端密钥和用户密码后再持久化
+func withoutCredentials(oauth2Details *model.OAuth2Details) *model.OAuth2Details {
+	details := &model.OAuth2Details{
+		Scope:          oauth2Details.Scope,
+		Audience:       oauth2Details.Audience,
+		Actor:          oauth2Details.Actor,
+		OriginClientId: oauth2Details.OriginClientId,
+	}
+	if oauth2Details.Client != nil {
+		client := *oauth2Details.Client
+		client.ClientSecret = ""
+		client.PreviousClientSecret = ""
+		details.Client = &client
+	}
+	if oauth2Details.User != nil {
+		user := *oauth2Details.User
+		user.Password = ""
+		details.User = &user
+	}
+	return details
+}
+
+type RedisTokenDenylist struct {
+	client *redis.Client
+}
+
+func NewRedisTokenDenylist(client *redis.Client) *RedisTokenDenylist {
+	return &RedisTokenDenylist{
+		client: client,
+	}
+}
+
+func (denylist *RedisTokenDenylist) Deny(tokenId string, expiresTime *time.Time) error {
+	var ttl time.Duration
+	if expiresTime != nil {
+		if ttl = time.Until(*expiresTime); ttl <= 0 {
+			return nil
+		}
+	}
+	return denylist.client.Set(deniedTokenKeyPrefix+tokenId, 1, ttl).Err()
+}
+
+func (denylist *RedisTokenDenylist) IsDenied(tokenId string) (bool, error) {
+	count, err := denylist.client.Exists(deniedTokenKeyPrefix + tokenId).Result()
+	return count > 0, err
+}
+
+// RevokeUser 吊销时间之前签发的令牌都在 maxTokenValidity 内过期，记录保留同样的时间
+func (denylist *RedisTokenDenylist) RevokeUser(userId int64, clientId string, revokedAt time.Time) error {
+	return denylist.client.Set(revokedUserKey(userId, clientId), revokedAt.Unix(), time.Until(revokedAt.Add(maxTokenValidity))).Err()
+}
+
+func (denylist *RedisTokenDenylist) RevokedAfter(userId int64, clientId string) (time.Time, error) {
+	values, err := denylist.client.MGet(revokedUserKey(userId, ""), revokedUserKey(userId, clientId)).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+	var revokedAfter int64
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		revokedAt, err := strconv.ParseInt(value.(string), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if revokedAt > revokedAfter {
+			revokedAfter = revokedAt
+		}
+	}
+	if revokedAfter == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(revokedAfter, 0), nil
+}
+
+// RevokeClient 停用前签发的令牌在 maxTokenValidity 内过期，再加上其他实例缓存客户端认证期间签发的令牌
+func (denylist *RedisTokenDenylist) RevokeClient(clientId string) error {
+	return denylist.client.Set(revokedClientKeyPrefix+clientId, time.Now().Unix(), maxTokenValidity+revokedClientRetention).Err()
+}
+
+func (denylist *RedisTokenDenylist) IsClientRevoked(clientId string) (bool, error) {
+	count, err := denylist.client.Exists(revokedClientKeyPrefix + clientId).Result()
+	return count > 0, err
+}
+
+func revokedUserKey(userId int64, clientId string) string {
+	key := revokedUserKeyPrefix + strconv.FormatInt(userId, 10)
+	if clientId != "" {
+		key += ":" + clientId
+	}
+	return key
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"net/http"
+)
+
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// RFC 8693 3 节定义的令牌类型，本服务签发的访问令牌即为 JWT
+	AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+	JwtTokenType    = "urn:ietf:params:oauth:token-type:jwt"
+	// act 声明最多嵌套的层数，限制令牌被逐级交换的次数
+	MaxActorDepth = 3
+)
+
+var (
+	ErrMissingSubjectToken      = NewOAuth2Error(ErrorCodeInvalidRequest, "subject_token and subject_token_type are required")
+	ErrUnsupportedTokenType     = NewOAuth2Error(ErrorCodeInvalidRequest, "token type must be access_token or jwt")
+	ErrActorTokenNotSupported   = NewOAuth2Error(ErrorCodeInvalidRequest, "actor_token is not supported, the authenticated client is the actor")
+	ErrMultipleAudiences        = NewOAuth2Error(ErrorCodeInvalidRequest, "only one audience is supported")
+	ErrInvalidSubjectToken      = NewOAuth2Error(ErrorCodeInvalidGrant, "subject token is invalid, expired, revoked or does not represent a user")
+	ErrInvalidTokenAudience     = NewOAuth2Error(ErrorCodeInvalidTarget, "audience is not a registered client")
+	ErrSubjectTokenNotForClient = NewOAuth2Error(ErrorCodeInvalidGrant, "subject token was not issued to or for this client")
+	ErrActorChainTooDeep        = NewOAuth2Error(ErrorCodeInvalidGrant, "subject token has been exchanged too many times")
+	ErrTokenAudienceMismatch    = NewOAuth2Error(ErrorCodeInvalidToken, "access token is not intended for this audience")
+)
+
+// TokenExchangeGranter 按 RFC 8693 将用户的访问令牌交换为调用下游服务的令牌，
+// 新令牌的受众为下游服务，权限范围不超过原令牌和调用方客户端的范围，有效期不超过原令牌，并在 act 声明中记录调用方
+type TokenExchangeGranter struct {
+	supportGrantType string
+	tokenEnhancer    TokenEnhancer
+	clientService    ClientDetailsService
+	tokenService     TokenService
+}
+
+func NewTokenExchangeGranter(grantType string, tokenEnhancer TokenEnhancer, clientService ClientDetailsService, tokenService TokenService) TokenGranter {
+	return &TokenExchangeGranter{
+		supportGrantType: grantType,
+		tokenEnhancer:    tokenEnhancer,
+		clientService:    clientService,
+		tokenService:     tokenService,
+	}
+}
+
+func (tokenGranter *TokenExchangeGranter) Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error) {
+	if grantType != tokenGranter.supportGrantType {
+		return nil, ErrNotSupportGrantType
+	}
+	// 调用方记录在 act 声明中，公开客户端无法证明自身身份
+	if client.Public {
+		return nil, ErrUnauthorizedClient
+	}
+	subjectTokenValue := reader.FormValue("subject_token")
+	subjectTokenType := reader.FormValue("subject_token_type")
+	if subjectTokenValue == "" || subjectTokenType == "" {
+		return nil, ErrMissingSubjectToken
+	}
+	if !isExchangeableTokenType(subjectTokenType) {
+		return nil, ErrUnsupportedTokenType
+	}
+	if requestedTokenType := reader.FormValue("requested_token_type"); requestedTokenType != "" && !isExchangeableTokenType(requestedTokenType) {
+		return nil, ErrUnsupportedTokenType
+	}
+	if reader.FormValue("actor_token") != "" {
+		return nil, ErrActorTokenNotSupported
+	}
+	subjectToken, subjectDetails, err := tokenGranter.extractSubjectToken(subjectTokenValue)
+	if err != nil {
+		return nil, err
+	}
+	// 只能交换签发给调用方或以调用方为受众的令牌
+	subjectAudience := subjectDetails.Audience
+	if subjectAudience == "" {
+		subjectAudience = subjectDetails.Client.ClientId
+	}
+	if subjectAudience != client.ClientId {
+		return nil, ErrSubjectTokenNotForClient
+	}
+	if subjectDetails.Actor.Depth() >= MaxActorDepth {
+		return nil, ErrActorChainTooDeep
+	}
+	audience, err := tokenGranter.resolveAudience(ctx, reader.Form["audience"])
+	if err != nil {
+		return nil, err
+	}
+	// 新令牌的范围不能超过原令牌和调用方客户端各自的范围
+	var granted []string
+	for _, scope := range subjectDetails.Scope {
+		if client.IsScopeAuthorized(scope) {
+			granted = append(granted, scope)
+		}
+	}
+	scope, err := ResolveScope(reader.FormValue("scope"), granted)
+	if err != nil {
+		return nil, err
+	}
+	// 多次交换时沿用最初授权的客户端
+	originClientId := subjectDetails.OriginClientId
+	if originClientId == "" {
+		originClientId = subjectDetails.Client.ClientId
+	}
+	return tokenGranter.tokenService.CreateAccessToken(&model.OAuth2Details{
+		Client:         client,
+		User:           subjectDetails.User,
+		Scope:          scope,
+		Audience:       audience,
+		OriginClientId: originClientId,
+		// 原令牌已经是交换得到的令牌时，之前的调用方嵌套在内层
+		Actor: &model.Actor{
+			Subject: client.ClientId,
+			Actor:   subjectDetails.Actor,
+		},
+		NotAfter: subjectToken.ExpriesTime,
+	})
+}
+
+// extractSubjectToken 通过 TokenEnhancer 校验签名和有效期，再从令牌存储中读取授权信息，
+// 确认令牌确由本服务签发、未被吊销且代表用户
+func (tokenGranter *TokenExchangeGranter) extractSubjectToken(tokenValue string) (*model.OAuth2Token, *model.OAuth2Details, error) {
+	subjectToken, _, err := tokenGranter.tokenEnhancer.Extract(tokenValue)
+	if err != nil || subjectToken.IsExpired() || subjectToken.ExpriesTime == nil {
+		return nil, nil, ErrInvalidSubjectToken
+	}
+	subjectDetails, err := tokenGranter.tokenService.GetOAuth2DetailsByAccessToken(tokenValue)
+	if err != nil || subjectDetails.User == nil || subjectDetails.Client == nil {
+		return nil, nil, ErrInvalidSubjectToken
+	}
+	return subjectToken, subjectDetails, nil
+}
+
+// resolveAudience 受众必须是已注册且未停用的客户端，未指定时沿用调用方的客户端 ID
+func (tokenGranter *TokenExchangeGranter) resolveAudience(ctx context.Context, audiences []string) (string, error) {
+	if len(audiences) == 0 || audiences[0] == "" {
+		return "", nil
+	}
+	if len(audiences) > 1 {
+		return "", ErrMultipleAudiences
+	}
+	if _, err := tokenGranter.clientService.GetClientDetailById(ctx, audiences[0]); err != nil {
+		if err == model.ErrClientNotFound || err == ErrClientDisabled {
+			return "", ErrInvalidTokenAudience
+		}
+		return "", err
+	}
+	return audiences[0], nil
+}
+
+func isExchangeableTokenType(tokenType string) bool {
+	return tokenType == AccessTokenType || tokenType == JwtTokenType
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTokenExchangeGranter(t *testing.T) {
+	tests := []struct {
+		name string
+		// subjectToken 返回待交换的令牌，默认为签发给 gateway 的用户令牌
+		subjectToken func(t *testing.T, tokenService *DefaultTokenService, userToken *model.OAuth2Token) string
+		clientId     string
+		form         url.Values
+		wantErr      error
+	}{
+		{name: "exchange for a downstream audience", form: url.Values{"audience": {"order"}}},
+		{name: "narrowed scope", form: url.Values{"scope": {"read"}}},
+		{name: "scope beyond the subject token", form: url.Values{"scope": {"admin"}}, wantErr: ErrInvalidScope},
+		{name: "unregistered audience", form: url.Values{"audience": {"unknown"}}, wantErr: ErrInvalidTokenAudience},
+		{name: "multiple audiences", form: url.Values{"audience": {"order", "gateway"}}, wantErr: ErrMultipleAudiences},
+		{name: "token issued to another client", clientId: "order", wantErr: ErrSubjectTokenNotForClient},
+		{name: "public client", clientId: "spa", wantErr: ErrUnauthorizedClient},
+		{
+			name: "forged subject token",
+			subjectToken: func(t *testing.T, tokenService *DefaultTokenService, userToken *model.OAuth2Token) string {
+				expiredTime := time.Now().Add(time.Hour)
+				forged, err := NewJwtTokenEnhancer("other-secret").Enhance(&model.OAuth2Token{ExpriesTime: &expiredTime}, &model.OAuth2Details{
+					Client: newTestClient("gateway", false, "read", "write"),
+					User:   newTestUser(1, "alice"),
+					Scope:  []string{"read", "write"},
+				})
+				if err != nil {
+					t.Fatalf("Enhance() err = %v", err)
+				}
+				return forged.TokenValue
+			},
+			wantErr: ErrInvalidSubjectToken,
+		},
+		{
+			name: "revoked subject token",
+			subjectToken: func(t *testing.T, tokenService *DefaultTokenService, userToken *model.OAuth2Token) string {
+				if err := tokenService.RevokeToken(userToken.TokenValue, "access_token", newTestClient("gateway", false)); err != nil {
+					t.Fatalf("RevokeToken() err = %v", err)
+				}
+				return userToken.TokenValue
+			},
+			wantErr: ErrInvalidSubjectToken,
+		},
+		{
+			name: "client token without user",
+			subjectToken: func(t *testing.T, tokenService *DefaultTokenService, userToken *model.OAuth2Token) string {
+				clientToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+					Client: newTestClient("gateway", false, "read", "write"),
+					Scope:  []string{"read"},
+				})
+				if err != nil {
+					t.Fatalf("CreateAccessToken() err = %v", err)
+				}
+				return clientToken.TokenValue
+			},
+			wantErr: ErrInvalidSubjectToken,
+		},
+		{
+			name: "actor chain too deep",
+			subjectToken: func(t *testing.T, tokenService *DefaultTokenService, userToken *model.OAuth2Token) string {
+				exchanged, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+					Client: newTestClient("gateway", false, "read", "write"),
+					User:   newTestUser(1, "alice"),
+					Scope:  []string{"read"},
+					Actor:  &model.Actor{Subject: "a", Actor: &model.Actor{Subject: "b", Actor: &model.Actor{Subject: "c"}}},
+				})
+				if err != nil {
+					t.Fatalf("CreateAccessToken() err = %v", err)
+				}
+				return exchanged.TokenValue
+			},
+			wantErr: ErrActorChainTooDeep,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, tokenEnhancer := newTestTokenService()
+			clients := map[string]*model.ClientDetails{
+				"gateway": newTestClient("gateway", false, "read", "write"),
+				"order":   newTestClient("order", false, "read"),
+				"spa":     newTestClient("spa", true, "read"),
+			}
+			clientService := newMemoryClientDetailsService(clients["gateway"], clients["order"], clients["spa"])
+			granter := NewTokenExchangeGranter(TokenExchangeGrantType, tokenEnhancer, clientService, tokenService)
+			userToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+				Client: clients["gateway"],
+				User:   newTestUser(1, "alice"),
+				Scope:  []string{"read", "write"},
+			})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			subjectTokenValue := userToken.TokenValue
+			if tt.subjectToken != nil {
+				subjectTokenValue = tt.subjectToken(t, tokenService, userToken)
+			}
+			clientId := tt.clientId
+			if clientId == "" {
+				clientId = "gateway"
+			}
+			form := url.Values{
+				"subject_token":      {subjectTokenValue},
+				"subject_token_type": {AccessTokenType},
+			}
+			for key, values := range tt.form {
+				form[key] = values
+			}
+			exchanged, err := granter.Grant(context.Background(), TokenExchangeGrantType, clients[clientId], newFormRequest(form))
+			if err != tt.wantErr {
+				t.Fatalf("Grant() err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			details, err := tokenService.GetOAuth2DetailsByAccessToken(exchanged.TokenValue)
+			if err != nil {
+				t.Fatalf("GetOAuth2DetailsByAccessToken() err = %v", err)
+			}
+			if details.Actor == nil || details.Actor.Subject != clientId {
+				t.Fatalf("actor = %+v, want %s", details.Actor, clientId)
+			}
+			if details.User == nil || details.User.UserId != 1 {
+				t.Fatalf("user = %+v, want user 1", details.User)
+			}
+			if audience := form.Get("audience"); !details.IsIntendedFor(audience) || (audience != "" && details.IsIntendedFor("gateway")) {
+				t.Fatalf("audience = %q, want %q", details.Audience, audience)
+			}
+			if exchanged.RefreshToken != nil {
+				t.Fatal("exchanged token carries a refresh token")
+			}
+			if exchanged.ExpriesTime.After(*userToken.ExpriesTime) {
+				t.Fatalf("exchanged token expires at %v, after the subject token %v", exchanged.ExpriesTime, userToken.ExpriesTime)
+			}
+		})
+	}
+}
+
+func TestExchangedTokenRevocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// revoke 在交换之后执行吊销
+		revoke    func(t *testing.T, tokenService *DefaultTokenService)
+		wantValid bool
+	}{
+		{name: "not revoked", revoke: func(t *testing.T, tokenService *DefaultTokenService) {}, wantValid: true},
+		{
+			name: "origin client disabled",
+			revoke: func(t *testing.T, tokenService *DefaultTokenService) {
+				if err := tokenService.RevokeClientTokens("spa"); err != nil {
+					t.Fatalf("RevokeClientTokens() err = %v", err)
+				}
+			},
+		},
+		{
+			name: "user revoked for the origin client",
+			revoke: func(t *testing.T, tokenService *DefaultTokenService) {
+				// 吊销时间只精确到秒，取下一秒确保交换得到的令牌早于吊销时间
+				if err := tokenService.tokenDenylist.(UserRevocationList).RevokeUser(1, "spa", time.Now().Add(time.Second)); err != nil {
+					t.Fatalf("RevokeUser() err = %v", err)
+				}
+			},
+		},
+		{
+			name: "user revoked for an unrelated client",
+			revoke: func(t *testing.T, tokenService *DefaultTokenService) {
+				if err := tokenService.tokenDenylist.(UserRevocationList).RevokeUser(1, "order", time.Now().Add(time.Second)); err != nil {
+					t.Fatalf("RevokeUser() err = %v", err)
+				}
+			},
+			wantValid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, tokenEnhancer := newTestTokenService()
+			gateway := newTestClient("gateway", false, "read")
+			clientService := newMemoryClientDetailsService(gateway, newTestClient("order", false, "read"))
+			granter := NewTokenExchangeGranter(TokenExchangeGrantType, tokenEnhancer, clientService, tokenService)
+			// 用户在 spa 登录，令牌以网关为受众
+			userToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+				Client:   newTestClient("spa", true, "read"),
+				User:     newTestUser(1, "alice"),
+				Scope:    []string{"read"},
+				Audience: "gateway",
+			})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			form := url.Values{
+				"subject_token":      {userToken.TokenValue},
+				"subject_token_type": {AccessTokenType},
+				"audience":           {"order"},
+			}
+			exchanged, err := granter.Grant(context.Background(), TokenExchangeGrantType, gateway, newFormRequest(form))
+			if err != nil {
+				t.Fatalf("Grant() err = %v", err)
+			}
+			tt.revoke(t, tokenService)
+			details, err := tokenService.GetOAuth2DetailsByAccessToken(exchanged.TokenValue)
+			if valid := err == nil; valid != tt.wantValid {
+				t.Fatalf("exchanged token valid = %v (err = %v), want %v", valid, err, tt.wantValid)
+			}
+			if err == nil && details.OriginClientId != "spa" {
+				t.Fatalf("origin client = %q, want spa", details.OriginClientId)
+			}
+		})
+	}
+}
@@ -3,28 +3,77 @@ package service
 import (
 	"SecondKill/oauth-service/model"
 	"context"
-	"errors"
 	"github.com/dgrijalva/jwt-go"
 	uuid "github.com/satori/go.uuid"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	ErrNotSupportGrantType               = errors.New("grant type is not supported")
-	ErrNotSupportOperation               = errors.New("no support operation")
-	ErrInvalidUsernameAndPasswordRequest = errors.New("invalid username, password")
-	ErrInvalidTokenRequest               = errors.New("invalid token")
-	ErrExpiredToken                      = errors.New("token is expired")
+	ErrNotSupportGrantType               = NewOAuth2Error(ErrorCodeUnsupportedGrantType, "grant type is not supported")
+	ErrNotSupportOperation               = NewOAuth2Error(ErrorCodeServerError, "no support operation")
+	ErrInvalidUsernameAndPasswordRequest = NewOAuth2Error(ErrorCodeInvalidGrant, "invalid username, password")
+	ErrMissingUsernameAndPassword        = NewOAuth2Error(ErrorCodeInvalidRequest, "username and password are required")
+	ErrMissingRefreshToken               = NewOAuth2Error(ErrorCodeInvalidRequest, "refresh_token is required")
+	ErrInvalidTokenRequest               = NewOAuth2Error(ErrorCodeInvalidGrant, "invalid token")
+	ErrExpiredToken                      = NewOAuth2Error(ErrorCodeInvalidGrant, "token is expired")
+	ErrUnauthorizedClient                = NewOAuth2Error(ErrorCodeUnauthorizedClient, "client is not authorized to use this grant type")
+	ErrRevokedToken                      = NewOAuth2Error(ErrorCodeInvalidGrant, "token is revoked")
+	ErrTokenNotIssuedToClient            = NewOAuth2Error(ErrorCodeUnauthorizedClient, "token was not issued to this client")
+	ErrInvalidScope                      = NewOAuth2Error(ErrorCodeInvalidScope, "requested scope is invalid or exceeds the granted scope")
+	ErrRefreshTokenReused                = NewOAuth2Error(ErrorCodeInvalidGrant, "refresh token has already been used")
 )
 
+// maxTokenValidity 令牌有效期的上限，签发时截断超出的部分，吊销标记保留这么久即可覆盖此前签发的全部令牌
+const maxTokenValidity = 30 * 24 * time.Hour
+
+// ResolveScope 解析以空格分隔的 scope 参数，未申请时授予 granted 的全部范围，
+// 申请的范围超出 granted 时返回 ErrInvalidScope
+func ResolveScope(requested string, granted []string) ([]string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return granted, nil
+	}
+	for _, scope := range scopes {
+		if !containsScope(granted, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	return scopes, nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 type TokenGranter interface {
 	Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error)
 }
 
+// TokenGrantPolicy 在分发授权请求前对客户端做额外的限制，如 IP 网段、可用时间段等
+type TokenGrantPolicy interface {
+	Check(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) error
+}
+
+// TokenGrantPolicyFunc 使普通函数可以作为 TokenGrantPolicy 使用
+type TokenGrantPolicyFunc func(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) error
+
+func (f TokenGrantPolicyFunc) Check(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) error {
+	return f(ctx, grantType, client, reader)
+}
+
 type ComposeTokenGranter struct {
 	TokenGrantDict map[string]TokenGranter
+	// 客户端注册的授权类型校验通过后依次执行的策略
+	Policies []TokenGrantPolicy
 }
 
 func (tokenGranter *ComposeTokenGranter) Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error) {
@@ -32,15 +81,30 @@ func (tokenGranter *ComposeTokenGranter) Grant(ctx context.Context, grantType st
 	if dispathcGranter == nil {
 		return nil, ErrNotSupportGrantType
 	}
+	if !client.IsGrantTypeAuthorized(grantType) {
+		return nil, ErrUnauthorizedClient
+	}
+	for _, policy := range tokenGranter.Policies {
+		if err := policy.Check(ctx, grantType, client, reader); err != nil {
+			return nil, err
+		}
+	}
 	return dispathcGranter.Grant(ctx, grantType, client, reader)
 }
 
-func NewComposeTokenGrante(tokenGrantDict map[string]TokenGranter) TokenGranter {
+// AddPolicy 追加客户端授权策略
+func (tokenGranter *ComposeTokenGranter) AddPolicy(policy TokenGrantPolicy) {
+	tokenGranter.Policies = append(tokenGranter.Policies, policy)
+}
+
+func NewComposeTokenGrante(tokenGrantDict map[string]TokenGranter, policies ...TokenGrantPolicy) TokenGranter {
 	return &ComposeTokenGranter{
 		TokenGrantDict: tokenGrantDict,
+		Policies:       policies,
 	}
 }
 
+// UsernamePasswordTokenGranter 登录失败次数由 LoginAttemptUserDetailsService 统一限制
 type UsernamePasswordTokenGranter struct {
 	supportGrantType   string
 	userDetailsService UserDetailsService
@@ -55,17 +119,26 @@ func (tokenGranter *UsernamePasswordTokenGranter) Grant(ctx context.Context, gra
 	username := reader.FormValue("username")
 	password := reader.FormValue("password")
 	if username == "" || password == "" {
-		return nil, ErrInvalidUsernameAndPasswordRequest
+		return nil, ErrMissingUsernameAndPassword
 	}
 	// 验证用户名密码是否正确
 	userDetails, err := tokenGranter.userDetailsService.GetUserDetailByUserName(ctx, username, password)
 	if err != nil {
+		// 账号锁定和用户服务不可用等错误原样返回
+		if !isCredentialError(err) {
+			return nil, err
+		}
 		return nil, ErrInvalidUsernameAndPasswordRequest
 	}
+	scope, err := ResolveScope(reader.FormValue("scope"), client.Scope)
+	if err != nil {
+		return nil, err
+	}
 	// 根据用户信息和客户端信息生成访问令牌
 	return tokenGranter.tokenService.CreateAccessToken(&model.OAuth2Details{
 		Client: client,
 		User:   userDetails,
+		Scope:  scope,
 	})
 }
 
@@ -93,15 +166,47 @@ func (tokenGranter *RefreshTokenGranter) Grant(ctx context.Context, grantType st
 	if grantType != tokenGranter.supportGranteType {
 		return nil, ErrNotSupportGrantType
 	}
-	// 从请求中获取刷新令牌
-	refreshTokenValue := reader.URL.Query().Get("refresh_token")
+	// 从请求体或查询参数中获取刷新令牌
+	refreshTokenValue := reader.FormValue("refresh_token")
 
 	if refreshTokenValue == "" {
-		return nil, ErrInvalidTokenRequest
+		return nil, ErrMissingRefreshToken
 	}
 
-	return tokenGranter.tokenService.RefreshAccessToken(refreshTokenValue)
+	// 刷新时只能申请原授权范围的子集
+	return tokenGranter.tokenService.RefreshAccessToken(refreshTokenValue, reader.FormValue("scope"))
+
+}
+
+type ClientCredentialsTokenGranter struct {
+	supportGrantType string
+	tokenService     TokenService
+}
 
+func NewClientCredentialsTokenGranter(grantType string, tokenService TokenService) TokenGranter {
+	return &ClientCredentialsTokenGranter{
+		supportGrantType: grantType,
+		tokenService:     tokenService,
+	}
+}
+
+func (tokenGranter *ClientCredentialsTokenGranter) Grant(ctx context.Context, grantType string, client *model.ClientDetails, reader *http.Request) (*model.OAuth2Token, error) {
+	if grantType != tokenGranter.supportGrantType {
+		return nil, ErrNotSupportGrantType
+	}
+	// 客户端以自身身份申请令牌，必须显式注册该授权类型，公开客户端无法证明自身身份
+	if !client.IsGrantTypeAuthorized(grantType) || client.Public {
+		return nil, ErrUnauthorizedClient
+	}
+	scope, err := ResolveScope(reader.FormValue("scope"), client.Scope)
+	if err != nil {
+		return nil, err
+	}
+	// 令牌不关联任何用户
+	return tokenGranter.tokenService.CreateAccessToken(&model.OAuth2Details{
+		Client: client,
+		Scope:  scope,
+	})
 }
 
 type TokenService interface {
@@ -109,47 +214,65 @@ type TokenService interface {
 	GetOAuth2DetailsByAccessToken(tokenValue string) (*model.OAuth2Details, error)
 	// 根据用户信息和客户端信息生成访问令牌
 	CreateAccessToken(oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error)
-	// 根据刷新令牌获取访问令牌
-	RefreshAccessToken(refreshTokenValue string) (*model.OAuth2Token, error)
+	// 根据刷新令牌获取访问令牌，scope 为空时沿用原授权范围
+	RefreshAccessToken(refreshTokenValue string, scope string) (*model.OAuth2Token, error)
 	// 根据用户信息和客户端信息获取已生成访问令牌
 	GetAccessToken(details *model.OAuth2Details) (*model.OAuth2Token, error)
 	// 根据访问令牌值获取访问令牌结构体
 	ReadAccessToken(tokenValue string) (*model.OAuth2Token, error)
+	// 吊销客户端的访问令牌或刷新令牌，tokenTypeHint 为 access_token 或 refresh_token
+	RevokeToken(tokenValue string, tokenTypeHint string, client *model.ClientDetails) error
+	// 吊销用户的全部访问令牌和刷新令牌，clientId 不为空时只吊销该客户端的令牌，返回吊销的令牌数量
+	RevokeUserTokens(userId int64, clientId string) (int, error)
+	// 停用客户端后吊销其已签发的全部令牌
+	RevokeClientTokens(clientId string) error
 }
 
 type DefaultTokenService struct {
 	tokenStore    TokenStore
 	tokenEnhancer TokenEnhancer
+	tokenDenylist TokenDenylist
+	auditLogger   AuditLogger
 }
 
-func NewTokenService(tokenStore TokenStore, tokenEnhancer TokenEnhancer) TokenService {
+func NewTokenService(tokenStore TokenStore, tokenEnhancer TokenEnhancer, tokenDenylist TokenDenylist, auditLogger AuditLogger) TokenService {
 	return &DefaultTokenService{
 		tokenStore:    tokenStore,
 		tokenEnhancer: tokenEnhancer,
+		tokenDenylist: tokenDenylist,
+		auditLogger:   auditLogger,
 	}
 }
 
 func (tokenService *DefaultTokenService) GetOAuth2DetailsByAccessToken(tokenValue string) (*model.OAuth2Details, error) {
-	return tokenService.tokenStore.ReadOAuth2Details(tokenValue)
+	accessToken, err := tokenService.tokenStore.ReadAccessToken(tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	oauth2Details, err := tokenService.tokenStore.ReadOAuth2Details(tokenValue)
+	if err != nil {
+		return nil, err
+	}
+	if tokenService.isRevoked(accessToken, oauth2Details) {
+		return nil, ErrRevokedToken
+	}
+	return oauth2Details, nil
 }
 
 func (tokenService *DefaultTokenService) CreateAccessToken(oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
-	existToken, err := tokenService.tokenStore.GetAccessToken(oauth2Details)
 	var refreshToken *model.OAuth2Token
-	if err == nil {
-		if !existToken.IsExpired() {
-			tokenService.tokenStore.StoreAccessToken(existToken, oauth2Details)
-			return existToken, nil
-		} else {
-			tokenService.tokenStore.RemoveAccessToken(existToken.TokenValue)
-			if existToken.RefreshToken != nil {
-				refreshToken = existToken.RefreshToken
-				tokenService.tokenStore.RemoveRefreshToken(refreshToken.TokenValue)
+	var err error
+	if oauth2Details.User == nil {
+		// 客户端令牌未过期时复用
+		if existToken, err := tokenService.tokenStore.GetAccessToken(oauth2Details); err == nil {
+			if !existToken.IsExpired() && !exceedsNotAfter(existToken, oauth2Details) {
+				tokenService.tokenStore.StoreAccessToken(existToken, oauth2Details)
+				return existToken, nil
 			}
+			tokenService.tokenStore.RemoveAccessToken(existToken.TokenValue)
 		}
-	}
-	// 刷新时间和创建时间不一样
-	if refreshToken == nil || refreshToken.IsExpired() {
+	} else if oauth2Details.Actor == nil {
+		// 每次用户授权都签发新的刷新令牌和家族，两次登录不共用刷新令牌；令牌交换签发的令牌不签发刷新令牌
 		refreshToken, err = tokenService.createRefreshToken(oauth2Details)
 		if err != nil {
 			return nil, err
@@ -160,33 +283,78 @@ func (tokenService *DefaultTokenService) CreateAccessToken(oauth2Details *model.
 	if err == nil {
 		// 保存新生成令牌
 		tokenService.tokenStore.StoreAccessToken(accessToken, oauth2Details)
-		tokenService.tokenStore.StoreRefreshToken(refreshToken, oauth2Details)
+		if refreshToken != nil {
+			tokenService.tokenStore.StoreRefreshToken(refreshToken, oauth2Details)
+		}
+		tokenService.storeTokenFamily(accessToken)
 	}
 	return accessToken, err
 }
 
 func (tokenService *DefaultTokenService) createAccessToken(refreshToken *model.OAuth2Token, oauth2Detail *model.OAuth2Details) (*model.OAuth2Token, error) {
-	validitySeconds := oauth2Detail.Client.AccessTokenValiditySeconds
-	s, _ := time.ParseDuration(strconv.Itoa(validitySeconds) + "s")
-	expiredTime := time.Now().Add(s)
+	issuedAt := time.Now()
+	expiredTime := tokenExpiresTime(issuedAt, oauth2Detail.Client.AccessTokenValiditySeconds)
+	if oauth2Detail.NotAfter != nil && oauth2Detail.NotAfter.Before(expiredTime) {
+		expiredTime = *oauth2Detail.NotAfter
+	}
 	access := &model.OAuth2Token{
 		RefreshToken: refreshToken,
+		IssuedAt:     &issuedAt,
 		ExpriesTime:  &expiredTime,
 		TokenValue:   uuid.NewV4().String(),
+		Scope:        oauth2Detail.Scope,
 	}
 	if tokenService.tokenEnhancer != nil {
-		return tokenService.tokenEnhancer.Enhance(access, oauth2Detail)
+		var err error
+		if access, err = tokenService.tokenEnhancer.Enhance(access, oauth2Detail); err != nil {
+			return nil, err
+		}
 	}
-	return access, nil
+	return access, tokenService.issueIdToken(access, oauth2Detail)
+}
+
+// tokenExpiresTime 按客户端配置的有效期计算过期时间，不超过 maxTokenValidity
+func tokenExpiresTime(issuedAt time.Time, validitySeconds int) time.Time {
+	validity := time.Duration(validitySeconds) * time.Second
+	if validity > maxTokenValidity {
+		validity = maxTokenValidity
+	}
+	return issuedAt.Add(validity)
+}
+
+// exceedsNotAfter 已签发的令牌晚于本次授权限定的过期时间时不能复用
+func exceedsNotAfter(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) bool {
+	return oauth2Details.NotAfter != nil &&
+		(oauth2Token.ExpriesTime == nil || oauth2Token.ExpriesTime.After(*oauth2Details.NotAfter))
+}
+
+// issueIdToken 用户令牌申请了 openid 范围时附带 id_token，令牌交换签发的令牌供服务间调用，不附带 id_token
+func (tokenService *DefaultTokenService) issueIdToken(accessToken *model.OAuth2Token, oauth2Details *model.OAuth2Details) error {
+	if oauth2Details.User == nil || oauth2Details.Actor != nil || !oauth2Details.HasScope(OpenIdScope) {
+		return nil
+	}
+	issuer, ok := tokenService.tokenEnhancer.(IdTokenIssuer)
+	if !ok {
+		return nil
+	}
+	idToken, err := issuer.IssueIdToken(accessToken, oauth2Details)
+	if err != nil {
+		return err
+	}
+	accessToken.IdToken = idToken
+	return nil
 }
 
 func (tokenService *DefaultTokenService) createRefreshToken(oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
-	validitySeconds := oauth2Details.Client.RefreshTokenValiditySeconds
-	s, _ := time.ParseDuration(strconv.Itoa(validitySeconds) + "s")
-	expiredTime := time.Now().Add(s)
+	issuedAt := time.Now()
+	expiredTime := tokenExpiresTime(issuedAt, oauth2Details.Client.RefreshTokenValiditySeconds)
 	refreshToken := &model.OAuth2Token{
+		IssuedAt:    &issuedAt,
 		ExpriesTime: &expiredTime,
 		TokenValue:  uuid.NewV4().String(),
+		Scope:       oauth2Details.Scope,
+		// 新签发的刷新令牌开启一个新的家族
+		FamilyId: uuid.NewV4().String(),
 	}
 
 	if tokenService.tokenEnhancer != nil {
@@ -195,32 +363,140 @@ func (tokenService *DefaultTokenService) createRefreshToken(oauth2Details *model
 	return refreshToken, nil
 }
 
-func (tokenService *DefaultTokenService) RefreshAccessToken(refreshTokenValue string) (*model.OAuth2Token, error) {
+// RefreshAccessToken 每次刷新都轮换刷新令牌，已轮换的刷新令牌再次出现时吊销整个令牌家族
+func (tokenService *DefaultTokenService) RefreshAccessToken(refreshTokenValue string, scope string) (*model.OAuth2Token, error) {
 	refreshToken, err := tokenService.tokenStore.ReadRefreshToken(refreshTokenValue)
-	if err == nil {
-		if refreshToken.IsExpired() {
-			return nil, ErrExpiredToken
+	if err != nil {
+		if familyId, ok := tokenService.usedRefreshTokenFamily(refreshTokenValue); ok {
+			tokenService.revokeTokenFamily(familyId)
+			return nil, ErrRefreshTokenReused
 		}
-		oauth2Details, err := tokenService.tokenStore.ReadOAuth2DetailsForRefreshToken(refreshTokenValue)
-		if err == nil {
-			oauth2Token, err := tokenService.tokenStore.GetAccessToken(oauth2Details)
-			if err == nil {
-				tokenService.tokenStore.RemoveAccessToken(oauth2Token.TokenValue)
-			}
-			// 移除已使用的刷新令牌
-			tokenService.tokenStore.RemoveRefreshToken(refreshTokenValue)
-			refreshToken, err = tokenService.createRefreshToken(oauth2Details)
-			if err == nil {
-				accessToken, err := tokenService.createAccessToken(refreshToken, oauth2Details)
-				if err == nil {
-					tokenService.tokenStore.StoreAccessToken(accessToken, oauth2Details)
-					tokenService.tokenStore.StoreRefreshToken(refreshToken, oauth2Details)
+		return nil, err
+	}
+	if refreshToken.IsExpired() {
+		return nil, ErrExpiredToken
+	}
+	if tokenService.isFamilyCompromised(refreshToken.FamilyId) {
+		return nil, ErrRefreshTokenReused
+	}
+	oauth2Details, err := tokenService.tokenStore.ReadOAuth2DetailsForRefreshToken(refreshTokenValue)
+	if err != nil {
+		return nil, err
+	}
+	if tokenService.isRevoked(refreshToken, oauth2Details) {
+		return nil, ErrRevokedToken
+	}
+	narrowedScope, err := ResolveScope(scope, oauth2Details.Scope)
+	if err != nil {
+		return nil, err
+	}
+	// 先标记为已使用，并发的刷新请求只有一个能完成轮换
+	if familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore); ok && refreshToken.FamilyId != "" {
+		firstUse, err := familyStore.MarkRefreshTokenUsed(refreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if !firstUse {
+			tokenService.revokeTokenFamily(refreshToken.FamilyId)
+			return nil, ErrRefreshTokenReused
+		}
+		if accessTokenValue, _, err := familyStore.ReadTokenFamily(refreshToken.FamilyId); err == nil {
+			tokenService.tokenStore.RemoveAccessToken(accessTokenValue)
+		}
+	} else if oauth2Token, err := tokenService.tokenStore.GetAccessToken(oauth2Details); err == nil {
+		tokenService.tokenStore.RemoveAccessToken(oauth2Token.TokenValue)
+	}
+	// 移除已使用的刷新令牌
+	tokenService.tokenStore.RemoveRefreshToken(refreshTokenValue)
+
+	newRefreshToken, err := tokenService.createRefreshToken(oauth2Details)
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken.FamilyId != "" {
+		newRefreshToken.FamilyId = refreshToken.FamilyId
+	}
+	// 新的刷新令牌保留原授权范围，访问令牌使用缩小后的范围
+	accessDetails := *oauth2Details
+	accessDetails.Scope = narrowedScope
+	accessToken, err := tokenService.createAccessToken(newRefreshToken, &accessDetails)
+	if err != nil {
+		return nil, err
+	}
+	tokenService.tokenStore.StoreAccessToken(accessToken, &accessDetails)
+	tokenService.tokenStore.StoreRefreshToken(newRefreshToken, oauth2Details)
+	tokenService.storeTokenFamily(accessToken)
+	// 并发的复用请求可能在记录家族之前已经完成吊销，记录之后再检查一次，保证新令牌不会漏掉
+	if tokenService.isFamilyCompromised(newRefreshToken.FamilyId) {
+		tokenService.revokeTokenFamily(newRefreshToken.FamilyId)
+		return nil, ErrRefreshTokenReused
+	}
+	return accessToken, nil
+}
+
+// usedRefreshTokenFamily 查询已轮换的刷新令牌所属的家族
+func (tokenService *DefaultTokenService) usedRefreshTokenFamily(refreshTokenValue string) (string, bool) {
+	familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore)
+	if !ok {
+		return "", false
+	}
+	familyId, err := familyStore.ReadUsedRefreshTokenFamily(refreshTokenValue)
+	return familyId, err == nil
+}
+
+// storeTokenFamily 记录家族当前有效的访问令牌和刷新令牌，复用检测时据此吊销
+func (tokenService *DefaultTokenService) storeTokenFamily(accessToken *model.OAuth2Token) {
+	familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore)
+	if !ok || accessToken.RefreshToken == nil || accessToken.RefreshToken.FamilyId == "" {
+		return
+	}
+	familyStore.StoreTokenFamily(accessToken.RefreshToken.FamilyId, accessToken, accessToken.RefreshToken)
+}
+
+// isFamilyCompromised 家族被标记为已泄露时其中的令牌全部失效，无法确认时按已泄露处理
+func (tokenService *DefaultTokenService) isFamilyCompromised(familyId string) bool {
+	familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore)
+	if !ok || familyId == "" {
+		return false
+	}
+	compromised, err := familyStore.IsTokenFamilyCompromised(familyId)
+	return compromised || err != nil
+}
+
+// revokeTokenFamily 先将家族标记为已泄露，正在轮换的请求据此放弃新令牌，再吊销家族当前有效的令牌并记录审计事件
+func (tokenService *DefaultTokenService) revokeTokenFamily(familyId string) {
+	familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore)
+	if !ok {
+		return
+	}
+	if err := familyStore.MarkTokenFamilyCompromised(familyId); err != nil {
+		log.Printf("mark token family compromised err : %v", err)
+	}
+	event := &AuditEvent{
+		Type:     AuditRefreshTokenReused,
+		FamilyId: familyId,
+		Time:     time.Now(),
+	}
+	if accessTokenValue, refreshTokenValue, err := familyStore.ReadTokenFamily(familyId); err == nil {
+		if accessToken, err := tokenService.tokenStore.ReadAccessToken(accessTokenValue); err == nil {
+			tokenService.tokenStore.RemoveAccessToken(accessTokenValue)
+			tokenService.deny(accessToken)
+		}
+		if refreshToken, err := tokenService.tokenStore.ReadRefreshToken(refreshTokenValue); err == nil {
+			if oauth2Details, err := tokenService.tokenStore.ReadOAuth2DetailsForRefreshToken(refreshTokenValue); err == nil {
+				event.ClientId = oauth2Details.Client.ClientId
+				if oauth2Details.User != nil {
+					event.UserId = oauth2Details.User.UserId
 				}
-				return accessToken, err
 			}
+			tokenService.tokenStore.RemoveRefreshToken(refreshTokenValue)
+			tokenService.deny(refreshToken)
 		}
+		familyStore.RemoveTokenFamily(familyId)
+	}
+	if tokenService.auditLogger != nil {
+		tokenService.auditLogger.Audit(event)
 	}
-	return nil, err
 }
 
 func (tokenService *DefaultTokenService) GetAccessToken(details *model.OAuth2Details) (*model.OAuth2Token, error) {
@@ -231,6 +507,276 @@ func (tokenService *DefaultTokenService) ReadAccessToken(tokenValue string) (*mo
 	return tokenService.tokenStore.ReadAccessToken(tokenValue)
 }
 
+// RevokeToken 按 RFC 7009 吊销令牌，令牌无效或已过期时直接返回成功
+func (tokenService *DefaultTokenService) RevokeToken(tokenValue string, tokenTypeHint string, client *model.ClientDetails) error {
+	if tokenTypeHint == "refresh_token" {
+		if revoked, err := tokenService.revokeRefreshToken(tokenValue, client); revoked || err != nil {
+			return err
+		}
+		_, err := tokenService.revokeAccessToken(tokenValue, client)
+		return err
+	}
+	if revoked, err := tokenService.revokeAccessToken(tokenValue, client); revoked || err != nil {
+		return err
+	}
+	_, err := tokenService.revokeRefreshToken(tokenValue, client)
+	return err
+}
+
+func (tokenService *DefaultTokenService) revokeAccessToken(tokenValue string, client *model.ClientDetails) (bool, error) {
+	accessToken, err := tokenService.tokenStore.ReadAccessToken(tokenValue)
+	if err != nil {
+		return false, nil
+	}
+	oauth2Details, err := tokenService.tokenStore.ReadOAuth2Details(tokenValue)
+	if err != nil {
+		return false, nil
+	}
+	if oauth2Details.Client.ClientId != client.ClientId {
+		return false, ErrTokenNotIssuedToClient
+	}
+	tokenService.tokenStore.RemoveAccessToken(tokenValue)
+	return true, tokenService.deny(accessToken)
+}
+
+func (tokenService *DefaultTokenService) revokeRefreshToken(tokenValue string, client *model.ClientDetails) (bool, error) {
+	refreshToken, err := tokenService.tokenStore.ReadRefreshToken(tokenValue)
+	if err != nil {
+		return false, nil
+	}
+	oauth2Details, err := tokenService.tokenStore.ReadOAuth2DetailsForRefreshToken(tokenValue)
+	if err != nil {
+		return false, nil
+	}
+	if oauth2Details.Client.ClientId != client.ClientId {
+		return false, ErrTokenNotIssuedToClient
+	}
+	// 刷新令牌被吊销时，一并吊销由它签发的访问令牌
+	if accessToken, err := tokenService.readIssuedAccessToken(refreshToken, oauth2Details); err == nil &&
+		accessToken.RefreshToken != nil && accessToken.RefreshToken.TokenValue == tokenValue {
+		tokenService.tokenStore.RemoveAccessToken(accessToken.TokenValue)
+		if err := tokenService.deny(accessToken); err != nil {
+			return true, err
+		}
+	}
+	tokenService.tokenStore.RemoveRefreshToken(tokenValue)
+	return true, tokenService.deny(refreshToken)
+}
+
+// readIssuedAccessToken 读取刷新令牌最近签发的访问令牌，同一用户可能同时持有多个刷新令牌，优先按家族查找
+func (tokenService *DefaultTokenService) readIssuedAccessToken(refreshToken *model.OAuth2Token, oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
+	if familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore); ok && refreshToken.FamilyId != "" {
+		accessTokenValue, _, err := familyStore.ReadTokenFamily(refreshToken.FamilyId)
+		if err != nil {
+			return nil, err
+		}
+		return tokenService.tokenStore.ReadAccessToken(accessTokenValue)
+	}
+	return tokenService.tokenStore.GetAccessToken(oauth2Details)
+}
+
+// deny 自包含的 JWT 无法从存储中删除，记录其 jti 直到令牌过期
+func (tokenService *DefaultTokenService) deny(oauth2Token *model.OAuth2Token) error {
+	if tokenService.tokenDenylist == nil || oauth2Token.TokenId == "" {
+		return nil
+	}
+	return tokenService.tokenDenylist.Deny(oauth2Token.TokenId, oauth2Token.ExpriesTime)
+}
+
+func (tokenService *DefaultTokenService) isRevoked(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) bool {
+	return isTokenRevoked(tokenService.tokenDenylist, oauth2Token, oauth2Details) ||
+		tokenService.isFamilyCompromised(tokenFamilyId(oauth2Token))
+}
+
+// tokenFamilyId 刷新令牌记录自身的家族，访问令牌属于其刷新令牌的家族
+func tokenFamilyId(oauth2Token *model.OAuth2Token) string {
+	if oauth2Token.FamilyId != "" {
+		return oauth2Token.FamilyId
+	}
+	if oauth2Token.RefreshToken != nil {
+		return oauth2Token.RefreshToken.FamilyId
+	}
+	return ""
+}
+
+// isTokenRevoked 令牌的 jti 已被吊销，或签发时间早于用户的吊销时间时视为已吊销，无法确认时按已吊销处理
+func isTokenRevoked(tokenDenylist TokenDenylist, oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) bool {
+	if tokenDenylist == nil {
+		return false
+	}
+	if oauth2Token.TokenId != "" {
+		if denied, err := tokenDenylist.IsDenied(oauth2Token.TokenId); denied || err != nil {
+			return true
+		}
+	}
+	if oauth2Details == nil || oauth2Details.Client == nil {
+		return false
+	}
+	// 令牌交换签发的令牌同时受调用方和最初授权的客户端的吊销影响
+	clientIds := oauth2Details.ClientIds()
+	if clientRevocationList, ok := tokenDenylist.(ClientRevocationList); ok {
+		for _, clientId := range clientIds {
+			if revoked, err := clientRevocationList.IsClientRevoked(clientId); revoked || err != nil {
+				return true
+			}
+		}
+	}
+	revocationList, ok := tokenDenylist.(UserRevocationList)
+	if !ok || oauth2Details.User == nil {
+		return false
+	}
+	for _, clientId := range clientIds {
+		revokedAfter, err := revocationList.RevokedAfter(oauth2Details.User.UserId, clientId)
+		if err != nil {
+			return true
+		}
+		// JWT 的 iat 只精确到秒，与吊销时间同一秒签发的令牌不受影响，吊销时已签发的令牌通过索引逐个吊销
+		if !revokedAfter.IsZero() && (oauth2Token.IssuedAt == nil || oauth2Token.IssuedAt.Unix() < revokedAfter.Unix()) {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeUserTokens 先记录吊销时间使之前签发的令牌立即失效，再通过索引删除存储中的令牌
+func (tokenService *DefaultTokenService) RevokeUserTokens(userId int64, clientId string) (int, error) {
+	revocationList, hasRevocationList := tokenService.tokenDenylist.(UserRevocationList)
+	index, hasIndex := tokenService.tokenStore.(UserTokenIndex)
+	if !hasRevocationList && !hasIndex {
+		return 0, ErrNotSupportOperation
+	}
+	if hasRevocationList {
+		if err := revocationList.RevokeUser(userId, clientId, time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	revoked := 0
+	if hasIndex {
+		accessTokens, refreshTokens, err := index.ReadUserTokens(userId)
+		if err != nil {
+			return 0, err
+		}
+		var removedAccessTokens, removedRefreshTokens []string
+		for _, tokenValue := range accessTokens {
+			accessToken, err := tokenService.tokenStore.ReadAccessToken(tokenValue)
+			if err != nil {
+				// 已过期或已吊销的令牌只从索引中移除
+				removedAccessTokens = append(removedAccessTokens, tokenValue)
+				continue
+			}
+			oauth2Details, err := tokenService.tokenStore.ReadOAuth2Details(tokenValue)
+			if err != nil || (clientId != "" && oauth2Details.Client.ClientId != clientId) {
+				continue
+			}
+			tokenService.tokenStore.RemoveAccessToken(tokenValue)
+			if err = tokenService.deny(accessToken); err != nil {
+				return revoked, err
+			}
+			removedAccessTokens = append(removedAccessTokens, tokenValue)
+			revoked++
+		}
+		for _, tokenValue := range refreshTokens {
+			refreshToken, err := tokenService.tokenStore.ReadRefreshToken(tokenValue)
+			if err != nil {
+				removedRefreshTokens = append(removedRefreshTokens, tokenValue)
+				continue
+			}
+			oauth2Details, err := tokenService.tokenStore.ReadOAuth2DetailsForRefreshToken(tokenValue)
+			if err != nil || (clientId != "" && oauth2Details.Client.ClientId != clientId) {
+				continue
+			}
+			tokenService.tokenStore.RemoveRefreshToken(tokenValue)
+			if familyStore, ok := tokenService.tokenStore.(RefreshTokenFamilyStore); ok && refreshToken.FamilyId != "" {
+				familyStore.RemoveTokenFamily(refreshToken.FamilyId)
+			}
+			if err = tokenService.deny(refreshToken); err != nil {
+				return revoked, err
+			}
+			removedRefreshTokens = append(removedRefreshTokens, tokenValue)
+			revoked++
+		}
+		if err = index.RemoveUserTokens(userId, removedAccessTokens, removedRefreshTokens); err != nil {
+			return revoked, err
+		}
+	}
+	if tokenService.auditLogger != nil {
+		tokenService.auditLogger.Audit(&AuditEvent{
+			Type:     AuditUserTokensRevoked,
+			ClientId: clientId,
+			UserId:   userId,
+			Time:     time.Now(),
+		})
+	}
+	return revoked, nil
+}
+
+// RevokeClientTokens 停用的客户端不会重新启用，令牌存储中的令牌等待自然过期
+func (tokenService *DefaultTokenService) RevokeClientTokens(clientId string) error {
+	revocationList, ok := tokenService.tokenDenylist.(ClientRevocationList)
+	if !ok {
+		return ErrNotSupportOperation
+	}
+	if err := revocationList.RevokeClient(clientId); err != nil {
+		return err
+	}
+	if tokenService.auditLogger != nil {
+		tokenService.auditLogger.Audit(&AuditEvent{
+			Type:     AuditClientTokensRevoked,
+			ClientId: clientId,
+			Time:     time.Now(),
+		})
+	}
+	return nil
+}
+
+// RefreshTokenFamilyStore 记录刷新令牌家族，用于检测已轮换的刷新令牌被重复使用，
+// TokenStore 实现该接口时启用复用检测
+type RefreshTokenFamilyStore interface {
+	// 将刷新令牌标记为已使用，返回 false 表示该令牌之前已被使用过
+	MarkRefreshTokenUsed(refreshToken *model.OAuth2Token) (bool, error)
+	// 根据已使用的刷新令牌获取其所属家族
+	ReadUsedRefreshTokenFamily(tokenValue string) (string, error)
+	// 记录家族当前有效的访问令牌和刷新令牌
+	StoreTokenFamily(familyId string, accessToken *model.OAuth2Token, refreshToken *model.OAuth2Token)
+	// 获取家族当前有效的访问令牌和刷新令牌的值
+	ReadTokenFamily(familyId string) (string, string, error)
+	// 移除家族记录
+	RemoveTokenFamily(familyId string)
+	// 标记家族已泄露，之后家族中的令牌全部失效，也不能再刷新
+	MarkTokenFamilyCompromised(familyId string) error
+	IsTokenFamilyCompromised(familyId string) (bool, error)
+}
+
+// TokenDenylist 记录已吊销令牌的 jti，保留到令牌过期为止
+type TokenDenylist interface {
+	Deny(tokenId string, expiresTime *time.Time) error
+	IsDenied(tokenId string) (bool, error)
+}
+
+// UserRevocationList 记录用户的吊销时间，此前签发的令牌全部失效，
+// TokenDenylist 实现该接口时自包含的 JWT 和索引之外的令牌也能按用户吊销
+type UserRevocationList interface {
+	// clientId 为空时吊销用户在全部客户端的令牌
+	RevokeUser(userId int64, clientId string, revokedAt time.Time) error
+	// 返回用户级和用户在该客户端的吊销时间中较晚的一个，未吊销时返回零值
+	RevokedAfter(userId int64, clientId string) (time.Time, error)
+}
+
+// ClientRevocationList 记录已停用的客户端，TokenDenylist 实现该接口时停用客户端后其已签发的令牌立即失效，
+// 其他实例缓存的客户端认证结果过期前签发的令牌同样失效
+type ClientRevocationList interface {
+	RevokeClient(clientId string) error
+	IsClientRevoked(clientId string) (bool, error)
+}
+
+// UserTokenIndex 按用户索引已签发的访问令牌和刷新令牌，TokenStore 实现该接口时吊销用户令牌会一并删除存储中的令牌
+type UserTokenIndex interface {
+	// 返回用户的访问令牌和刷新令牌的值，可能包含已过期的令牌
+	ReadUserTokens(userId int64) ([]string, []string, error)
+	// 从索引中移除已吊销或已过期的令牌
+	RemoveUserTokens(userId int64, accessTokens []string, refreshTokens []string) error
+}
+
 type TokenEnhancer interface {
 	// 组装 Token 信息
 	Enhance(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error)
@@ -260,76 +806,251 @@ type TokenStore interface {
 	ReadOAuth2DetailsForRefreshToken(tokenValue string) (*model.OAuth2Details, error)
 }
 
+const defaultIssuer = "System"
+
 type JwtTokenEnhancer struct {
 	secretKey []byte
+	// 非对称签名密钥，activeKid 对应的密钥用于签名，全部密钥都可用于校验
+	signingKeys map[string]*SigningKey
+	activeKid   string
+	issuer      string
+	// 是否接受旧格式（内嵌 UserDetails、ClientDetails）的令牌，旧令牌使用 legacySecretKey 做 HS256 签名
+	legacyClaims    bool
+	legacySecretKey []byte
 }
 
 func NewJwtTokenEnhancer(secretKey string) TokenEnhancer {
 	return &JwtTokenEnhancer{
 		secretKey: []byte(secretKey),
+		issuer:    defaultIssuer,
 	}
 }
 
-func (enhancer *JwtTokenEnhancer) Extract(tokenValue string) (*model.OAuth2Token, *model.OAuth2Details, error) {
-	token, err := jwt.ParseWithClaims(tokenValue, &OAuth2TokenCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+// NewJwtTokenEnhancerWithKeys 使用 RS256/ES256 密钥签名，轮换时先加入新密钥并切换 activeKid，
+// 旧密钥保留到其签发的令牌全部过期后再移除
+func NewJwtTokenEnhancerWithKeys(activeKid string, signingKeys ...*SigningKey) (TokenEnhancer, error) {
+	keys := make(map[string]*SigningKey, len(signingKeys))
+	for _, key := range signingKeys {
+		keys[key.Kid] = key
+	}
+	if activeKey, ok := keys[activeKid]; !ok || activeKey.PrivateKey == nil {
+		return nil, ErrUnknownSigningKey
+	}
+	return &JwtTokenEnhancer{
+		signingKeys: keys,
+		activeKid:   activeKid,
+		issuer:      defaultIssuer,
+	}, nil
+}
+
+// SetIssuer 设置令牌的 iss 声明
+func (enhancer *JwtTokenEnhancer) SetIssuer(issuer string) {
+	if issuer != "" {
+		enhancer.issuer = issuer
+	}
+}
+
+// AcceptLegacyClaims 迁移期间继续接受旧格式的 HS256 令牌，secretKey 为旧令牌的签名密钥，
+// 该密钥只用于校验旧格式的令牌，不能用于伪造新格式的令牌
+func (enhancer *JwtTokenEnhancer) AcceptLegacyClaims(secretKey string) {
+	enhancer.legacyClaims = true
+	enhancer.legacySecretKey = []byte(secretKey)
+}
+
+// KeySet 公开全部非对称密钥的公钥，供 /.well-known/jwks.json 使用
+func (enhancer *JwtTokenEnhancer) KeySet() *JSONWebKeySet {
+	keySet := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range enhancer.signingKeys {
+		if jwk, ok := key.jsonWebKey(); ok {
+			keySet.Keys = append(keySet.Keys, jwk)
+		}
+	}
+	return keySet
+}
+
+// verifyKey 根据令牌头部的 kid 选择公钥，且签名算法必须与密钥一致，
+// 只有使用 HS256 签名（未配置非对称密钥）时才接受没有 kid 的令牌
+func (enhancer *JwtTokenEnhancer) verifyKey(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok := enhancer.signingKeys[kid]
+		if !ok || key.Method.Alg() != token.Method.Alg() {
+			return nil, ErrUnknownSigningKey
+		}
+		return key.PublicKey, nil
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && len(enhancer.signingKeys) == 0 && len(enhancer.secretKey) > 0 {
 		return enhancer.secretKey, nil
-	})
-	if err == nil {
-		claims := token.Claims.(*OAuth2TokenCustomClaims)
-		expiresTime := time.Unix(claims.ExpiresAt, 0)
-		return &model.OAuth2Token{
-				RefreshToken: &claims.RefreshToken,
-				TokenType:    tokenValue,
-				ExpriesTime:  &expiresTime,
-			}, &model.OAuth2Details{
-				User:   &claims.UserDetails,
-				Client: &claims.ClientDetails,
-			}, nil
 	}
-	return nil, nil, err
+	return nil, ErrUnknownSigningKey
+}
+
+// verifyLegacyKey 旧格式的令牌只使用 HS256 签名且没有 kid
+func (enhancer *JwtTokenEnhancer) verifyLegacyKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Header["kid"]; ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && len(enhancer.legacySecretKey) > 0 {
+		return enhancer.legacySecretKey, nil
+	}
+	return nil, ErrUnknownSigningKey
+}
+
+func (enhancer *JwtTokenEnhancer) Extract(tokenValue string) (*model.OAuth2Token, *model.OAuth2Details, error) {
+	token, err := jwt.ParseWithClaims(tokenValue, &OAuth2TokenCustomClaims{}, enhancer.verifyKey)
+	if err != nil || token.Claims.(*OAuth2TokenCustomClaims).ClientId == "" {
+		// 迁移期间兼容旧格式的令牌
+		if enhancer.legacyClaims {
+			return enhancer.extractLegacyClaims(tokenValue)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidTokenRequest
+	}
+	claims := token.Claims.(*OAuth2TokenCustomClaims)
+	expiresTime := time.Unix(claims.ExpiresAt, 0)
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	oauth2Details := &model.OAuth2Details{
+		Client: &model.ClientDetails{
+			ClientId:                    claims.ClientId,
+			AccessTokenValiditySeconds:  claims.AccessTokenValiditySeconds,
+			RefreshTokenValiditySeconds: claims.RefreshTokenValiditySeconds,
+			AuthorizedGrantTypes:        claims.GrantTypes,
+		},
+		Scope:          strings.Fields(claims.Scope),
+		Actor:          claims.Act,
+		OriginClientId: claims.OriginClientId,
+	}
+	if claims.Audience != claims.ClientId {
+		oauth2Details.Audience = claims.Audience
+	}
+	if claims.Username != "" {
+		userId, _ := strconv.ParseInt(claims.Subject, 10, 64)
+		oauth2Details.User = &model.UserDetails{
+			UserId:      userId,
+			Username:    claims.Username,
+			Authorities: claims.Authorities,
+		}
+	}
+	return &model.OAuth2Token{
+		TokenValue:  tokenValue,
+		TokenType:   "jwt",
+		ExpriesTime: &expiresTime,
+		IssuedAt:    &issuedAt,
+		TokenId:     claims.Id,
+		Scope:       oauth2Details.Scope,
+	}, oauth2Details, nil
 }
 
 func (enhancer *JwtTokenEnhancer) Enhance(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
 	return enhancer.sign(oauth2Token, oauth2Details)
 }
 
+// OAuth2TokenCustomClaims 使用标准声明：sub 为用户 ID（客户端模式下为客户端 ID），aud 默认为客户端 ID
 type OAuth2TokenCustomClaims struct {
-	UserDetails   model.UserDetails
+	ClientId    string   `json:"client_id"`
+	Username    string   `json:"user_name,omitempty"`
+	Authorities []string `json:"authorities,omitempty"`
+	// 刷新令牌时据此还原客户端的令牌有效期和授权类型
+	AccessTokenValiditySeconds  int      `json:"access_token_validity,omitempty"`
+	RefreshTokenValiditySeconds int      `json:"refresh_token_validity,omitempty"`
+	GrantTypes                  []string `json:"grant_types,omitempty"`
+	// 以空格分隔的权限范围
+	Scope string `json:"scope,omitempty"`
+	// 令牌交换签发的令牌记录调用方和用户最初授权的客户端
+	Act            *model.Actor `json:"act,omitempty"`
+	OriginClientId string       `json:"origin_client_id,omitempty"`
+	//内嵌模式
+	jwt.StandardClaims
+}
+
+// legacyOAuth2TokenClaims 旧版本令牌直接内嵌用户和客户端信息
+type legacyOAuth2TokenClaims struct {
+	UserDetails   *model.UserDetails
 	ClientDetails model.ClientDetails
 	RefreshToken  model.OAuth2Token
-	//内嵌模式
 	jwt.StandardClaims
 }
 
+// extractLegacyClaims 只接受内嵌 ClientDetails 的旧格式声明，使用旧密钥签名的新格式令牌视为无效
+func (enhancer *JwtTokenEnhancer) extractLegacyClaims(tokenValue string) (*model.OAuth2Token, *model.OAuth2Details, error) {
+	claims := &legacyOAuth2TokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenValue, claims, enhancer.verifyLegacyKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.ClientDetails.ClientId == "" {
+		return nil, nil, ErrInvalidTokenRequest
+	}
+	expiresTime := time.Unix(claims.ExpiresAt, 0)
+	return &model.OAuth2Token{
+		RefreshToken: &claims.RefreshToken,
+		TokenValue:   token.Raw,
+		TokenType:    "jwt",
+		ExpriesTime:  &expiresTime,
+	}, &model.OAuth2Details{
+		User:   claims.UserDetails,
+		Client: &claims.ClientDetails,
+	}, nil
+}
+
 func (enhancer *JwtTokenEnhancer) sign(oauth2Token *model.OAuth2Token, oauth2Details *model.OAuth2Details) (*model.OAuth2Token, error) {
-	expireTime := oauth2Token.ExpriesTime
-	clientDetails := *oauth2Details.Client
-	userDetails := *oauth2Details.User
-	clientDetails.ClientSecret = ""
-	userDetails.Password = ""
+	now := time.Now()
+	if oauth2Token.IssuedAt != nil {
+		now = *oauth2Token.IssuedAt
+	}
+	client := oauth2Details.Client
 	claims := &OAuth2TokenCustomClaims{
-		UserDetails:   userDetails,
-		ClientDetails: clientDetails,
+		ClientId:                    client.ClientId,
+		AccessTokenValiditySeconds:  client.AccessTokenValiditySeconds,
+		RefreshTokenValiditySeconds: client.RefreshTokenValiditySeconds,
+		GrantTypes:                  client.AuthorizedGrantTypes,
+		Scope:                       strings.Join(oauth2Details.Scope, " "),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expireTime.Unix(),
-			Issuer:    "System",
+			Id:        uuid.NewV4().String(),
+			Subject:   client.ClientId,
+			Audience:  client.ClientId,
+			ExpiresAt: oauth2Token.ExpriesTime.Unix(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			Issuer:    enhancer.issuer,
 		},
 	}
-	if oauth2Token.RefreshToken != nil {
-		claims.RefreshToken = *oauth2Token.RefreshToken
+	if oauth2Details.Audience != "" {
+		claims.Audience = oauth2Details.Audience
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenValue, err := token.SignedString(enhancer.secretKey)
+	claims.Act = oauth2Details.Actor
+	claims.OriginClientId = oauth2Details.OriginClientId
+	if oauth2Details.User != nil {
+		claims.Subject = strconv.FormatInt(oauth2Details.User.UserId, 10)
+		claims.Username = oauth2Details.User.Username
+		claims.Authorities = oauth2Details.User.Authorities
+	}
+	tokenValue, err := enhancer.signClaims(claims)
 	if err == nil {
 		oauth2Token.TokenValue = tokenValue
 		oauth2Token.TokenType = "jwt"
+		oauth2Token.TokenId = claims.Id
 		return oauth2Token, nil
 
 	}
 	return nil, err
 }
 
+// signClaims 使用 activeKid 对应的密钥签名，未配置非对称密钥时使用 HS256
+func (enhancer *JwtTokenEnhancer) signClaims(claims jwt.Claims) (string, error) {
+	if activeKey, ok := enhancer.signingKeys[enhancer.activeKid]; ok {
+		token := jwt.NewWithClaims(activeKey.Method, claims)
+		token.Header["kid"] = activeKey.Kid
+		return token.SignedString(activeKey.PrivateKey)
+	}
+	if len(enhancer.secretKey) == 0 {
+		return "", ErrUnknownSigningKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(enhancer.secretKey)
+}
+
 type JwtTokenStore struct {
 	jwtTokenEnhancer *JwtTokenEnhancer
 }
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"SecondKill/oauth-service/model"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/dgrijalva/jwt-go"
+	"testing"
+	"time"
+)
+
+func TestResolveScope(t *testing.T) {
+	granted := []string{"read", "write"}
+	tests := []struct {
+		name      string
+		requested string
+		want      []string
+		wantErr   error
+	}{
+		{name: "empty request grants all", requested: "", want: granted},
+		{name: "narrowed scope", requested: "read", want: []string{"read"}},
+		{name: "scope outside granted", requested: "read admin", wantErr: ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := ResolveScope(tt.requested, granted)
+			if err != tt.wantErr {
+				t.Fatalf("ResolveScope() err = %v, want %v", err, tt.wantErr)
+			}
+			if len(scope) != len(tt.want) {
+				t.Fatalf("ResolveScope() = %v, want %v", scope, tt.want)
+			}
+			for i := range scope {
+				if scope[i] != tt.want[i] {
+					t.Fatalf("ResolveScope() = %v, want %v", scope, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshAccessToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// run 在首次签发的令牌上执行刷新序列，返回最后一次刷新的错误
+		run     func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error
+		wantErr error
+		// 首次签发的访问令牌在序列结束后是否仍然有效
+		firstAccessValid bool
+	}{
+		{
+			name: "rotation issues a new refresh token",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				rotated, err := tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "")
+				if err != nil {
+					return err
+				}
+				if rotated.RefreshToken.TokenValue == first.RefreshToken.TokenValue {
+					t.Fatal("refresh token was not rotated")
+				}
+				if rotated.RefreshToken.FamilyId != first.RefreshToken.FamilyId {
+					t.Fatal("rotated refresh token left the family")
+				}
+				_, err = tokenService.GetOAuth2DetailsByAccessToken(rotated.TokenValue)
+				return err
+			},
+		},
+		{
+			name: "narrowed scope on refresh",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				rotated, err := tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "read")
+				if err != nil {
+					return err
+				}
+				if len(rotated.Scope) != 1 || rotated.Scope[0] != "read" {
+					t.Fatalf("access token scope = %v, want [read]", rotated.Scope)
+				}
+				// 新的刷新令牌保留原授权范围
+				_, err = tokenService.RefreshAccessToken(rotated.RefreshToken.TokenValue, "read write")
+				return err
+			},
+		},
+		{
+			name: "scope beyond the grant",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				_, err := tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "admin")
+				return err
+			},
+			wantErr:          ErrInvalidScope,
+			firstAccessValid: true,
+		},
+		{
+			name: "reusing a rotated refresh token revokes the family",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				rotated, err := tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "")
+				if err != nil {
+					return err
+				}
+				if _, err = tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, ""); err != ErrRefreshTokenReused {
+					t.Fatalf("reuse err = %v, want %v", err, ErrRefreshTokenReused)
+				}
+				if _, err = tokenService.GetOAuth2DetailsByAccessToken(rotated.TokenValue); err == nil {
+					t.Fatal("access token of the reused family is still valid")
+				}
+				// 家族中尚未使用的刷新令牌也已被删除
+				_, err = tokenService.RefreshAccessToken(rotated.RefreshToken.TokenValue, "")
+				return err
+			},
+			wantErr: ErrInvalidTokenRequest,
+		},
+		{
+			name: "rotation finishing after a concurrent reuse is discarded",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				// 模拟复用请求在本次轮换记录家族之前完成了吊销
+				tokenStore.MarkTokenFamilyCompromised(first.RefreshToken.FamilyId)
+				_, err := tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "")
+				return err
+			},
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "a second login gets its own refresh token family",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				second, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+					Client: newTestClient("app", false, "read", "write"),
+					User:   newTestUser(1, "alice"),
+					Scope:  []string{"read", "write"},
+				})
+				if err != nil {
+					return err
+				}
+				if second.RefreshToken.TokenValue == first.RefreshToken.TokenValue || second.RefreshToken.FamilyId == first.RefreshToken.FamilyId {
+					t.Fatal("second login shares the first login's refresh token")
+				}
+				if _, err = tokenService.RefreshAccessToken(second.RefreshToken.TokenValue, ""); err != nil {
+					return err
+				}
+				// 另一次登录的轮换不会被当作复用
+				_, err = tokenService.RefreshAccessToken(first.RefreshToken.TokenValue, "")
+				return err
+			},
+		},
+		{
+			name: "unknown refresh token",
+			run: func(t *testing.T, tokenService *DefaultTokenService, tokenStore *memoryTokenStore, first *model.OAuth2Token) error {
+				_, err := tokenService.RefreshAccessToken("unknown", "")
+				return err
+			},
+			wantErr:          ErrInvalidTokenRequest,
+			firstAccessValid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, tokenStore, _ := newTestTokenService()
+			first, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+				Client: newTestClient("app", false, "read", "write"),
+				User:   newTestUser(1, "alice"),
+				Scope:  []string{"read", "write"},
+			})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			if err = tt.run(t, tokenService, tokenStore, first); err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if _, err = tokenService.GetOAuth2DetailsByAccessToken(first.TokenValue); (err == nil) != tt.firstAccessValid {
+				t.Fatalf("first access token valid = %v, want %v", err == nil, tt.firstAccessValid)
+			}
+		})
+	}
+}
+
+func TestTokenExpiresTime(t *testing.T) {
+	issuedAt := time.Now()
+	tests := []struct {
+		name            string
+		validitySeconds int
+		want            time.Duration
+	}{
+		{name: "configured validity", validitySeconds: 3600, want: time.Hour},
+		{name: "validity beyond the limit", validitySeconds: int(maxTokenValidity/time.Second) + 1, want: maxTokenValidity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expiresTime := tokenExpiresTime(issuedAt, tt.validitySeconds); expiresTime.Sub(issuedAt) != tt.want {
+				t.Fatalf("tokenExpiresTime() validity = %v, want %v", expiresTime.Sub(issuedAt), tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	owner := newTestClient("app", false, "read")
+	tests := []struct {
+		name          string
+		tokenTypeHint string
+		// 吊销访问令牌还是刷新令牌
+		revokeRefresh bool
+		client        *model.ClientDetails
+		wantErr       error
+		wantRevoked   bool
+	}{
+		{name: "owner revokes access token", tokenTypeHint: "access_token", client: owner, wantRevoked: true},
+		{name: "owner revokes refresh token", tokenTypeHint: "refresh_token", revokeRefresh: true, client: owner, wantRevoked: true},
+		{name: "wrong hint still revokes", tokenTypeHint: "refresh_token", client: owner, wantRevoked: true},
+		{name: "other client cannot revoke", tokenTypeHint: "access_token", client: newTestClient("other", false, "read"), wantErr: ErrTokenNotIssuedToClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, _ := newTestTokenService()
+			accessToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+				Client: owner,
+				User:   newTestUser(1, "alice"),
+				Scope:  []string{"read"},
+			})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			tokenValue := accessToken.TokenValue
+			if tt.revokeRefresh {
+				tokenValue = accessToken.RefreshToken.TokenValue
+			}
+			if err = tokenService.RevokeToken(tokenValue, tt.tokenTypeHint, tt.client); err != tt.wantErr {
+				t.Fatalf("RevokeToken() err = %v, want %v", err, tt.wantErr)
+			}
+			_, err = tokenService.GetOAuth2DetailsByAccessToken(accessToken.TokenValue)
+			if (err != nil) != tt.wantRevoked {
+				t.Fatalf("access token revoked = %v, want %v", err != nil, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientId string
+		// 吊销后 app 和 other 两个客户端的令牌是否仍然有效
+		wantAppValid   bool
+		wantOtherValid bool
+	}{
+		{name: "revoke one client", clientId: "app", wantAppValid: false, wantOtherValid: true},
+		{name: "revoke all clients", clientId: "", wantAppValid: false, wantOtherValid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, _ := newTestTokenService()
+			user := newTestUser(1, "alice")
+			issued := make(map[string]*model.OAuth2Token)
+			for _, clientId := range []string{"app", "other"} {
+				accessToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+					Client: newTestClient(clientId, false, "read"),
+					User:   user,
+					Scope:  []string{"read"},
+				})
+				if err != nil {
+					t.Fatalf("CreateAccessToken() err = %v", err)
+				}
+				issued[clientId] = accessToken
+			}
+			if _, err := tokenService.RevokeUserTokens(user.UserId, tt.clientId); err != nil {
+				t.Fatalf("RevokeUserTokens() err = %v", err)
+			}
+			for clientId, want := range map[string]bool{"app": tt.wantAppValid, "other": tt.wantOtherValid} {
+				if _, err := tokenService.GetOAuth2DetailsByAccessToken(issued[clientId].TokenValue); (err == nil) != want {
+					t.Fatalf("%s access token valid = %v, want %v", clientId, err == nil, want)
+				}
+				if _, err := tokenService.RefreshAccessToken(issued[clientId].RefreshToken.TokenValue, ""); (err == nil) != want {
+					t.Fatalf("%s refresh token valid = %v, want %v", clientId, err == nil, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeClientTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		details *model.OAuth2Details
+	}{
+		{name: "user token", details: &model.OAuth2Details{User: newTestUser(1, "alice"), Scope: []string{"read"}}},
+		{name: "client credentials token", details: &model.OAuth2Details{Scope: []string{"read"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenService, _, _ := newTestTokenService()
+			tt.details.Client = newTestClient("app", false, "read")
+			accessToken, err := tokenService.CreateAccessToken(tt.details)
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			otherToken, err := tokenService.CreateAccessToken(&model.OAuth2Details{
+				Client: newTestClient("other", false, "read"),
+				Scope:  []string{"read"},
+			})
+			if err != nil {
+				t.Fatalf("CreateAccessToken() err = %v", err)
+			}
+			if err = tokenService.RevokeClientTokens("app"); err != nil {
+				t.Fatalf("RevokeClientTokens() err = %v", err)
+			}
+			if _, err = tokenService.GetOAuth2DetailsByAccessToken(accessToken.TokenValue); err != ErrRevokedToken {
+				t.Fatalf("access token err = %v, want %v", err, ErrRevokedToken)
+			}
+			if accessToken.RefreshToken != nil {
+				if _, err = tokenService.RefreshAccessToken(accessToken.RefreshToken.TokenValue, ""); err != ErrRevokedToken {
+					t.Fatalf("refresh err = %v, want %v", err, ErrRevokedToken)
+				}
+			}
+			if _, err = tokenService.GetOAuth2DetailsByAccessToken(otherToken.TokenValue); err != nil {
+				t.Fatalf("other client's token err = %v", err)
+			}
+		})
+	}
+}
+
+func TestJwtTokenEnhancerExtract(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() err = %v", err)
+	}
+	newRsaEnhancer := func(t *testing.T) *JwtTokenEnhancer {
+		tokenEnhancer, err := NewJwtTokenEnhancerWithKeys("k1", &SigningKey{Kid: "k1", Method: jwt.SigningMethodRS256, PrivateKey: privateKey, PublicKey: &privateKey.PublicKey})
+		if err != nil {
+			t.Fatalf("NewJwtTokenEnhancerWithKeys() err = %v", err)
+		}
+		return tokenEnhancer.(*JwtTokenEnhancer)
+	}
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	legacyToken := func(t *testing.T) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, legacyOAuth2TokenClaims{
+			UserDetails:    newTestUser(1, "alice"),
+			ClientDetails:  *newTestClient("app", false, "read"),
+			StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt},
+		}).SignedString([]byte("legacy-secret"))
+		if err != nil {
+			t.Fatalf("SignedString() err = %v", err)
+		}
+		return token
+	}
+	// newShapeToken 使用旧密钥签名新格式的声明，模拟持有旧密钥的伪造者
+	newShapeToken := func(t *testing.T) string {
+		expiresTime := time.Now().Add(time.Hour)
+		token, err := NewJwtTokenEnhancer("legacy-secret").Enhance(&model.OAuth2Token{ExpriesTime: &expiresTime}, &model.OAuth2Details{
+			Client: newTestClient("app", false, "read"),
+			User:   newTestUser(1, "alice"),
+			Scope:  []string{"read"},
+		})
+		if err != nil {
+			t.Fatalf("Enhance() err = %v", err)
+		}
+		return token.TokenValue
+	}
+	tests := []struct {
+		name      string
+		enhancer  func(t *testing.T) *JwtTokenEnhancer
+		token     func(t *testing.T) string
+		wantValid bool
+	}{
+		{
+			name:      "HS256 token with the configured secret",
+			enhancer:  func(t *testing.T) *JwtTokenEnhancer { return NewJwtTokenEnhancer("legacy-secret").(*JwtTokenEnhancer) },
+			token:     newShapeToken,
+			wantValid: true,
+		},
+		{
+			name: "legacy token in legacy mode",
+			enhancer: func(t *testing.T) *JwtTokenEnhancer {
+				tokenEnhancer := newRsaEnhancer(t)
+				tokenEnhancer.AcceptLegacyClaims("legacy-secret")
+				return tokenEnhancer
+			},
+			token:     legacyToken,
+			wantValid: true,
+		},
+		{name: "legacy token without legacy mode", enhancer: newRsaEnhancer, token: legacyToken},
+		{
+			name: "new shape signed with the legacy secret",
+			enhancer: func(t *testing.T) *JwtTokenEnhancer {
+				tokenEnhancer := newRsaEnhancer(t)
+				tokenEnhancer.AcceptLegacyClaims("legacy-secret")
+				return tokenEnhancer
+			},
+			token: newShapeToken,
+		},
+		{name: "HS256 token when signing with RSA", enhancer: newRsaEnhancer, token: newShapeToken},
+		{name: "empty secret signs nothing", enhancer: func(t *testing.T) *JwtTokenEnhancer { return NewJwtTokenEnhancer("").(*JwtTokenEnhancer) }, token: legacyToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, details, err := tt.enhancer(t).Extract(tt.token(t))
+			if valid := err == nil; valid != tt.wantValid {
+				t.Fatalf("Extract() err = %v, wantValid %v", err, tt.wantValid)
+			}
+			if tt.wantValid && (details.Client == nil || details.Client.ClientId != "app" || details.User == nil || details.User.UserId != 1) {
+				t.Fatalf("Extract() details = %+v, want client app and user 1", details)
+			}
+		})
+	}
+}
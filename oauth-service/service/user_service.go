@@ -56,11 +56,12 @@ func (service *RemoteUserService) GetUserDetailByUserName(ctx context.Context, u
 		Password: password,
 	})
 	if err == nil {
-		if response.UserId != 0 {
+		if response.Result && response.UserId != 0 {
+			// 不保留明文密码
 			return &model.UserDetails{
-				UserId:   response.UserId,
-				Username: username,
-				Password: password,
+				UserId:      response.UserId,
+				Username:    username,
+				Authorities: response.Authorities,
 			}, nil
 		} else {
 			return nil, InvalidUserInfo
@@ -76,3 +77,8 @@ func NewRemoteUserDetailService() *RemoteUserService {
 		userClient: userClient,
 	}
 }
+
+// isCredentialError 判断是否为用户名或密码错误
+func isCredentialError(err error) bool {
+	return err == ErrUserNotExit || err == ErrPassword || err == InvalidUserInfo
+}
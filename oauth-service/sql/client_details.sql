@@ -0,0 +1,18 @@
+-- 客户端信息表，新部署直接执行本文件
+CREATE TABLE IF NOT EXISTS `client_details` (
+  `client_id` varchar(255) NOT NULL,
+  -- bcrypt 哈希，历史数据可能仍是明文，公开客户端为空
+  `client_secret` varchar(255) NOT NULL DEFAULT '',
+  -- 轮换密钥后旧密钥的哈希，在 previous_secret_expires_at（unix 秒）之前仍然可用
+  `previous_client_secret` varchar(255) NOT NULL DEFAULT '',
+  `previous_secret_expires_at` bigint(20) NOT NULL DEFAULT 0,
+  `access_token_validity_seconds` int(10) NOT NULL,
+  `refresh_token_validity_seconds` int(10) NOT NULL,
+  `registered_redirect_uri` varchar(255) NOT NULL DEFAULT '',
+  -- JSON 数组
+  `authorized_grant_types` varchar(255) NOT NULL,
+  `scope` varchar(1024) NOT NULL DEFAULT '[]',
+  `public` tinyint(1) NOT NULL DEFAULT 0,
+  `disabled` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`client_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,11 @@
+-- 已有的 client_details 表升级到支持密钥轮换、权限范围、公开客户端和停用客户端的版本，只需执行一次
+ALTER TABLE `client_details`
+  MODIFY COLUMN `client_secret` varchar(255) NOT NULL DEFAULT '',
+  MODIFY COLUMN `registered_redirect_uri` varchar(255) NOT NULL DEFAULT '',
+  ADD COLUMN `previous_client_secret` varchar(255) NOT NULL DEFAULT '' AFTER `client_secret`,
+  ADD COLUMN `previous_secret_expires_at` bigint(20) NOT NULL DEFAULT 0 AFTER `previous_client_secret`,
+  ADD COLUMN `scope` varchar(1024) NOT NULL DEFAULT '[]',
+  ADD COLUMN `public` tinyint(1) NOT NULL DEFAULT 0,
+  ADD COLUMN `disabled` tinyint(1) NOT NULL DEFAULT 0;
+
+-- 明文密钥在客户端下次认证成功时自动转换为 bcrypt 哈希
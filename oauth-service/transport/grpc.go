@@ -2,39 +2,271 @@ package transport
 
 import (
 	"SecondKill/oauth-service/endpoint"
+	"SecondKill/oauth-service/model"
+	"SecondKill/oauth-service/plugins"
+	"SecondKill/oauth-service/service"
 	"SecondKill/pb"
+	"SecondKill/pkg/common"
 	"context"
+	"encoding/base64"
 	"github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type grpcServer struct{
-	checkTokenServer grpc.Handler
+	checkTokenServer    grpc.Handler
+	createClientServer  grpc.Handler
+	updateClientServer  grpc.Handler
+	disableClientServer grpc.Handler
+	listClientsServer   grpc.Handler
+	tokenServer         grpc.Handler
+	refreshTokenServer  grpc.Handler
+	revokeServer        grpc.Handler
 }
 
 func (s *grpcServer) CheckToken(ctx context.Context, request *pb.CheckTokenRequest) (*pb.CheckTokenResponse, error) {
 	_, resp, err := s.checkTokenServer.ServeGRPC(ctx, request)
 	if err != nil {
-		return nil, err
+		return nil, encodeGRPCError(err)
 	}
 	return resp.(*pb.CheckTokenResponse), nil
 }
 
-func NewGRPCServer(ctx context.Context, endpoints endpoint.OAuth2Endpoints, serverTracer grpc.ServerOption) pb.OAuthServiceServer {
+func (s *grpcServer) CreateClient(ctx context.Context, request *pb.ClientRequest) (*pb.ClientResponse, error) {
+	_, resp, err := s.createClientServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.ClientResponse), nil
+}
+
+func (s *grpcServer) UpdateClient(ctx context.Context, request *pb.ClientRequest) (*pb.ClientResponse, error) {
+	_, resp, err := s.updateClientServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.ClientResponse), nil
+}
+
+func (s *grpcServer) DisableClient(ctx context.Context, request *pb.DisableClientRequest) (*pb.DisableClientResponse, error) {
+	_, resp, err := s.disableClientServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.DisableClientResponse), nil
+}
+
+func (s *grpcServer) ListClients(ctx context.Context, request *pb.ListClientsRequest) (*pb.ListClientsResponse, error) {
+	_, resp, err := s.listClientsServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.ListClientsResponse), nil
+}
+
+func (s *grpcServer) Token(ctx context.Context, request *pb.TokenRequest) (*pb.TokenResponse, error) {
+	_, resp, err := s.tokenServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.TokenResponse), nil
+}
+
+func (s *grpcServer) RefreshToken(ctx context.Context, request *pb.RefreshTokenRequest) (*pb.TokenResponse, error) {
+	_, resp, err := s.refreshTokenServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.TokenResponse), nil
+}
+
+func (s *grpcServer) Revoke(ctx context.Context, request *pb.RevokeRequest) (*pb.RevokeResponse, error) {
+	_, resp, err := s.revokeServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, encodeGRPCError(err)
+	}
+	return resp.(*pb.RevokeResponse), nil
+}
+
+// encodeGRPCError 将 OAuth2 错误转换为对应的 gRPC 状态码，消息为 "错误码: 描述"
+func encodeGRPCError(err error) error {
+	if oauth2Err, ok := err.(*service.OAuth2Error); ok {
+		return status.Error(oauth2GRPCCode(oauth2Err.ErrorCode), oauth2Err.ErrorCode+": "+oauth2Err.Description)
+	}
+	switch err {
+	case plugins.ErrLimitExceed:
+		// 客户端可据此退避重试
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case model.ErrClientNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+func oauth2GRPCCode(errorCode string) codes.Code {
+	switch errorCode {
+	case service.ErrorCodeInvalidClient, service.ErrorCodeInvalidToken:
+		return codes.Unauthenticated
+	case service.ErrorCodeInsufficientScope, service.ErrorCodeAccessDenied, service.ErrorCodeUnauthorizedClient:
+		return codes.PermissionDenied
+	case service.ErrorCodeInvalidGrant:
+		return codes.FailedPrecondition
+	case service.ErrorCodeUnsupportedGrantType, service.ErrorCodeUnsupportedResponseType:
+		return codes.Unimplemented
+	case service.ErrorCodeServerError:
+		return codes.Internal
+	case service.ErrorCodeTemporarilyUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+func NewGRPCServer(ctx context.Context, endpoints endpoint.OAuth2Endpoints, clientIpResolver *common.ClientIpResolver, serverTracer grpc.ServerOption) pb.OAuthServiceServer {
+	clientIpContext := grpc.ServerBefore(makeGRPCClientIpContext(clientIpResolver))
+	adminOptions := []grpc.ServerOption{
+		clientIpContext,
+		grpc.ServerBefore(makeGRPCAccessTokenContext),
+		serverTracer,
+	}
+	// 与 http 的令牌和吊销端点一致，允许公开客户端只携带 client_id
+	clientAuthorizationOptions := []grpc.ServerOption{
+		clientIpContext,
+		grpc.ServerBefore(makeGRPCClientCredentialsContext),
+		serverTracer,
+	}
 	return &grpcServer{
 		checkTokenServer : grpc.NewServer(
 			endpoints.GRPCCheckTokenEndpoint,
 			DecodeGRPCCheckTokenRequest,
 			EncodeGRPCCheckTokenResponse,
+			clientIpContext,
 			serverTracer,
 			),
+		createClientServer: grpc.NewServer(
+			endpoints.CreateClientEndpoint,
+			DecodeGRPCClientRequest,
+			EncodeGRPCClientResponse,
+			adminOptions...,
+		),
+		updateClientServer: grpc.NewServer(
+			endpoints.UpdateClientEndpoint,
+			DecodeGRPCClientRequest,
+			EncodeGRPCClientResponse,
+			adminOptions...,
+		),
+		disableClientServer: grpc.NewServer(
+			endpoints.DisableClientEndpoint,
+			DecodeGRPCDisableClientRequest,
+			EncodeGRPCDisableClientResponse,
+			adminOptions...,
+		),
+		listClientsServer: grpc.NewServer(
+			endpoints.ListClientsEndpoint,
+			DecodeGRPCListClientsRequest,
+			EncodeGRPCListClientsResponse,
+			adminOptions...,
+		),
+		tokenServer: grpc.NewServer(
+			endpoints.TokenEndpoint,
+			DecodeGRPCTokenRequest,
+			EncodeGRPCTokenResponse,
+			clientAuthorizationOptions...,
+		),
+		refreshTokenServer: grpc.NewServer(
+			endpoints.TokenEndpoint,
+			DecodeGRPCRefreshTokenRequest,
+			EncodeGRPCTokenResponse,
+			clientAuthorizationOptions...,
+		),
+		revokeServer: grpc.NewServer(
+			endpoints.RevokeTokenEndpoint,
+			DecodeGRPCRevokeRequest,
+			EncodeGRPCRevokeResponse,
+			clientAuthorizationOptions...,
+		),
 	}
 }
 
+// makeGRPCClientCredentialsContext 从 metadata 的 authorization 中读取客户端的 Basic 认证信息，
+// 没有时按公开客户端读取 client_id，凭证由 endpoint 层在限流之后校验
+func makeGRPCClientCredentialsContext(ctx context.Context, md metadata.MD) context.Context {
+	if values := md.Get("authorization"); len(values) > 0 {
+		if clientId, clientSecret, ok := parseBasicAuth(values[0]); ok {
+			return context.WithValue(ctx, endpoint.OAuth2ClientCredentialsKey, &endpoint.ClientCredentials{
+				ClientId:     clientId,
+				ClientSecret: clientSecret,
+			})
+		}
+	} else if values := md.Get("client_id"); len(values) > 0 && values[0] != "" {
+		return context.WithValue(ctx, endpoint.OAuth2ClientCredentialsKey, &endpoint.ClientCredentials{
+			ClientId: values[0],
+			Public:   true,
+		})
+	}
+	return ctx
+}
+
+// parseBasicAuth 与 http.Request.BasicAuth 的解析方式一致
+func parseBasicAuth(authorization string) (username string, password string, ok bool) {
+	const prefix = "Basic "
+	if !strings.HasPrefix(authorization, prefix) {
+		return
+	}
+	decoded, err := base64.StdEncoding.DecodeString(authorization[len(prefix):])
+	if err != nil {
+		return
+	}
+	credentials := string(decoded)
+	index := strings.IndexByte(credentials, ':')
+	if index < 0 {
+		return
+	}
+	return credentials[:index], credentials[index+1:], true
+}
+
+// makeGRPCClientIpContext gRPC 的调用方通常是代用户登录的内部服务，连接地址不是终端用户 IP。
+// 受信的内部服务通过 metadata 的 x-forwarded-for 传递终端用户 IP，未传递时不按 IP 限制，
+// 避免少数用户输错密码就锁定经由该服务的全部登录；不受信的调用方按连接地址统计
+func makeGRPCClientIpContext(clientIpResolver *common.ClientIpResolver) grpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return ctx
+		}
+		peerAddr := p.Addr.String()
+		if clientIpResolver.IsTrusted(peerAddr) {
+			if forwardedFor := md.Get("x-forwarded-for"); len(forwardedFor) > 0 {
+				return service.WithClientIp(ctx, clientIpResolver.ClientIp(peerAddr, forwardedFor))
+			}
+			return ctx
+		}
+		return service.WithClientIp(ctx, clientIpResolver.ClientIp(peerAddr, nil))
+	}
+}
+
+// makeGRPCAccessTokenContext 从 metadata 的 authorization 中读取访问令牌
+func makeGRPCAccessTokenContext(ctx context.Context, md metadata.MD) context.Context {
+	if values := md.Get("authorization"); len(values) > 0 {
+		return context.WithValue(ctx, endpoint.OAuth2AccessTokenKey, strings.TrimPrefix(values[0], "Bearer "))
+	}
+	return ctx
+}
+
 
 func DecodeGRPCCheckTokenRequest(ctx context.Context, r interface{}) (interface{}, error) {
 	req := r.(*pb.CheckTokenRequest)
 	return &endpoint.CheckTokenRequest{
-		Token: req.Token,
+		Token:    req.Token,
+		Audience: req.Audience,
 	}, nil
 }
 
@@ -48,12 +280,7 @@ func EncodeGRPCCheckTokenResponse(_ context.Context, r interface{}) (interface{}
 			Err:          resp.Error,
 		}, nil
 	} else {
-		return &pb.CheckTokenResponse{
-			UserDetails: &pb.UserDetails{
-				UserId:      resp.OAuthDetails.User.UserId,
-				Username:    resp.OAuthDetails.User.Username,
-				Authorities: resp.OAuthDetails.User.Authorities,
-			},
+		response := &pb.CheckTokenResponse{
 			ClientDetails: &pb.ClientDetails{
 				ClientId:                    resp.OAuthDetails.Client.ClientId,
 				AccessTokenValiditySeconds:  int32(resp.OAuthDetails.Client.AccessTokenValiditySeconds),
@@ -62,6 +289,184 @@ func EncodeGRPCCheckTokenResponse(_ context.Context, r interface{}) (interface{}
 			},
 			IsValidToken: true,
 			Err:          "",
-		}, nil
+			Scope:        resp.OAuthDetails.Scope,
+			Audience:     resp.OAuthDetails.Audience,
+		}
+		// 客户端模式签发的令牌没有用户信息
+		if resp.OAuthDetails.User != nil {
+			response.UserDetails = &pb.UserDetails{
+				UserId:      resp.OAuthDetails.User.UserId,
+				Username:    resp.OAuthDetails.User.Username,
+				Authorities: resp.OAuthDetails.User.Authorities,
+			}
+		}
+		return response, nil
+	}
+}
+
+func DecodeGRPCClientRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.ClientRequest)
+	clientDetails := req.ClientDetails
+	if clientDetails == nil {
+		clientDetails = &pb.ClientDetails{}
+	}
+	return &endpoint.ClientRequest{
+		ClientId:                    clientDetails.ClientId,
+		ClientSecret:                req.ClientSecret,
+		AccessTokenValiditySeconds:  int(clientDetails.AccessTokenValiditySeconds),
+		RefreshTokenValiditySeconds: int(clientDetails.RefreshTokenValiditySeconds),
+		RegisteredRedirectUri:       clientDetails.RegisteredRedirectUri,
+		AuthorizedGrantTypes:        clientDetails.AuthorizedGrantTypes,
+		Scope:                       clientDetails.Scope,
+		Public:                      clientDetails.Public,
+	}, nil
+}
+
+func EncodeGRPCClientResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(endpoint.ClientResponse)
+	return &pb.ClientResponse{
+		ClientDetails: encodeGRPCClientDetails(resp),
+		ClientSecret:  resp.ClientSecret,
+	}, nil
+}
+
+func DecodeGRPCDisableClientRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.DisableClientRequest)
+	return &endpoint.DisableClientRequest{
+		ClientId: req.ClientId,
+	}, nil
+}
+
+func EncodeGRPCDisableClientResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(endpoint.DisableClientResponse)
+	return &pb.DisableClientResponse{
+		Success: resp.Success,
+	}, nil
+}
+
+func DecodeGRPCListClientsRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.ListClientsRequest)
+	limit := int(req.Limit)
+	if limit <= 0 || limit > maxClientPageSize {
+		limit = maxClientPageSize
+	}
+	offset := int(req.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+	return &endpoint.ListClientsRequest{
+		Offset: offset,
+		Limit:  limit,
+	}, nil
+}
+
+func EncodeGRPCListClientsResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(endpoint.ListClientsResponse)
+	clients := make([]*pb.ClientDetails, 0, len(resp.Clients))
+	for _, client := range resp.Clients {
+		clients = append(clients, encodeGRPCClientDetails(client))
+	}
+	return &pb.ListClientsResponse{
+		Clients: clients,
+	}, nil
+}
+
+func encodeGRPCClientDetails(client endpoint.ClientResponse) *pb.ClientDetails {
+	return &pb.ClientDetails{
+		ClientId:                    client.ClientId,
+		AccessTokenValiditySeconds:  int32(client.AccessTokenValiditySeconds),
+		RefreshTokenValiditySeconds: int32(client.RefreshTokenValiditySeconds),
+		AuthorizedGrantTypes:        client.AuthorizedGrantTypes,
+		RegisteredRedirectUri:       client.RegisteredRedirectUri,
+		Scope:                       client.Scope,
+		Public:                      client.Public,
+		Disabled:                    client.Disabled,
+	}
+}
+
+// DecodeGRPCTokenRequest 令牌授予者从表单读取参数，这里将 gRPC 请求转换为等价的表单请求
+func DecodeGRPCTokenRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.TokenRequest)
+	if req.GrantType == "" {
+		return nil, ErrorGrantTypeRequest
 	}
+	return &endpoint.TokenRequest{
+		GrantType: req.GrantType,
+		Reader: newGRPCFormRequest(ctx, map[string]string{
+			"grant_type":         req.GrantType,
+			"username":           req.Username,
+			"password":           req.Password,
+			"scope":              req.Scope,
+			"code":               req.Code,
+			"redirect_uri":       req.RedirectUri,
+			"code_verifier":      req.CodeVerifier,
+			"subject_token":      req.SubjectToken,
+			"subject_token_type": req.SubjectTokenType,
+			"audience":           req.Audience,
+		}),
+	}, nil
+}
+
+func DecodeGRPCRefreshTokenRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.RefreshTokenRequest)
+	return &endpoint.TokenRequest{
+		GrantType: "refresh_token",
+		Reader: newGRPCFormRequest(ctx, map[string]string{
+			"grant_type":    "refresh_token",
+			"refresh_token": req.RefreshToken,
+			"scope":         req.Scope,
+		}),
+	}, nil
+}
+
+// newGRPCFormRequest 令牌授予者需要的来源 IP 已由 makeGRPCClientIpContext 放入上下文
+func newGRPCFormRequest(ctx context.Context, values map[string]string) *http.Request {
+	form := url.Values{}
+	for key, value := range values {
+		if value != "" {
+			form.Set(key, value)
+		}
+	}
+	request := &http.Request{
+		Method:   http.MethodPost,
+		URL:      &url.URL{},
+		Header:   http.Header{},
+		Form:     form,
+		PostForm: form,
+	}
+	return request.WithContext(ctx)
+}
+
+func EncodeGRPCTokenResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(endpoint.TokenResponse)
+	token := resp.AccessToken
+	response := &pb.TokenResponse{
+		AccessToken:     token.TokenValue,
+		TokenType:       token.TokenType,
+		Scope:           token.Scope,
+		IdToken:         token.IdToken,
+		IssuedTokenType: resp.IssuedTokenType,
+	}
+	if token.ExpriesTime != nil {
+		response.ExpiresIn = int64(time.Until(*token.ExpriesTime).Seconds())
+	}
+	if token.RefreshToken != nil {
+		response.RefreshToken = token.RefreshToken.TokenValue
+	}
+	return response, nil
+}
+
+func DecodeGRPCRevokeRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.RevokeRequest)
+	if req.Token == "" {
+		return nil, ErrInvalidRevokeRequest
+	}
+	return &endpoint.RevokeTokenRequest{
+		Token:         req.Token,
+		TokenTypeHint: req.TokenTypeHint,
+	}, nil
+}
+
+func EncodeGRPCRevokeResponse(_ context.Context, r interface{}) (interface{}, error) {
+	return &pb.RevokeResponse{}, nil
 }
@@ -2,10 +2,15 @@ package transport
 
 import (
 	"SecondKill/oauth-service/endpoint"
+	"SecondKill/oauth-service/model"
+	"SecondKill/oauth-service/plugins"
 	"SecondKill/oauth-service/service"
+	"SecondKill/pkg/common"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/tracing/zipkin"
 	"github.com/go-kit/kit/transport"
@@ -13,47 +18,189 @@ import (
 	"github.com/gorilla/mux"
 	gozipkin "github.com/openzipkin/zipkin-go"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
-	ErrorBadRequest         = errors.New("invalid request parameter")
-	ErrorGrantTypeRequest   = errors.New("invalid request grant type")
-	ErrorTokenRequest       = errors.New("invalid request token")
-	ErrInvalidClientRequest = errors.New("invalid client message")
+	ErrorBadRequest         = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "invalid request parameter")
+	ErrorGrantTypeRequest   = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "grant_type is required")
+	ErrInvalidClientRequest = endpoint.ErrInvalidClientRequest
+	ErrInvalidRevokeRequest = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "token is required")
+	// 与吊销端点一致，缺少 token 时返回 invalid_request
+	ErrInvalidIntrospectRequest = ErrInvalidRevokeRequest
+	ErrInvalidGracePeriod       = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "grace_period must be a non-negative number of seconds")
+	ErrInvalidClientBody        = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "request body must be a JSON client object")
+	ErrInvalidPagination        = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "offset and limit must be non-negative numbers")
+	ErrInvalidUnlockRequest     = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "username or ip is required")
+	ErrInvalidUserId            = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, "user_id must be a positive number")
+)
+
+const (
+	// 轮换客户端密钥时旧密钥默认的宽限期
+	defaultSecretGracePeriod = 24 * time.Hour
+	// 客户端列表的默认和最大分页大小
+	defaultClientPageSize = 20
+	maxClientPageSize     = 100
+	// 设备验证页和授权登录页使用双重提交 cookie 防止跨站提交表单
+	deviceCsrfCookie    = "oauth_device_csrf"
+	authorizeCsrfCookie = "oauth_authorize_csrf"
 )
 
 func MakeHttpHandler(
 	ctx context.Context,
 	endpoints endpoint.OAuth2Endpoints,
 	tokenService service.TokenService,
-	clientService service.ClientDetailsService,
+	clientIpResolver *common.ClientIpResolver,
 	zipkinTracer *gozipkin.Tracer, logger log.Logger) http.Handler {
 	r := mux.NewRouter()
 	zipkinServer := zipkin.HTTPServerTrace(zipkinTracer, zipkin.Name("http-transport"))
+	clientIpContext := kithttp.ServerBefore(makeClientIpContext(clientIpResolver))
 	options := []kithttp.ServerOption{
+		clientIpContext,
 		kithttp.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		kithttp.ServerErrorEncoder(encodeError),
 		zipkinServer,
 	}
 	r.Path("/metrics").Handler(promhttp.Handler())
 	clientAuthorizationOptions := []kithttp.ServerOption{
-		kithttp.ServerBefore(makeClientAuthorizationContext(clientService, logger)),
+		clientIpContext,
+		kithttp.ServerBefore(makeClientCredentialsContext(false)),
+		kithttp.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		kithttp.ServerErrorEncoder(encodeError),
+		zipkinServer,
+	}
+	adminOptions := []kithttp.ServerOption{
+		clientIpContext,
+		kithttp.ServerBefore(makeAccessTokenContext()),
+		kithttp.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		kithttp.ServerErrorEncoder(encodeError),
+		zipkinServer,
+	}
+	// 令牌和吊销端点允许公开客户端只携带 client_id
+	publicClientAuthorizationOptions := []kithttp.ServerOption{
+		clientIpContext,
+		kithttp.ServerBefore(makeClientCredentialsContext(true)),
 		kithttp.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		kithttp.ServerErrorEncoder(encodeError),
 		zipkinServer,
 	}
-	r.Methods("POST").Path("/oath/token").Handler(kithttp.NewServer(
+	tokenHandler := kithttp.NewServer(
 		endpoints.TokenEndpoint,
 		decodeOathRequest,
 		encodeJsonResponse,
+		publicClientAuthorizationOptions...,
+	)
+	r.Methods("POST").Path("/oauth/token").Name(endpoint.TokenRoute).Handler(tokenHandler)
+	// 早期版本的路径拼写为 /oath，保留为别名兼容已有的调用方，新的调用方应通过元数据发现端点
+	r.Methods("POST").Path("/oath/token").Handler(tokenHandler)
+	r.Methods("GET", "POST").Path("/oauth/authorize").Name(endpoint.AuthorizationRoute).Handler(kithttp.NewServer(
+		endpoints.AuthorizeEndpoint,
+		decodeAuthorizeRequest,
+		encodeAuthorizeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/oauth/device_authorization").Name(endpoint.DeviceAuthorizationRoute).Handler(kithttp.NewServer(
+		endpoints.DeviceAuthorizationEndpoint,
+		makeDecodeDeviceAuthorizationRequest(r),
+		encodeJsonResponse,
+		publicClientAuthorizationOptions...,
+	))
+	r.Methods("GET", "POST").Path("/oauth/device").Name(endpoint.DeviceVerificationRoute).Handler(kithttp.NewServer(
+		endpoints.DeviceVerificationEndpoint,
+		decodeDeviceVerificationRequest,
+		encodeDeviceVerificationResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/oauth/revoke").Name(endpoint.RevocationRoute).Handler(kithttp.NewServer(
+		endpoints.RevokeTokenEndpoint,
+		decodeRevokeTokenRequest,
+		encodeJsonResponse,
+		publicClientAuthorizationOptions...,
+	))
+	r.Methods("POST").Path("/oauth/introspect").Name(endpoint.IntrospectionRoute).Handler(kithttp.NewServer(
+		endpoints.IntrospectEndpoint,
+		decodeIntrospectRequest,
+		encodeJsonResponse,
 		clientAuthorizationOptions...,
 	))
-	r.Methods("POST").Path("/oath/check_token").Handler(kithttp.NewServer(
+	checkTokenHandler := kithttp.NewServer(
 		endpoints.CheckTokenEndpoint,
 		decodeCheckTokenRequest,
 		encodeJsonResponse,
 		clientAuthorizationOptions...,
+	)
+	r.Methods("POST").Path("/oauth/check_token").Handler(checkTokenHandler)
+	// /oath/check_token 为兼容旧调用方保留的别名
+	r.Methods("POST").Path("/oath/check_token").Handler(checkTokenHandler)
+	r.Methods("POST").Path("/oauth/clients").Handler(kithttp.NewServer(
+		endpoints.CreateClientEndpoint,
+		decodeCreateClientRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("GET").Path("/oauth/clients").Handler(kithttp.NewServer(
+		endpoints.ListClientsEndpoint,
+		decodeListClientsRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("PUT").Path("/oauth/clients/{client_id}").Handler(kithttp.NewServer(
+		endpoints.UpdateClientEndpoint,
+		decodeUpdateClientRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("POST").Path("/oauth/clients/{client_id}/disable").Handler(kithttp.NewServer(
+		endpoints.DisableClientEndpoint,
+		decodeDisableClientRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("POST").Path("/oauth/clients/{client_id}/secret").Handler(kithttp.NewServer(
+		endpoints.RotateSecretEndpoint,
+		decodeRotateSecretRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("POST").Path("/oauth/lockouts/unlock").Handler(kithttp.NewServer(
+		endpoints.UnlockLoginEndpoint,
+		decodeUnlockLoginRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("POST").Path("/oauth/users/{user_id}/revoke").Handler(kithttp.NewServer(
+		endpoints.RevokeUserTokensEndpoint,
+		decodeRevokeUserTokensRequest,
+		encodeJsonResponse,
+		adminOptions...,
+	))
+	r.Methods("GET", "POST").Path("/userinfo").Name(endpoint.UserInfoRoute).Handler(kithttp.NewServer(
+		endpoints.UserInfoEndpoint,
+		decodeUserInfoRequest,
+		encodeJsonResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/.well-known/openid-configuration").Handler(kithttp.NewServer(
+		endpoints.OpenIdConfigEndpoint,
+		makeDecodeDiscoveryRequest(r),
+		encodeJsonResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/.well-known/oauth-authorization-server").Handler(kithttp.NewServer(
+		endpoints.ServerMetadataEndpoint,
+		makeDecodeDiscoveryRequest(r),
+		encodeJsonResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/.well-known/jwks.json").Name(endpoint.JwksRoute).Handler(kithttp.NewServer(
+		endpoints.JwksEndpoint,
+		decodeEmptyRequest,
+		encodeJsonResponse,
+		options...,
 	))
 	// create health check handler
 	r.Methods("GET").Path("/health").Handler(kithttp.NewServer(
@@ -66,7 +213,7 @@ func MakeHttpHandler(
 }
 
 func decodeOathRequest(ctx context.Context, req *http.Request) (request interface{}, err error) {
-	grantType := req.URL.Query().Get("grant_type")
+	grantType := req.FormValue("grant_type")
 	if grantType == "" {
 		return nil, ErrorGrantTypeRequest
 	}
@@ -76,13 +223,299 @@ func decodeOathRequest(ctx context.Context, req *http.Request) (request interfac
 	}, nil
 }
 
+func decodeAuthorizeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.AuthorizeRequest{
+		ResponseType:        r.FormValue("response_type"),
+		ClientId:            r.FormValue("client_id"),
+		RedirectUri:         r.FormValue("redirect_uri"),
+		State:               r.FormValue("state"),
+		Scope:               r.FormValue("scope"),
+		Nonce:               r.FormValue("nonce"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+		Username:            r.PostFormValue("username"),
+		Password:            r.PostFormValue("password"),
+		Submit:              r.Method == http.MethodPost,
+		CsrfVerified:        r.Method == http.MethodPost && verifyCsrfToken(r, authorizeCsrfCookie, r.PostFormValue("csrf_token")),
+	}, nil
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>登录授权</title></head>
+<body>
+{{if .Error}}<p style="color:red">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+	<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+	<input type="hidden" name="client_id" value="{{.Request.ClientId}}">
+	<input type="hidden" name="redirect_uri" value="{{.Request.RedirectUri}}">
+	<input type="hidden" name="state" value="{{.Request.State}}">
+	<input type="hidden" name="scope" value="{{.Request.Scope}}">
+	<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+	<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+	<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+	<input type="hidden" name="csrf_token" value="{{.CsrfToken}}">
+	<p>用户名：<input type="text" name="username"></p>
+	<p>密码：<input type="password" name="password"></p>
+	<p><input type="submit" value="授权 {{.Request.ClientId}} 登录"></p>
+</form>
+</body>
+</html>`))
+
+// encodeAuthorizeResponse 每次展示登录页都生成新的 CSRF 令牌，同时写入 cookie 和表单
+func encodeAuthorizeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoint.AuthorizeResponse)
+	if resp.ShowLogin {
+		csrfToken, err := setCsrfCookie(w, authorizeCsrfCookie, "/oauth/authorize")
+		if err != nil {
+			return err
+		}
+		resp.CsrfToken = csrfToken
+		w.Header().Set("Content-Type", "text/html;charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		return loginTemplate.Execute(w, resp)
+	}
+	if resp.RedirectUri != "" {
+		w.Header().Set("Location", resp.RedirectUri)
+		w.WriteHeader(http.StatusFound)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": resp.Error,
+	})
+}
+
+func decodeIntrospectRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	tokenValue := r.PostFormValue("token")
+	if tokenValue == "" {
+		return nil, ErrInvalidIntrospectRequest
+	}
+	return &endpoint.IntrospectRequest{
+		Token:         tokenValue,
+		TokenTypeHint: r.PostFormValue("token_type_hint"),
+	}, nil
+}
+
+// decodeUserInfoRequest 按 RFC 6750 从 Authorization 头或表单的 access_token 中读取访问令牌
+func decodeUserInfoRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	tokenValue := bearerToken(r)
+	if tokenValue == "" {
+		return nil, service.ErrInvalidAccessToken
+	}
+	return &endpoint.UserInfoRequest{
+		Token: tokenValue,
+	}, nil
+}
+
+func bearerToken(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return r.FormValue("access_token")
+}
+
+func decodeRotateSecretRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	gracePeriod := defaultSecretGracePeriod
+	if gracePeriodValue := r.FormValue("grace_period"); gracePeriodValue != "" {
+		seconds, err := strconv.Atoi(gracePeriodValue)
+		if err != nil || seconds < 0 {
+			return nil, ErrInvalidGracePeriod
+		}
+		gracePeriod = time.Duration(seconds) * time.Second
+	}
+	return &endpoint.RotateSecretRequest{
+		ClientId:    mux.Vars(r)["client_id"],
+		GracePeriod: gracePeriod,
+	}, nil
+}
+
+func decodeCreateClientRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := &endpoint.ClientRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, ErrInvalidClientBody
+	}
+	return req, nil
+}
+
+// decodeUpdateClientRequest 以路径中的 client_id 为准
+func decodeUpdateClientRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := &endpoint.ClientRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, ErrInvalidClientBody
+	}
+	req.ClientId = mux.Vars(r)["client_id"]
+	return req, nil
+}
+
+func decodeDisableClientRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.DisableClientRequest{
+		ClientId: mux.Vars(r)["client_id"],
+	}, nil
+}
+
+func decodeListClientsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := &endpoint.ListClientsRequest{Limit: defaultClientPageSize}
+	var err error
+	if offset := r.FormValue("offset"); offset != "" {
+		if req.Offset, err = strconv.Atoi(offset); err != nil || req.Offset < 0 {
+			return nil, ErrInvalidPagination
+		}
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		if req.Limit, err = strconv.Atoi(limit); err != nil || req.Limit < 0 {
+			return nil, ErrInvalidPagination
+		}
+	}
+	if req.Limit == 0 || req.Limit > maxClientPageSize {
+		req.Limit = maxClientPageSize
+	}
+	return req, nil
+}
+
+func decodeUnlockLoginRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req := &endpoint.UnlockLoginRequest{
+		Username: r.FormValue("username"),
+		Ip:       r.FormValue("ip"),
+	}
+	if req.Username == "" && req.Ip == "" {
+		return nil, ErrInvalidUnlockRequest
+	}
+	return req, nil
+}
+
+func decodeRevokeUserTokensRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	userId, err := strconv.ParseInt(mux.Vars(r)["user_id"], 10, 64)
+	if err != nil || userId <= 0 {
+		return nil, ErrInvalidUserId
+	}
+	return &endpoint.RevokeUserTokensRequest{
+		UserId:   userId,
+		ClientId: r.FormValue("client_id"),
+	}, nil
+}
+
+// makeDecodeDiscoveryRequest 元数据中的端点地址取自路由表中命名的路由，路径调整后无需修改元数据
+func makeDecodeDiscoveryRequest(router *mux.Router) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return discoveryRequest(router), nil
+	}
+}
+
+func discoveryRequest(router *mux.Router) *endpoint.OpenIdConfigRequest {
+	routes := make(map[string]string)
+	_ = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if path, err := route.GetPathTemplate(); err == nil && route.GetName() != "" {
+			routes[route.GetName()] = path
+		}
+		return nil
+	})
+	return &endpoint.OpenIdConfigRequest{
+		Routes: routes,
+	}
+}
+
+// makeDecodeDeviceAuthorizationRequest 验证页地址与元数据一样取自命名的路由
+func makeDecodeDeviceAuthorizationRequest(router *mux.Router) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return &endpoint.DeviceAuthorizationRequest{
+			Scope:               r.PostFormValue("scope"),
+			OpenIdConfigRequest: *discoveryRequest(router),
+		}, nil
+	}
+}
+
+func decodeDeviceVerificationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.DeviceVerificationRequest{
+		UserCode: r.FormValue("user_code"),
+		Username: r.PostFormValue("username"),
+		Password: r.PostFormValue("password"),
+		Action:   r.PostFormValue("action"),
+		Submit:   r.Method == http.MethodPost,
+		// 跨站请求无法读取 cookie，也就无法在表单中提交相同的值
+		CsrfVerified: r.Method == http.MethodPost && verifyCsrfToken(r, deviceCsrfCookie, r.PostFormValue("csrf_token")),
+	}, nil
+}
+
+func verifyCsrfToken(r *http.Request, cookieName string, csrfToken string) bool {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie.Value == "" || csrfToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(csrfToken)) == 1
+}
+
+// setCsrfCookie 生成随机的 CSRF 令牌写入限定路径的 cookie，返回的令牌需放入表单一并提交
+func setCsrfCookie(w http.ResponseWriter, cookieName string, path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    csrfToken,
+		Path:     path,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return csrfToken, nil
+}
+
+var deviceVerificationTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>设备授权</title></head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{else}}
+{{if .Error}}<p style="color:red">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/device">
+	<p>设备上显示的代码：<input type="text" name="user_code" value="{{.Request.UserCode}}"></p>
+	{{if .ClientId}}<p>{{.ClientId}} 申请以下权限：{{range .Scope}}{{.}} {{end}}</p>{{end}}
+	<input type="hidden" name="csrf_token" value="{{.CsrfToken}}">
+	<p>用户名：<input type="text" name="username"></p>
+	<p>密码：<input type="password" name="password"></p>
+	<p><button type="submit" name="action" value="approve">授权</button>
+	<button type="submit" name="action" value="deny">拒绝</button></p>
+</form>
+{{end}}
+</body>
+</html>`))
+
+// encodeDeviceVerificationResponse 每次展示验证页都生成新的 CSRF 令牌，同时写入 cookie 和表单
+func encodeDeviceVerificationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoint.DeviceVerificationResponse)
+	if resp.Message == "" {
+		csrfToken, err := setCsrfCookie(w, deviceCsrfCookie, "/oauth/device")
+		if err != nil {
+			return err
+		}
+		resp.CsrfToken = csrfToken
+	}
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	return deviceVerificationTemplate.Execute(w, resp)
+}
+
+func decodeRevokeTokenRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	tokenValue := r.PostFormValue("token")
+	if tokenValue == "" {
+		return nil, ErrInvalidRevokeRequest
+	}
+	return &endpoint.RevokeTokenRequest{
+		Token:         tokenValue,
+		TokenTypeHint: r.PostFormValue("token_type_hint"),
+	}, nil
+}
+
 func decodeCheckTokenRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	tokenValue := r.URL.Query().Get("token")
 	if tokenValue == "" {
-		return nil, ErrorTokenRequest
+		return nil, ErrInvalidRevokeRequest
 	}
 	return &endpoint.CheckTokenRequest{
-		Token: tokenValue,
+		Token:    tokenValue,
+		Audience: r.URL.Query().Get("audience"),
 	}, nil
 }
 
@@ -91,30 +524,97 @@ func encodeJsonResponse(ctx context.Context, w http.ResponseWriter, response int
 	return json.NewEncoder(w).Encode(response)
 }
 
+func decodeEmptyRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
 func decodeHealthCheckRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	return &endpoint.HealthRequest{}, nil
 }
 
-func makeClientAuthorizationContext(clientDetailsService service.ClientDetailsService, logger log.Logger) kithttp.RequestFunc {
+// makeClientIpContext 将终端用户 IP 放入上下文，用于按 IP 的登录限制和限流
+func makeClientIpContext(clientIpResolver *common.ClientIpResolver) kithttp.RequestFunc {
+	return func(ctx context.Context, request *http.Request) context.Context {
+		return service.WithClientIp(ctx, clientIpResolver.RequestClientIp(request))
+	}
+}
+
+// makeAccessTokenContext 将请求携带的访问令牌放入上下文，由后台接口的中间件校验
+func makeAccessTokenContext() kithttp.RequestFunc {
+	return func(ctx context.Context, request *http.Request) context.Context {
+		return context.WithValue(ctx, endpoint.OAuth2AccessTokenKey, bearerToken(request))
+	}
+}
+
+// makeClientCredentialsContext 将 Basic 认证信息放入上下文，allowPublic 时没有 Basic 认证的请求按公开客户端读取 client_id，
+// 凭证由 endpoint 层在限流之后校验
+func makeClientCredentialsContext(allowPublic bool) kithttp.RequestFunc {
 	return func(ctx context.Context, request *http.Request) context.Context {
-		if userID, userSecret, ok := request.BasicAuth(); ok {
-			clientDetail, err := clientDetailsService.GetClientDetailByClientId(ctx, userID, userSecret)
-			if err == nil {
-				return context.WithValue(ctx, endpoint.OAuth2ClientDetailsKey, clientDetail)
-			}
+		if clientId, clientSecret, ok := request.BasicAuth(); ok {
+			return context.WithValue(ctx, endpoint.OAuth2ClientCredentialsKey, &endpoint.ClientCredentials{
+				ClientId:     clientId,
+				ClientSecret: clientSecret,
+			})
+		}
+		if clientId := request.FormValue("client_id"); allowPublic && clientId != "" {
+			return context.WithValue(ctx, endpoint.OAuth2ClientCredentialsKey, &endpoint.ClientCredentials{
+				ClientId: clientId,
+				Public:   true,
+			})
 		}
-		return context.WithValue(ctx, endpoint.OAuth2ErrorKey, encodeError)
+		return ctx
 	}
 }
 
 // encode errors from business-logic
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	switch err {
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
+	w.Header().Set("Cache-Control", "no-store")
+	oauth2Err, ok := err.(*service.OAuth2Error)
+	if !ok {
+		switch err {
+		case model.ErrClientNotFound:
+			w.WriteHeader(http.StatusNotFound)
+			oauth2Err = service.NewOAuth2Error(service.ErrorCodeInvalidRequest, err.Error())
+		case plugins.ErrLimitExceed:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			oauth2Err = service.NewOAuth2Error(service.ErrorCodeTemporarilyUnavailable, err.Error())
+		default:
+			// 内部错误只写日志，不把细节返回给客户端
+			w.WriteHeader(http.StatusInternalServerError)
+			oauth2Err = service.NewOAuth2Error(service.ErrorCodeServerError, "internal server error")
+		}
+	} else {
+		switch oauth2Err.ErrorCode {
+		case service.ErrorCodeInvalidClient:
+			// RFC 6749 5.2 节，客户端认证失败时提示使用 Basic 认证
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		case service.ErrorCodeInvalidToken, service.ErrorCodeInsufficientScope:
+			// RFC 6750 3.1 节
+			w.Header().Set("WWW-Authenticate", `Bearer error="`+oauth2Err.ErrorCode+`"`)
+		}
+		w.WriteHeader(oauth2StatusCode(oauth2Err.ErrorCode))
 	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
+		"error":             oauth2Err.ErrorCode,
+		"error_description": oauth2Err.Description,
 	})
 }
+
+// oauth2StatusCode 错误码对应的 HTTP 状态码，RFC 6749 5.2 节未单独规定的都使用 400，
+// 包括 RFC 8628 3.5 节设备轮询返回的 authorization_pending、slow_down、access_denied 和 expired_token
+func oauth2StatusCode(errorCode string) int {
+	switch errorCode {
+	case service.ErrorCodeInvalidClient, service.ErrorCodeInvalidToken:
+		return http.StatusUnauthorized
+	case service.ErrorCodeInsufficientScope:
+		return http.StatusForbidden
+	case service.ErrorCodeServerError:
+		return http.StatusInternalServerError
+	case service.ErrorCodeTemporarilyUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
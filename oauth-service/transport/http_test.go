@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"SecondKill/oauth-service/endpoint"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOathRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		form          url.Values
+		wantGrantType string
+		wantErr       error
+	}{
+		{name: "grant type in the form body", form: url.Values{"grant_type": {"password"}}, wantGrantType: "password"},
+		{name: "grant type in the query", query: "grant_type=client_credentials", wantGrantType: "client_credentials"},
+		{name: "missing grant type", form: url.Values{"username": {"alice"}}, wantErr: ErrorGrantTypeRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/oauth/token?"+tt.query, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request, err := decodeOathRequest(context.Background(), req)
+			if err != tt.wantErr {
+				t.Fatalf("decodeOathRequest() err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && request.(*endpoint.TokenRequest).GrantType != tt.wantGrantType {
+				t.Fatalf("grant type = %q, want %q", request.(*endpoint.TokenRequest).GrantType, tt.wantGrantType)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCsrfToken(t *testing.T) {
+	// 展示登录页时写入 cookie 和表单的 CSRF 令牌
+	recorder := httptest.NewRecorder()
+	if err := encodeAuthorizeResponse(context.Background(), recorder, endpoint.AuthorizeResponse{ShowLogin: true, Request: &endpoint.AuthorizeRequest{}}); err != nil {
+		t.Fatalf("encodeAuthorizeResponse() err = %v", err)
+	}
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != authorizeCsrfCookie || cookies[0].Value == "" {
+		t.Fatalf("cookies = %v, want a %s cookie", cookies, authorizeCsrfCookie)
+	}
+	csrfToken := cookies[0].Value
+	if !strings.Contains(recorder.Body.String(), `name="csrf_token" value="`+csrfToken+`"`) {
+		t.Fatal("login form does not carry the csrf token")
+	}
+	tests := []struct {
+		name      string
+		cookie    string
+		csrfToken string
+		want      bool
+	}{
+		{name: "matching cookie and form", cookie: csrfToken, csrfToken: csrfToken, want: true},
+		{name: "cross site post without cookie", csrfToken: csrfToken},
+		{name: "form without token", cookie: csrfToken},
+		{name: "mismatched token", cookie: csrfToken, csrfToken: "forged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"username": {"alice"}, "password": {"secret"}, "csrf_token": {tt.csrfToken}}
+			req, _ := http.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: authorizeCsrfCookie, Value: tt.cookie})
+			}
+			request, err := decodeAuthorizeRequest(context.Background(), req)
+			if err != nil {
+				t.Fatalf("decodeAuthorizeRequest() err = %v", err)
+			}
+			if verified := request.(*endpoint.AuthorizeRequest).CsrfVerified; verified != tt.want {
+				t.Fatalf("CsrfVerified = %v, want %v", verified, tt.want)
+			}
+		})
+	}
+}
@@ -25,7 +25,9 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type CheckTokenRequest struct {
-	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// 调用方服务的客户端 ID，令牌限定了受众时必须与之一致
+	Audience             string   `protobuf:"bytes,2,opt,name=audience,proto3" json:"audience,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -63,11 +65,22 @@ func (m *CheckTokenRequest) GetToken() string {
 	return ""
 }
 
+func (m *CheckTokenRequest) GetAudience() string {
+	if m != nil {
+		return m.Audience
+	}
+	return ""
+}
+
 type ClientDetails struct {
 	ClientId                    string   `protobuf:"bytes,1,opt,name=clientId,proto3" json:"clientId,omitempty"`
 	AccessTokenValiditySeconds  int32    `protobuf:"varint,2,opt,name=accessTokenValiditySeconds,proto3" json:"accessTokenValiditySeconds,omitempty"`
 	RefreshTokenValiditySeconds int32    `protobuf:"varint,3,opt,name=refreshTokenValiditySeconds,proto3" json:"refreshTokenValiditySeconds,omitempty"`
 	AuthorizedGrantTypes        []string `protobuf:"bytes,4,rep,name=authorizedGrantTypes,proto3" json:"authorizedGrantTypes,omitempty"`
+	RegisteredRedirectUri       string   `protobuf:"bytes,5,opt,name=registeredRedirectUri,proto3" json:"registeredRedirectUri,omitempty"`
+	Scope                       []string `protobuf:"bytes,6,rep,name=scope,proto3" json:"scope,omitempty"`
+	Public                      bool     `protobuf:"varint,7,opt,name=public,proto3" json:"public,omitempty"`
+	Disabled                    bool     `protobuf:"varint,8,opt,name=disabled,proto3" json:"disabled,omitempty"`
 	XXX_NoUnkeyedLiteral        struct{} `json:"-"`
 	XXX_unrecognized            []byte   `json:"-"`
 	XXX_sizecache               int32    `json:"-"`
@@ -126,6 +139,34 @@ func (m *ClientDetails) GetAuthorizedGrantTypes() []string {
 	return nil
 }
 
+func (m *ClientDetails) GetRegisteredRedirectUri() string {
+	if m != nil {
+		return m.RegisteredRedirectUri
+	}
+	return ""
+}
+
+func (m *ClientDetails) GetScope() []string {
+	if m != nil {
+		return m.Scope
+	}
+	return nil
+}
+
+func (m *ClientDetails) GetPublic() bool {
+	if m != nil {
+		return m.Public
+	}
+	return false
+}
+
+func (m *ClientDetails) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
 type UserDetails struct {
 	UserId               int64    `protobuf:"varint,1,opt,name=userId,proto3" json:"userId,omitempty"`
 	Username             string   `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
@@ -182,13 +223,17 @@ func (m *UserDetails) GetAuthorities() []string {
 }
 
 type CheckTokenResponse struct {
-	UserDetails          *UserDetails   `protobuf:"bytes,1,opt,name=userDetails,proto3" json:"userDetails,omitempty"`
-	ClientDetails        *ClientDetails `protobuf:"bytes,2,opt,name=clientDetails,proto3" json:"clientDetails,omitempty"`
-	IsValidToken         bool           `protobuf:"varint,3,opt,name=isValidToken,proto3" json:"isValidToken,omitempty"`
-	Err                  string         `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
-	XXX_unrecognized     []byte         `json:"-"`
-	XXX_sizecache        int32          `json:"-"`
+	UserDetails   *UserDetails   `protobuf:"bytes,1,opt,name=userDetails,proto3" json:"userDetails,omitempty"`
+	ClientDetails *ClientDetails `protobuf:"bytes,2,opt,name=clientDetails,proto3" json:"clientDetails,omitempty"`
+	IsValidToken  bool           `protobuf:"varint,3,opt,name=isValidToken,proto3" json:"isValidToken,omitempty"`
+	Err           string         `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
+	// 令牌被授予的权限范围
+	Scope []string `protobuf:"bytes,5,rep,name=scope,proto3" json:"scope,omitempty"`
+	// 令牌的受众，为空时不限定受众
+	Audience             string   `protobuf:"bytes,6,opt,name=audience,proto3" json:"audience,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *CheckTokenResponse) Reset()         { *m = CheckTokenResponse{} }
@@ -244,39 +289,686 @@ func (m *CheckTokenResponse) GetErr() string {
 	return ""
 }
 
+func (m *CheckTokenResponse) GetScope() []string {
+	if m != nil {
+		return m.Scope
+	}
+	return nil
+}
+
+func (m *CheckTokenResponse) GetAudience() string {
+	if m != nil {
+		return m.Audience
+	}
+	return ""
+}
+
+type ClientRequest struct {
+	ClientDetails *ClientDetails `protobuf:"bytes,1,opt,name=clientDetails,proto3" json:"clientDetails,omitempty"`
+	// 注册时可指定密钥，为空时自动生成
+	ClientSecret         string   `protobuf:"bytes,2,opt,name=clientSecret,proto3" json:"clientSecret,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ClientRequest) Reset()         { *m = ClientRequest{} }
+func (m *ClientRequest) String() string { return proto.CompactTextString(m) }
+func (*ClientRequest) ProtoMessage()    {}
+func (*ClientRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{4}
+}
+
+func (m *ClientRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClientRequest.Unmarshal(m, b)
+}
+func (m *ClientRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClientRequest.Marshal(b, m, deterministic)
+}
+func (m *ClientRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClientRequest.Merge(m, src)
+}
+func (m *ClientRequest) XXX_Size() int {
+	return xxx_messageInfo_ClientRequest.Size(m)
+}
+func (m *ClientRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClientRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClientRequest proto.InternalMessageInfo
+
+func (m *ClientRequest) GetClientDetails() *ClientDetails {
+	if m != nil {
+		return m.ClientDetails
+	}
+	return nil
+}
+
+func (m *ClientRequest) GetClientSecret() string {
+	if m != nil {
+		return m.ClientSecret
+	}
+	return ""
+}
+
+type ClientResponse struct {
+	ClientDetails *ClientDetails `protobuf:"bytes,1,opt,name=clientDetails,proto3" json:"clientDetails,omitempty"`
+	// 只在注册时返回一次密钥明文
+	ClientSecret         string   `protobuf:"bytes,2,opt,name=clientSecret,proto3" json:"clientSecret,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ClientResponse) Reset()         { *m = ClientResponse{} }
+func (m *ClientResponse) String() string { return proto.CompactTextString(m) }
+func (*ClientResponse) ProtoMessage()    {}
+func (*ClientResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{5}
+}
+
+func (m *ClientResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClientResponse.Unmarshal(m, b)
+}
+func (m *ClientResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClientResponse.Marshal(b, m, deterministic)
+}
+func (m *ClientResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClientResponse.Merge(m, src)
+}
+func (m *ClientResponse) XXX_Size() int {
+	return xxx_messageInfo_ClientResponse.Size(m)
+}
+func (m *ClientResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClientResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClientResponse proto.InternalMessageInfo
+
+func (m *ClientResponse) GetClientDetails() *ClientDetails {
+	if m != nil {
+		return m.ClientDetails
+	}
+	return nil
+}
+
+func (m *ClientResponse) GetClientSecret() string {
+	if m != nil {
+		return m.ClientSecret
+	}
+	return ""
+}
+
+type DisableClientRequest struct {
+	ClientId             string   `protobuf:"bytes,1,opt,name=clientId,proto3" json:"clientId,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DisableClientRequest) Reset()         { *m = DisableClientRequest{} }
+func (m *DisableClientRequest) String() string { return proto.CompactTextString(m) }
+func (*DisableClientRequest) ProtoMessage()    {}
+func (*DisableClientRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{6}
+}
+
+func (m *DisableClientRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DisableClientRequest.Unmarshal(m, b)
+}
+func (m *DisableClientRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DisableClientRequest.Marshal(b, m, deterministic)
+}
+func (m *DisableClientRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DisableClientRequest.Merge(m, src)
+}
+func (m *DisableClientRequest) XXX_Size() int {
+	return xxx_messageInfo_DisableClientRequest.Size(m)
+}
+func (m *DisableClientRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DisableClientRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DisableClientRequest proto.InternalMessageInfo
+
+func (m *DisableClientRequest) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+type DisableClientResponse struct {
+	Success              bool     `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DisableClientResponse) Reset()         { *m = DisableClientResponse{} }
+func (m *DisableClientResponse) String() string { return proto.CompactTextString(m) }
+func (*DisableClientResponse) ProtoMessage()    {}
+func (*DisableClientResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{7}
+}
+
+func (m *DisableClientResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DisableClientResponse.Unmarshal(m, b)
+}
+func (m *DisableClientResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DisableClientResponse.Marshal(b, m, deterministic)
+}
+func (m *DisableClientResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DisableClientResponse.Merge(m, src)
+}
+func (m *DisableClientResponse) XXX_Size() int {
+	return xxx_messageInfo_DisableClientResponse.Size(m)
+}
+func (m *DisableClientResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DisableClientResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DisableClientResponse proto.InternalMessageInfo
+
+func (m *DisableClientResponse) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+type ListClientsRequest struct {
+	Offset               int32    `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListClientsRequest) Reset()         { *m = ListClientsRequest{} }
+func (m *ListClientsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListClientsRequest) ProtoMessage()    {}
+func (*ListClientsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{8}
+}
+
+func (m *ListClientsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListClientsRequest.Unmarshal(m, b)
+}
+func (m *ListClientsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListClientsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListClientsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListClientsRequest.Merge(m, src)
+}
+func (m *ListClientsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListClientsRequest.Size(m)
+}
+func (m *ListClientsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListClientsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListClientsRequest proto.InternalMessageInfo
+
+func (m *ListClientsRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *ListClientsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type ListClientsResponse struct {
+	Clients              []*ClientDetails `protobuf:"bytes,1,rep,name=clients,proto3" json:"clients,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ListClientsResponse) Reset()         { *m = ListClientsResponse{} }
+func (m *ListClientsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListClientsResponse) ProtoMessage()    {}
+func (*ListClientsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{9}
+}
+
+func (m *ListClientsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListClientsResponse.Unmarshal(m, b)
+}
+func (m *ListClientsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListClientsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListClientsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListClientsResponse.Merge(m, src)
+}
+func (m *ListClientsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListClientsResponse.Size(m)
+}
+func (m *ListClientsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListClientsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListClientsResponse proto.InternalMessageInfo
+
+func (m *ListClientsResponse) GetClients() []*ClientDetails {
+	if m != nil {
+		return m.Clients
+	}
+	return nil
+}
+
+type TokenRequest struct {
+	GrantType string `protobuf:"bytes,1,opt,name=grantType,proto3" json:"grantType,omitempty"`
+	// password 模式
+	Username string `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Password string `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	// 以空格分隔，为空时授予客户端注册的全部范围
+	Scope string `protobuf:"bytes,4,opt,name=scope,proto3" json:"scope,omitempty"`
+	// authorization_code 模式
+	Code         string `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+	RedirectUri  string `protobuf:"bytes,6,opt,name=redirectUri,proto3" json:"redirectUri,omitempty"`
+	CodeVerifier string `protobuf:"bytes,7,opt,name=codeVerifier,proto3" json:"codeVerifier,omitempty"`
+	// token-exchange 模式，audience 为下游服务的客户端 ID
+	SubjectToken         string   `protobuf:"bytes,8,opt,name=subjectToken,proto3" json:"subjectToken,omitempty"`
+	SubjectTokenType     string   `protobuf:"bytes,9,opt,name=subjectTokenType,proto3" json:"subjectTokenType,omitempty"`
+	Audience             string   `protobuf:"bytes,10,opt,name=audience,proto3" json:"audience,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenRequest) Reset()         { *m = TokenRequest{} }
+func (m *TokenRequest) String() string { return proto.CompactTextString(m) }
+func (*TokenRequest) ProtoMessage()    {}
+func (*TokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{10}
+}
+
+func (m *TokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenRequest.Unmarshal(m, b)
+}
+func (m *TokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenRequest.Marshal(b, m, deterministic)
+}
+func (m *TokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenRequest.Merge(m, src)
+}
+func (m *TokenRequest) XXX_Size() int {
+	return xxx_messageInfo_TokenRequest.Size(m)
+}
+func (m *TokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenRequest proto.InternalMessageInfo
+
+func (m *TokenRequest) GetGrantType() string {
+	if m != nil {
+		return m.GrantType
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetScope() string {
+	if m != nil {
+		return m.Scope
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetRedirectUri() string {
+	if m != nil {
+		return m.RedirectUri
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetCodeVerifier() string {
+	if m != nil {
+		return m.CodeVerifier
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetSubjectToken() string {
+	if m != nil {
+		return m.SubjectToken
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetSubjectTokenType() string {
+	if m != nil {
+		return m.SubjectTokenType
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetAudience() string {
+	if m != nil {
+		return m.Audience
+	}
+	return ""
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `protobuf:"bytes,1,opt,name=refreshToken,proto3" json:"refreshToken,omitempty"`
+	// 只能申请原授权范围的子集
+	Scope                string   `protobuf:"bytes,2,opt,name=scope,proto3" json:"scope,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RefreshTokenRequest) Reset()         { *m = RefreshTokenRequest{} }
+func (m *RefreshTokenRequest) String() string { return proto.CompactTextString(m) }
+func (*RefreshTokenRequest) ProtoMessage()    {}
+func (*RefreshTokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{11}
+}
+
+func (m *RefreshTokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RefreshTokenRequest.Unmarshal(m, b)
+}
+func (m *RefreshTokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RefreshTokenRequest.Marshal(b, m, deterministic)
+}
+func (m *RefreshTokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RefreshTokenRequest.Merge(m, src)
+}
+func (m *RefreshTokenRequest) XXX_Size() int {
+	return xxx_messageInfo_RefreshTokenRequest.Size(m)
+}
+func (m *RefreshTokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RefreshTokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RefreshTokenRequest proto.InternalMessageInfo
+
+func (m *RefreshTokenRequest) GetRefreshToken() string {
+	if m != nil {
+		return m.RefreshToken
+	}
+	return ""
+}
+
+func (m *RefreshTokenRequest) GetScope() string {
+	if m != nil {
+		return m.Scope
+	}
+	return ""
+}
+
+type TokenResponse struct {
+	AccessToken string `protobuf:"bytes,1,opt,name=accessToken,proto3" json:"accessToken,omitempty"`
+	TokenType   string `protobuf:"bytes,2,opt,name=tokenType,proto3" json:"tokenType,omitempty"`
+	// 访问令牌剩余有效时间，秒
+	ExpiresIn    int64    `protobuf:"varint,3,opt,name=expiresIn,proto3" json:"expiresIn,omitempty"`
+	RefreshToken string   `protobuf:"bytes,4,opt,name=refreshToken,proto3" json:"refreshToken,omitempty"`
+	Scope        []string `protobuf:"bytes,5,rep,name=scope,proto3" json:"scope,omitempty"`
+	IdToken      string   `protobuf:"bytes,6,opt,name=idToken,proto3" json:"idToken,omitempty"`
+	// token-exchange 模式签发的令牌类型
+	IssuedTokenType      string   `protobuf:"bytes,7,opt,name=issuedTokenType,proto3" json:"issuedTokenType,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenResponse) Reset()         { *m = TokenResponse{} }
+func (m *TokenResponse) String() string { return proto.CompactTextString(m) }
+func (*TokenResponse) ProtoMessage()    {}
+func (*TokenResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{12}
+}
+
+func (m *TokenResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenResponse.Unmarshal(m, b)
+}
+func (m *TokenResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenResponse.Marshal(b, m, deterministic)
+}
+func (m *TokenResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenResponse.Merge(m, src)
+}
+func (m *TokenResponse) XXX_Size() int {
+	return xxx_messageInfo_TokenResponse.Size(m)
+}
+func (m *TokenResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenResponse proto.InternalMessageInfo
+
+func (m *TokenResponse) GetAccessToken() string {
+	if m != nil {
+		return m.AccessToken
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetTokenType() string {
+	if m != nil {
+		return m.TokenType
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetExpiresIn() int64 {
+	if m != nil {
+		return m.ExpiresIn
+	}
+	return 0
+}
+
+func (m *TokenResponse) GetRefreshToken() string {
+	if m != nil {
+		return m.RefreshToken
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetScope() []string {
+	if m != nil {
+		return m.Scope
+	}
+	return nil
+}
+
+func (m *TokenResponse) GetIdToken() string {
+	if m != nil {
+		return m.IdToken
+	}
+	return ""
+}
+
+func (m *TokenResponse) GetIssuedTokenType() string {
+	if m != nil {
+		return m.IssuedTokenType
+	}
+	return ""
+}
+
+type RevokeRequest struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	TokenTypeHint        string   `protobuf:"bytes,2,opt,name=tokenTypeHint,proto3" json:"tokenTypeHint,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeRequest) Reset()         { *m = RevokeRequest{} }
+func (m *RevokeRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeRequest) ProtoMessage()    {}
+func (*RevokeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{13}
+}
+
+func (m *RevokeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeRequest.Unmarshal(m, b)
+}
+func (m *RevokeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeRequest.Merge(m, src)
+}
+func (m *RevokeRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeRequest.Size(m)
+}
+func (m *RevokeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeRequest proto.InternalMessageInfo
+
+func (m *RevokeRequest) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *RevokeRequest) GetTokenTypeHint() string {
+	if m != nil {
+		return m.TokenTypeHint
+	}
+	return ""
+}
+
+type RevokeResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeResponse) Reset()         { *m = RevokeResponse{} }
+func (m *RevokeResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeResponse) ProtoMessage()    {}
+func (*RevokeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7ce0b12f599e9f07, []int{14}
+}
+
+func (m *RevokeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeResponse.Unmarshal(m, b)
+}
+func (m *RevokeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeResponse.Marshal(b, m, deterministic)
+}
+func (m *RevokeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeResponse.Merge(m, src)
+}
+func (m *RevokeResponse) XXX_Size() int {
+	return xxx_messageInfo_RevokeResponse.Size(m)
+}
+func (m *RevokeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeResponse proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*CheckTokenRequest)(nil), "pb.CheckTokenRequest")
 	proto.RegisterType((*ClientDetails)(nil), "pb.ClientDetails")
 	proto.RegisterType((*UserDetails)(nil), "pb.UserDetails")
 	proto.RegisterType((*CheckTokenResponse)(nil), "pb.CheckTokenResponse")
+	proto.RegisterType((*ClientRequest)(nil), "pb.ClientRequest")
+	proto.RegisterType((*ClientResponse)(nil), "pb.ClientResponse")
+	proto.RegisterType((*DisableClientRequest)(nil), "pb.DisableClientRequest")
+	proto.RegisterType((*DisableClientResponse)(nil), "pb.DisableClientResponse")
+	proto.RegisterType((*ListClientsRequest)(nil), "pb.ListClientsRequest")
+	proto.RegisterType((*ListClientsResponse)(nil), "pb.ListClientsResponse")
+	proto.RegisterType((*TokenRequest)(nil), "pb.TokenRequest")
+	proto.RegisterType((*RefreshTokenRequest)(nil), "pb.RefreshTokenRequest")
+	proto.RegisterType((*TokenResponse)(nil), "pb.TokenResponse")
+	proto.RegisterType((*RevokeRequest)(nil), "pb.RevokeRequest")
+	proto.RegisterType((*RevokeResponse)(nil), "pb.RevokeResponse")
 }
 
 func init() { proto.RegisterFile("oauth.proto", fileDescriptor_7ce0b12f599e9f07) }
 
 var fileDescriptor_7ce0b12f599e9f07 = []byte{
-	// 351 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x92, 0x41, 0x4f, 0xc2, 0x30,
-	0x14, 0xc7, 0x33, 0x06, 0x04, 0xde, 0x20, 0x4a, 0x83, 0x64, 0xc1, 0xcb, 0xb2, 0x13, 0x5e, 0x48,
-	0x9c, 0x07, 0x0f, 0x26, 0x46, 0x83, 0x89, 0x31, 0x1e, 0x4c, 0x0a, 0x7a, 0x1f, 0xdd, 0x33, 0x6b,
-	0xc0, 0x75, 0xb6, 0x9d, 0x09, 0x7e, 0x30, 0xbf, 0x8f, 0xdf, 0xc4, 0xb4, 0x4c, 0x18, 0x84, 0x70,
-	0xeb, 0xff, 0xed, 0xbf, 0xf7, 0xfe, 0xbf, 0xd7, 0x82, 0x27, 0xe2, 0x42, 0xa7, 0xe3, 0x5c, 0x0a,
-	0x2d, 0x48, 0x2d, 0x9f, 0x87, 0x17, 0xd0, 0x9b, 0xa4, 0xc8, 0x16, 0x33, 0xb1, 0xc0, 0x8c, 0xe2,
-	0x67, 0x81, 0x4a, 0x93, 0x3e, 0x34, 0xb4, 0xd1, 0xbe, 0x13, 0x38, 0xa3, 0x36, 0x5d, 0x8b, 0xf0,
-	0xd7, 0x81, 0xee, 0x64, 0xc9, 0x31, 0xd3, 0x0f, 0xa8, 0x63, 0xbe, 0x54, 0x64, 0x08, 0x2d, 0x66,
-	0x0b, 0x4f, 0x49, 0x69, 0xdd, 0x68, 0x72, 0x0b, 0xc3, 0x98, 0x31, 0x54, 0xca, 0x76, 0x7e, 0x8b,
-	0x97, 0x3c, 0xe1, 0x7a, 0x35, 0x45, 0x26, 0xb2, 0x44, 0xf9, 0xb5, 0xc0, 0x19, 0x35, 0xe8, 0x11,
-	0x07, 0xb9, 0x83, 0x73, 0x89, 0xef, 0x12, 0x55, 0x7a, 0xb0, 0x81, 0x6b, 0x1b, 0x1c, 0xb3, 0x90,
-	0x08, 0xfa, 0x06, 0x56, 0x48, 0xfe, 0x8d, 0xc9, 0xa3, 0x8c, 0x33, 0x3d, 0x5b, 0xe5, 0xa8, 0xfc,
-	0x7a, 0xe0, 0x8e, 0xda, 0xf4, 0xe0, 0xb7, 0x90, 0x81, 0xf7, 0xaa, 0x50, 0xfe, 0x03, 0x0e, 0xa0,
-	0x59, 0x28, 0x94, 0x25, 0x9e, 0x4b, 0x4b, 0x65, 0xc0, 0xcd, 0x29, 0x8b, 0x3f, 0xd0, 0xa2, 0xb4,
-	0xe9, 0x46, 0x93, 0x00, 0xbc, 0xb2, 0xb5, 0xe6, 0x68, 0x82, 0x9a, 0x69, 0xd5, 0x52, 0xf8, 0xe3,
-	0x00, 0xa9, 0x2e, 0x5d, 0xe5, 0x22, 0x53, 0x48, 0x2e, 0xc1, 0x2b, 0xb6, 0xb3, 0xed, 0x44, 0x2f,
-	0x3a, 0x19, 0xe7, 0xf3, 0x71, 0x25, 0x12, 0xad, 0x7a, 0xc8, 0x35, 0x74, 0x59, 0xf5, 0x46, 0x6c,
-	0x18, 0x2f, 0xea, 0x99, 0x9f, 0x76, 0xae, 0x8a, 0xee, 0xfa, 0x48, 0x08, 0x1d, 0xae, 0xec, 0xc2,
-	0x6c, 0x06, 0xbb, 0xce, 0x16, 0xdd, 0xa9, 0x91, 0x53, 0x70, 0x51, 0x4a, 0xbf, 0x6e, 0xf9, 0xcc,
-	0x31, 0x7a, 0x86, 0xce, 0xcb, 0x7d, 0xa1, 0xd3, 0x29, 0xca, 0x2f, 0xce, 0x90, 0xdc, 0x00, 0x6c,
-	0x39, 0xc8, 0x99, 0x9d, 0xba, 0xff, 0x98, 0x86, 0x83, 0xfd, 0xf2, 0x1a, 0x77, 0xde, 0xb4, 0x8f,
-	0xf0, 0xea, 0x2f, 0x00, 0x00, 0xff, 0xff, 0xd3, 0xa2, 0x2a, 0x89, 0x93, 0x02, 0x00, 0x00,
+	// 861 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0x4b, 0x6f, 0xeb, 0x44,
+	0x14, 0x56, 0xde, 0xc9, 0x49, 0xd2, 0xc7, 0xb4, 0x0d, 0x26, 0xb0, 0x88, 0x2c, 0x16, 0x11, 0xa0,
+	0xa2, 0x06, 0x10, 0x12, 0x48, 0x15, 0xb4, 0x45, 0x50, 0x81, 0x54, 0x69, 0xfa, 0xd8, 0x3b, 0xf6,
+	0x49, 0x33, 0x34, 0xb5, 0xcd, 0xcc, 0xb8, 0x50, 0x7e, 0x1d, 0xbf, 0x83, 0x1d, 0x3b, 0x24, 0xfe,
+	0xc4, 0xd5, 0x3c, 0x1c, 0x8f, 0x13, 0x2b, 0xba, 0x77, 0x71, 0x77, 0x39, 0xdf, 0x9c, 0xe7, 0x77,
+	0x1e, 0x0e, 0xf4, 0x93, 0x20, 0x93, 0xcb, 0xd3, 0x94, 0x27, 0x32, 0x21, 0xf5, 0x74, 0xee, 0xff,
+	0x08, 0x87, 0x97, 0x4b, 0x0c, 0x9f, 0xee, 0x92, 0x27, 0x8c, 0x29, 0xfe, 0x9e, 0xa1, 0x90, 0xe4,
+	0x18, 0x5a, 0x52, 0xc9, 0x5e, 0x6d, 0x52, 0x9b, 0xf6, 0xa8, 0x11, 0xc8, 0x18, 0xba, 0x41, 0x16,
+	0x31, 0x8c, 0x43, 0xf4, 0xea, 0xfa, 0x61, 0x2d, 0xfb, 0xff, 0xd6, 0x61, 0x78, 0xb9, 0x62, 0x18,
+	0xcb, 0x2b, 0x94, 0x01, 0x5b, 0x09, 0xa5, 0x1d, 0x6a, 0xe0, 0x3a, 0xb2, 0x6e, 0xd6, 0x32, 0x39,
+	0x87, 0x71, 0x10, 0x86, 0x28, 0x84, 0x8e, 0xfa, 0x10, 0xac, 0x58, 0xc4, 0xe4, 0xeb, 0x2d, 0x86,
+	0x49, 0x1c, 0x09, 0xed, 0xbb, 0x45, 0x77, 0x68, 0x90, 0xef, 0xe1, 0x23, 0x8e, 0x0b, 0x8e, 0x62,
+	0x59, 0xe9, 0xa0, 0xa1, 0x1d, 0xec, 0x52, 0x21, 0x33, 0x38, 0x56, 0x44, 0x24, 0x9c, 0xfd, 0x85,
+	0xd1, 0x4f, 0x3c, 0x88, 0xe5, 0xdd, 0x6b, 0x8a, 0xc2, 0x6b, 0x4e, 0x1a, 0xd3, 0x1e, 0xad, 0x7c,
+	0x23, 0x5f, 0xc1, 0x09, 0xc7, 0x47, 0x26, 0x24, 0x72, 0x8c, 0x28, 0x46, 0x8c, 0x63, 0x28, 0xef,
+	0x39, 0xf3, 0x5a, 0xba, 0xbc, 0xea, 0x47, 0xc5, 0xa5, 0x08, 0x93, 0x14, 0xbd, 0xb6, 0x76, 0x6d,
+	0x04, 0x32, 0x82, 0x76, 0x9a, 0xcd, 0x57, 0x2c, 0xf4, 0x3a, 0x93, 0xda, 0xb4, 0x4b, 0xad, 0xa4,
+	0x58, 0x8b, 0x98, 0x08, 0xe6, 0x2b, 0x8c, 0xbc, 0xae, 0x7e, 0x59, 0xcb, 0x7e, 0x08, 0xfd, 0x7b,
+	0x81, 0x3c, 0x27, 0x78, 0x04, 0xed, 0x4c, 0x20, 0xb7, 0xf4, 0x36, 0xa8, 0x95, 0x94, 0x0b, 0xf5,
+	0x2b, 0x0e, 0x9e, 0xd7, 0x6d, 0xca, 0x65, 0x32, 0x81, 0xbe, 0x2d, 0x4d, 0x32, 0x54, 0x44, 0xa9,
+	0x94, 0x5c, 0xc8, 0xff, 0xaf, 0x06, 0xc4, 0x1d, 0x08, 0x91, 0x26, 0xb1, 0x40, 0x72, 0x06, 0xfd,
+	0xac, 0x88, 0xad, 0x23, 0xf6, 0x67, 0xfb, 0xa7, 0xe9, 0xfc, 0xd4, 0x49, 0x89, 0xba, 0x3a, 0xe4,
+	0x1b, 0x18, 0x86, 0xee, 0x44, 0xe8, 0x64, 0xfa, 0xb3, 0x43, 0x65, 0x54, 0x1a, 0x15, 0x5a, 0xd6,
+	0x23, 0x3e, 0x0c, 0x98, 0xd0, 0x0d, 0xd3, 0x39, 0xe8, 0x76, 0x76, 0x69, 0x09, 0x23, 0x07, 0xd0,
+	0x40, 0xce, 0xbd, 0xa6, 0xae, 0x4f, 0xfd, 0x2c, 0x78, 0x6e, 0xb9, 0x3c, 0xbb, 0x33, 0xdb, 0xde,
+	0x98, 0xd9, 0x55, 0x3e, 0xb2, 0xf9, 0xd8, 0x6f, 0x65, 0x5c, 0x7b, 0xfb, 0x8c, 0x0d, 0x70, 0x8b,
+	0x21, 0x47, 0x69, 0x69, 0x2f, 0x61, 0xfe, 0x33, 0xec, 0xe5, 0xd1, 0x2c, 0xa7, 0xef, 0x35, 0xdc,
+	0x0c, 0x8e, 0xaf, 0xcc, 0xe0, 0x94, 0x6b, 0xdc, 0xb1, 0x96, 0xfe, 0x19, 0x9c, 0x6c, 0xd8, 0xd8,
+	0x4c, 0x3d, 0xe8, 0x88, 0x4c, 0xaf, 0xa3, 0xb6, 0xe9, 0xd2, 0x5c, 0xf4, 0x2f, 0x80, 0xfc, 0xca,
+	0x84, 0x34, 0xfa, 0x22, 0x0f, 0x32, 0x82, 0x76, 0xb2, 0x58, 0x08, 0x94, 0x5a, 0xbd, 0x45, 0xad,
+	0xa4, 0x7a, 0xb4, 0x62, 0xcf, 0x4c, 0xda, 0x15, 0x37, 0x82, 0x7f, 0x01, 0x47, 0x25, 0x1f, 0x36,
+	0xe8, 0x67, 0xd0, 0x31, 0x99, 0xa9, 0xa0, 0x8d, 0x6a, 0x62, 0x72, 0x0d, 0xff, 0xef, 0x3a, 0x0c,
+	0x4a, 0x27, 0xec, 0x63, 0xe8, 0x3d, 0xe6, 0xab, 0x6b, 0x0b, 0x2d, 0x80, 0x9d, 0x3b, 0x32, 0x86,
+	0x6e, 0x1a, 0x08, 0xf1, 0x47, 0xc2, 0x23, 0x3d, 0x7a, 0x3d, 0xba, 0x96, 0x8b, 0x21, 0x33, 0x83,
+	0x67, 0x04, 0x42, 0xa0, 0x19, 0x26, 0x11, 0xda, 0x3b, 0xa0, 0x7f, 0xab, 0x4d, 0xe3, 0xce, 0x89,
+	0x30, 0xb3, 0xe7, 0x42, 0xba, 0x8b, 0x49, 0x84, 0x0f, 0xc8, 0xd9, 0x82, 0x21, 0xf7, 0x3a, 0xb6,
+	0x8b, 0x0e, 0xa6, 0x74, 0x44, 0x36, 0xff, 0x0d, 0x43, 0x69, 0x56, 0xa1, 0x6b, 0x74, 0x5c, 0x8c,
+	0x7c, 0x0a, 0x07, 0xae, 0xac, 0x0b, 0xee, 0x69, 0xbd, 0x2d, 0xbc, 0xb4, 0x0e, 0xb0, 0xb1, 0x0e,
+	0x37, 0x70, 0x44, 0x9d, 0x8b, 0x99, 0x13, 0xe9, 0xc3, 0xc0, 0x3d, 0xa4, 0x96, 0xcb, 0x12, 0x56,
+	0xd0, 0x52, 0x77, 0x68, 0xf1, 0xff, 0xaf, 0xc1, 0xb0, 0x7c, 0x45, 0xd4, 0xf9, 0x29, 0xae, 0xba,
+	0x75, 0xe5, 0x42, 0xaa, 0x6d, 0x72, 0x5d, 0x85, 0xf1, 0x56, 0x00, 0xea, 0x15, 0xff, 0x4c, 0x19,
+	0x47, 0x71, 0x6d, 0xce, 0x42, 0x83, 0x16, 0xc0, 0x56, 0xa6, 0xcd, 0x5d, 0x99, 0x96, 0xae, 0x84,
+	0x07, 0x9d, 0xfc, 0xd8, 0x98, 0x46, 0xe5, 0x22, 0x99, 0xc2, 0x3e, 0x13, 0x22, 0xc3, 0xa8, 0xe0,
+	0xd6, 0xf4, 0x69, 0x13, 0xf6, 0x7f, 0x81, 0x21, 0xc5, 0x97, 0xe4, 0x09, 0x77, 0x7f, 0x44, 0x3f,
+	0x81, 0xe1, 0xba, 0x9e, 0x9f, 0x59, 0x9c, 0x2f, 0x6f, 0x19, 0xf4, 0x0f, 0x60, 0x2f, 0x77, 0x66,
+	0xa8, 0x9b, 0xfd, 0xd3, 0x80, 0xc1, 0xcd, 0x0f, 0x99, 0x5c, 0xde, 0x22, 0x7f, 0x61, 0x21, 0x92,
+	0xef, 0x00, 0x8a, 0x3b, 0x4d, 0x4e, 0xf4, 0x6e, 0x6c, 0x7e, 0xc8, 0xc7, 0xa3, 0x4d, 0xd8, 0x36,
+	0xe2, 0x6b, 0x18, 0x5c, 0x72, 0x0c, 0xa4, 0x5d, 0x74, 0xe2, 0xac, 0x56, 0x6e, 0x4a, 0x5c, 0xa8,
+	0x30, 0xbb, 0x4f, 0xa3, 0x77, 0x36, 0xbb, 0x82, 0x61, 0xe9, 0xae, 0x10, 0x4f, 0x29, 0x55, 0x9d,
+	0xa7, 0xf1, 0x87, 0x15, 0x2f, 0xd6, 0xcb, 0x39, 0xf4, 0x9d, 0x33, 0x41, 0x74, 0x69, 0xdb, 0xb7,
+	0x67, 0xfc, 0xc1, 0x16, 0x6e, 0xed, 0x3f, 0x87, 0x96, 0xfd, 0x76, 0x28, 0x8d, 0x12, 0x4d, 0x87,
+	0x0e, 0x62, 0xb5, 0xbf, 0x85, 0x81, 0xbb, 0x0d, 0x44, 0xbb, 0xad, 0xd8, 0x8f, 0x2a, 0xdb, 0x2f,
+	0xa0, 0x6d, 0xba, 0x67, 0x08, 0x2a, 0x8d, 0xc5, 0x98, 0xb8, 0x90, 0x31, 0x98, 0xb7, 0xf5, 0xff,
+	0xb1, 0x2f, 0xdf, 0x0c, 0x00, 0x0c, 0xd5, 0xce, 0x6b, 0x9e, 0x09, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -293,6 +985,15 @@ const _ = grpc.SupportPackageIsVersion4
 type OAuthServiceClient interface {
 	// token 校验
 	CheckToken(ctx context.Context, in *CheckTokenRequest, opts ...grpc.CallOption) (*CheckTokenResponse, error)
+	// 客户端管理，metadata 的 authorization 中需携带具备 admin 范围的访问令牌
+	CreateClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error)
+	UpdateClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error)
+	DisableClient(ctx context.Context, in *DisableClientRequest, opts ...grpc.CallOption) (*DisableClientResponse, error)
+	ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error)
+	// 令牌签发、刷新与吊销，metadata 的 authorization 中需携带客户端的 Basic 认证信息，公开客户端只需携带 client_id
+	Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error)
 }
 
 type oAuthServiceClient struct {
@@ -312,10 +1013,82 @@ func (c *oAuthServiceClient) CheckToken(ctx context.Context, in *CheckTokenReque
 	return out, nil
 }
 
+func (c *oAuthServiceClient) CreateClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error) {
+	out := new(ClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/CreateClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) UpdateClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*ClientResponse, error) {
+	out := new(ClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/UpdateClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) DisableClient(ctx context.Context, in *DisableClientRequest, opts ...grpc.CallOption) (*DisableClientResponse, error) {
+	out := new(DisableClientResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/DisableClient", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error) {
+	out := new(ListClientsResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/ListClients", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/Token", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/RefreshToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oAuthServiceClient) Revoke(ctx context.Context, in *RevokeRequest, opts ...grpc.CallOption) (*RevokeResponse, error) {
+	out := new(RevokeResponse)
+	err := c.cc.Invoke(ctx, "/pb.OAuthService/Revoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OAuthServiceServer is the server API for OAuthService service.
 type OAuthServiceServer interface {
 	// token 校验
 	CheckToken(context.Context, *CheckTokenRequest) (*CheckTokenResponse, error)
+	// 客户端管理，metadata 的 authorization 中需携带具备 admin 范围的访问令牌
+	CreateClient(context.Context, *ClientRequest) (*ClientResponse, error)
+	UpdateClient(context.Context, *ClientRequest) (*ClientResponse, error)
+	DisableClient(context.Context, *DisableClientRequest) (*DisableClientResponse, error)
+	ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error)
+	// 令牌签发、刷新与吊销，metadata 的 authorization 中需携带客户端的 Basic 认证信息，公开客户端只需携带 client_id
+	Token(context.Context, *TokenRequest) (*TokenResponse, error)
+	RefreshToken(context.Context, *RefreshTokenRequest) (*TokenResponse, error)
+	Revoke(context.Context, *RevokeRequest) (*RevokeResponse, error)
 }
 
 // UnimplementedOAuthServiceServer can be embedded to have forward compatible implementations.
@@ -325,6 +1098,27 @@ type UnimplementedOAuthServiceServer struct {
 func (*UnimplementedOAuthServiceServer) CheckToken(ctx context.Context, req *CheckTokenRequest) (*CheckTokenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckToken not implemented")
 }
+func (*UnimplementedOAuthServiceServer) CreateClient(ctx context.Context, req *ClientRequest) (*ClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateClient not implemented")
+}
+func (*UnimplementedOAuthServiceServer) UpdateClient(ctx context.Context, req *ClientRequest) (*ClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateClient not implemented")
+}
+func (*UnimplementedOAuthServiceServer) DisableClient(ctx context.Context, req *DisableClientRequest) (*DisableClientResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableClient not implemented")
+}
+func (*UnimplementedOAuthServiceServer) ListClients(ctx context.Context, req *ListClientsRequest) (*ListClientsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClients not implemented")
+}
+func (*UnimplementedOAuthServiceServer) Token(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Token not implemented")
+}
+func (*UnimplementedOAuthServiceServer) RefreshToken(ctx context.Context, req *RefreshTokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (*UnimplementedOAuthServiceServer) Revoke(ctx context.Context, req *RevokeRequest) (*RevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
 
 func RegisterOAuthServiceServer(s *grpc.Server, srv OAuthServiceServer) {
 	s.RegisterService(&_OAuthService_serviceDesc, srv)
@@ -348,6 +1142,132 @@ func _OAuthService_CheckToken_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OAuthService_CreateClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).CreateClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/CreateClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).CreateClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_UpdateClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).UpdateClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/UpdateClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).UpdateClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_DisableClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).DisableClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/DisableClient",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).DisableClient(ctx, req.(*DisableClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_ListClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).ListClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/ListClients",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).ListClients(ctx, req.(*ListClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_Token_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).Token(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/Token",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).Token(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/RefreshToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).RefreshToken(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OAuthService_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OAuthServiceServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.OAuthService/Revoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OAuthServiceServer).Revoke(ctx, req.(*RevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _OAuthService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.OAuthService",
 	HandlerType: (*OAuthServiceServer)(nil),
@@ -356,6 +1276,34 @@ var _OAuthService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CheckToken",
 			Handler:    _OAuthService_CheckToken_Handler,
 		},
+		{
+			MethodName: "CreateClient",
+			Handler:    _OAuthService_CreateClient_Handler,
+		},
+		{
+			MethodName: "UpdateClient",
+			Handler:    _OAuthService_UpdateClient_Handler,
+		},
+		{
+			MethodName: "DisableClient",
+			Handler:    _OAuthService_DisableClient_Handler,
+		},
+		{
+			MethodName: "ListClients",
+			Handler:    _OAuthService_ListClients_Handler,
+		},
+		{
+			MethodName: "Token",
+			Handler:    _OAuthService_Token_Handler,
+		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _OAuthService_RefreshToken_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _OAuthService_Revoke_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "oauth.proto",
@@ -75,6 +75,7 @@ type UserResponse struct {
 	Result               bool     `protobuf:"varint,1,opt,name=result,proto3" json:"result,omitempty"`
 	UserId               int64    `protobuf:"varint,2,opt,name=userId,proto3" json:"userId,omitempty"`
 	Err                  string   `protobuf:"bytes,3,opt,name=err,proto3" json:"err,omitempty"`
+	Authorities          []string `protobuf:"bytes,4,rep,name=authorities,proto3" json:"authorities,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -126,6 +127,13 @@ func (m *UserResponse) GetErr() string {
 	return ""
 }
 
+func (m *UserResponse) GetAuthorities() []string {
+	if m != nil {
+		return m.Authorities
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*UserRequest)(nil), "pb.UserRequest")
 	proto.RegisterType((*UserResponse)(nil), "pb.UserResponse")
@@ -134,19 +142,20 @@ func init() {
 func init() { proto.RegisterFile("user.proto", fileDescriptor_116e343673f7ffaf) }
 
 var fileDescriptor_116e343673f7ffaf = []byte{
-	// 183 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2a, 0x2d, 0x4e, 0x2d,
-	0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2a, 0x48, 0x52, 0x72, 0xe5, 0xe2, 0x0e, 0x2d,
-	0x4e, 0x2d, 0x0a, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0x11, 0x92, 0xe2, 0xe2, 0x00, 0x29, 0xc8,
-	0x4b, 0xcc, 0x4d, 0x95, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x82, 0xf3, 0x41, 0x72, 0x05, 0x89,
-	0xc5, 0xc5, 0xe5, 0xf9, 0x45, 0x29, 0x12, 0x4c, 0x10, 0x39, 0x18, 0x5f, 0x29, 0x80, 0x8b, 0x07,
-	0x62, 0x4c, 0x71, 0x41, 0x7e, 0x5e, 0x71, 0xaa, 0x90, 0x18, 0x17, 0x5b, 0x51, 0x6a, 0x71, 0x69,
-	0x4e, 0x09, 0xd8, 0x14, 0x8e, 0x20, 0x28, 0x0f, 0x24, 0x0e, 0x32, 0xcf, 0x13, 0x62, 0x02, 0x73,
-	0x10, 0x94, 0x27, 0x24, 0xc0, 0xc5, 0x9c, 0x5a, 0x54, 0x24, 0xc1, 0x0c, 0x36, 0x16, 0xc4, 0x34,
-	0xb2, 0x86, 0x38, 0x2c, 0x38, 0xb5, 0xa8, 0x2c, 0x33, 0x39, 0x55, 0x48, 0x87, 0x8b, 0xd5, 0x39,
-	0x23, 0x35, 0x39, 0x5b, 0x88, 0x5f, 0xaf, 0x20, 0x49, 0x0f, 0xc9, 0xc9, 0x52, 0x02, 0x08, 0x01,
-	0x88, 0xe5, 0x4a, 0x0c, 0x49, 0x6c, 0x60, 0x0f, 0x1a, 0x03, 0x02, 0x00, 0x00, 0xff, 0xff, 0x4f,
-	0x02, 0x52, 0x34, 0xee, 0x00, 0x00, 0x00,
+	// 199 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x90, 0xb1, 0x4e, 0x86, 0x30,
+	0x14, 0x85, 0x85, 0x2a, 0x81, 0x8b, 0x89, 0xa4, 0x83, 0x69, 0x98, 0x08, 0x13, 0x83, 0x61, 0xd0,
+	0xd1, 0xd1, 0x38, 0xb8, 0xd6, 0xf8, 0x00, 0x80, 0x37, 0x81, 0xa8, 0xb4, 0xde, 0xdb, 0xea, 0xeb,
+	0x9b, 0x52, 0xfc, 0x7f, 0xb6, 0x7e, 0xe7, 0x34, 0x5f, 0x4e, 0x0b, 0xe0, 0x19, 0xa9, 0xb7, 0x64,
+	0x9c, 0x91, 0xa9, 0x1d, 0xdb, 0x67, 0x28, 0xdf, 0x18, 0x49, 0xe3, 0xb7, 0x47, 0x76, 0xb2, 0x86,
+	0x3c, 0x5c, 0x58, 0x87, 0x2f, 0x54, 0x49, 0x93, 0x74, 0x85, 0x3e, 0x71, 0xe8, 0xec, 0xc0, 0xfc,
+	0x6b, 0xe8, 0x5d, 0xa5, 0xb1, 0xfb, 0xe7, 0x96, 0xe0, 0x3a, 0x6a, 0xd8, 0x9a, 0x95, 0x51, 0xde,
+	0x42, 0x46, 0xc8, 0xfe, 0xd3, 0x6d, 0x96, 0x5c, 0xef, 0x14, 0xf2, 0xe0, 0x7b, 0x89, 0x06, 0xa1,
+	0x77, 0x92, 0x15, 0x08, 0x24, 0x52, 0x62, 0xd3, 0x86, 0xa3, 0x6c, 0xa0, 0x1c, 0xbc, 0x9b, 0x0d,
+	0x2d, 0x6e, 0x41, 0x56, 0x97, 0x8d, 0xe8, 0x0a, 0x7d, 0x8c, 0xee, 0x1f, 0xe3, 0xf4, 0x57, 0xa4,
+	0x9f, 0x65, 0x42, 0x79, 0x07, 0x57, 0x4f, 0x33, 0x4e, 0x1f, 0xf2, 0xa6, 0xb7, 0x63, 0x7f, 0x78,
+	0x54, 0x5d, 0x9d, 0x83, 0x38, 0xaf, 0xbd, 0x18, 0xb3, 0xed, 0x0b, 0x1e, 0xfe, 0x06, 0x00, 0xb0,
+	0x0b, 0x03, 0x2b, 0x10, 0x01, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
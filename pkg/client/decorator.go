@@ -50,12 +50,15 @@ func (manager *DefaultClientManager) DecoratorInvoke(path string, hystrixName st
 		instances := manager.discoverClient.DiscoverServices(manager.serviceName, manager.logger)
 		if instances, err := manager.loadbalance.SelectBalance(instances); err == nil {
 			if instances.GrpcPort > 0 {
-				if conn, err := grpc.Dial(instances.Host+":"+strconv.Itoa(instances.Port), grpc.WithInsecure(),
+				conn, err := grpc.Dial(instances.Host+":"+strconv.Itoa(instances.GrpcPort), grpc.WithInsecure(),
 					grpc.WithUnaryInterceptor(otgrpc.OpenTracingClientInterceptor(genTracer(tracer),
-						otgrpc.LogPayloads())), grpc.WithTimeout(1*time.Second)); err == nil {
-					if err = conn.Invoke(ctx, path, inputVal, outVal); err != nil {
-						return err
-					}
+						otgrpc.LogPayloads())), grpc.WithTimeout(1*time.Second))
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				if err = conn.Invoke(ctx, path, inputVal, outVal); err != nil {
+					return err
 				}
 			} else {
 				return ErrRPCService
@@ -5,22 +5,66 @@ import (
 	"SecondKill/pkg/discover"
 	"SecondKill/pkg/loadbalance"
 	"context"
+	"encoding/base64"
 	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc/metadata"
 )
 
 type OAuthClient interface {
 	CheckToken(ctx context.Context, tracer opentracing.Tracer, request *pb.CheckTokenRequest) (*pb.CheckTokenResponse, error)
+	// Token、RefreshToken 和 Revoke 需要先用 WithClientCredentials 携带客户端认证信息
+	Token(ctx context.Context, tracer opentracing.Tracer, request *pb.TokenRequest) (*pb.TokenResponse, error)
+	RefreshToken(ctx context.Context, tracer opentracing.Tracer, request *pb.RefreshTokenRequest) (*pb.TokenResponse, error)
+	Revoke(ctx context.Context, tracer opentracing.Tracer, request *pb.RevokeRequest) (*pb.RevokeResponse, error)
+}
+
+// WithClientCredentials 在 metadata 中携带客户端认证信息，公开客户端的 clientSecret 为空
+func WithClientCredentials(ctx context.Context, clientId string, clientSecret string) context.Context {
+	if clientSecret == "" {
+		return metadata.AppendToOutgoingContext(ctx, "client_id", clientId)
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(clientId + ":" + clientSecret))
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+credentials)
+}
+
+// WithClientIp 代用户调用密码模式等登录接口时携带终端用户 IP，认证服务只信任受信内部服务传递的 IP
+func WithClientIp(ctx context.Context, ip string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-forwarded-for", ip)
 }
 
 func (O *OAuthClientImpl) CheckToken(ctx context.Context, tracer opentracing.Tracer, request *pb.CheckTokenRequest) (*pb.CheckTokenResponse, error) {
 	response := new(pb.CheckTokenResponse)
-	if err := O.manager.DecoratorInvoke("pb.OAuthService/CheckToken", "token_check", tracer, ctx, request, response); err != nil {
+	if err := O.manager.DecoratorInvoke("/pb.OAuthService/CheckToken", "token_check", tracer, ctx, request, response); err != nil {
 		return nil, err
 	} else {
 		return response, nil
 	}
 }
 
+func (O *OAuthClientImpl) Token(ctx context.Context, tracer opentracing.Tracer, request *pb.TokenRequest) (*pb.TokenResponse, error) {
+	response := new(pb.TokenResponse)
+	if err := O.manager.DecoratorInvoke("/pb.OAuthService/Token", "token_grant", tracer, ctx, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (O *OAuthClientImpl) RefreshToken(ctx context.Context, tracer opentracing.Tracer, request *pb.RefreshTokenRequest) (*pb.TokenResponse, error) {
+	response := new(pb.TokenResponse)
+	if err := O.manager.DecoratorInvoke("/pb.OAuthService/RefreshToken", "token_refresh", tracer, ctx, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (O *OAuthClientImpl) Revoke(ctx context.Context, tracer opentracing.Tracer, request *pb.RevokeRequest) (*pb.RevokeResponse, error) {
+	response := new(pb.RevokeResponse)
+	if err := O.manager.DecoratorInvoke("/pb.OAuthService/Revoke", "token_revoke", tracer, ctx, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 type OAuthClientImpl struct {
 	manager     ClientManager
 	serviceName string
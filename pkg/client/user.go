@@ -21,7 +21,7 @@ type UserClientImpl struct {
 
 func (u *UserClientImpl) CheckUser(ctx context.Context, tracer opentracing.Tracer, requeset *pb.UserRequest) (*pb.UserResponse, error) {
 	response := new(pb.UserResponse)
-	if err := u.manager.DecoratorInvoke("pb.UserService/check", "user_check", tracer, ctx, requeset, response); err != nil {
+	if err := u.manager.DecoratorInvoke("/pb.UserService/Check", "user_check", tracer, ctx, requeset, response); err != nil {
 		return nil, err
 	} else {
 		return response, nil
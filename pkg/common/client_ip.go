@@ -0,0 +1,79 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIpResolver 只信任受信代理（网关、内部服务）转发的 X-Forwarded-For，
+// 直连的请求使用连接的来源地址，避免客户端伪造来源 IP 绕过按 IP 的限制
+type ClientIpResolver struct {
+	trustedProxies []*net.IPNet
+}
+
+// NewClientIpResolver trustedProxies 为 IP 或 CIDR，为空时不信任任何 X-Forwarded-For
+func NewClientIpResolver(trustedProxies []string) (*ClientIpResolver, error) {
+	resolver := &ClientIpResolver{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		resolver.trustedProxies = append(resolver.trustedProxies, network)
+	}
+	return resolver, nil
+}
+
+// IsTrusted 判断地址是否为受信代理
+func (resolver *ClientIpResolver) IsTrusted(address string) bool {
+	ip := net.ParseIP(hostOf(address))
+	if ip == nil {
+		return false
+	}
+	for _, network := range resolver.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIp 连接来自受信代理时，从右向左取 X-Forwarded-For 中第一个不是受信代理的地址
+func (resolver *ClientIpResolver) ClientIp(remoteAddr string, forwardedFor []string) string {
+	ip := hostOf(remoteAddr)
+	if !resolver.IsTrusted(ip) {
+		return ip
+	}
+	addresses := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(addresses[i])
+		if net.ParseIP(address) == nil {
+			break
+		}
+		ip = address
+		if !resolver.IsTrusted(address) {
+			break
+		}
+	}
+	return ip
+}
+
+// RequestClientIp 返回 http 请求的终端用户 IP
+func (resolver *ClientIpResolver) RequestClientIp(r *http.Request) string {
+	return resolver.ClientIp(r.RemoteAddr, r.Header["X-Forwarded-For"])
+}
+
+func hostOf(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,34 @@
+package common
+
+import "testing"
+
+func TestClientIpResolver(t *testing.T) {
+	resolver, err := NewClientIpResolver([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewClientIpResolver() err = %v", err)
+	}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "direct connection", remoteAddr: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "forged header from untrusted peer", remoteAddr: "203.0.113.5:1234", forwardedFor: []string{"1.1.1.1"}, want: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "client prepended a forged entry", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"1.1.1.1, 198.51.100.7"}, want: "198.51.100.7"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.7", "192.168.1.2"}, want: "198.51.100.7"},
+		{name: "malformed entry stops the walk", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.7, garbage, 192.168.1.2"}, want: "192.168.1.2"},
+		{name: "trusted proxy without header", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ip := resolver.ClientIp(tt.remoteAddr, tt.forwardedFor); ip != tt.want {
+				t.Fatalf("ClientIp() = %q, want %q", ip, tt.want)
+			}
+		})
+	}
+	if _, err = NewClientIpResolver([]string{"not-an-ip"}); err == nil {
+		t.Fatal("NewClientIpResolver() accepted an invalid proxy")
+	}
+}
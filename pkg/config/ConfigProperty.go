@@ -14,11 +14,18 @@ var (
 	MysqlConfig MysqlConf
 	TraceConfig TraceConf
 	Zk          ZookeeperConf
+	Proxy       ProxyConf
 )
 
+// 受信代理的 IP 或 CIDR，只有来自受信代理的请求才读取 X-Forwarded-For 中的客户端 IP
+type ProxyConf struct {
+	TrustedProxies []string
+}
+
 type ZookeeperConf struct {
 	ZkConn        *zk.Conn
-	SecProductKey string //商品键
+	SecProductKey string   //商品键
+	Hosts         []string //zookeeper地址
 }
 
 type EtcdConf struct {
@@ -72,8 +79,14 @@ type SecKillConf struct {
 	IPBlackMap map[string]bool
 	IDBlackMap map[int]bool
 
+	RWSecProductLock  sync.RWMutex
 	SecProductInfoMap map[int]*SecProductInfoConf
 
+	SecReqChan      chan *SecRequest //待写入redis的秒杀请求
+	SecReqChanSize  int
+	UserConnMap     map[string]chan *SecResult //等待秒杀结果的请求
+	UserConnMapLock sync.Mutex
+
 	AppWriteToHandleGoroutineNum  int
 	AppReadFromHandleGoroutineNum int
 
@@ -81,6 +94,9 @@ type SecKillConf struct {
 	CoreWriteRedisGoroutineNum int
 	CoreHandleGoroutineNum     int
 
+	Read2HandleChanSize  int
+	Handle2WriteChanSize int
+
 	AppWaitResultTimeout int
 
 	CoreWaitResultTimeout int
@@ -114,3 +130,49 @@ type AccessLimitConf struct {
 	IPMinAccessLimit   int //IP每分钟访问限制
 	UserMinAccessLimit int //用户每分钟访问限制
 }
+
+const (
+	SecResultSuccess      = 1000 //抢购成功
+	SecResultSoldOut      = 1001 //商品已售罄
+	SecResultRetry        = 1002 //抢购人数过多，请重试
+	SecResultAlreadyBuy   = 1003 //已超过单人购买限制
+	SecResultNotStart     = 1004 //活动未开始
+	SecResultAlreadyEnd   = 1005 //活动已结束
+	SecResultInvalidParam = 1006 //请求参数错误
+)
+
+//秒杀请求，sk-app写入Proxy2layerQueueName，由sk-core消费
+type SecRequest struct {
+	ProductId     int             `json:"product_id"` //商品ID
+	Source        string          `json:"source"`
+	AuthCode      string          `json:"auth_code"`
+	SecTime       int64           `json:"sec_time"`
+	Nance         string          `json:"nance"`
+	UserId        int             `json:"user_id"`
+	UserAuthSign  string          `json:"user_auth_sign"` //用户授权签名
+	AccessTime    int64           `json:"access_time"`
+	ClientAddr    string          `json:"client_addr"`
+	ClientRefence string          `json:"client_refence"`
+	RequestId     string          `json:"request_id"` //请求ID，sk-app按请求ID返回结果
+	CloseNotify   <-chan bool     `json:"-"`
+	ResultChan    chan *SecResult `json:"-"`
+}
+
+//秒杀结果，sk-core写入Layer2proxyQueueName，由sk-app返回给用户
+type SecResult struct {
+	ProductId int    `json:"product_id"` //商品ID
+	UserId    int    `json:"user_id"`    //用户ID
+	Token     string `json:"token"`      //购买凭证
+	TokenTime int64  `json:"token_time"` //凭证生成时间
+	Code      int    `json:"code"`       //状态码
+	RequestId string `json:"request_id"` //对应的请求ID
+}
+
+// ConnKey 同一用户对同一商品的并发请求各自等待自己的结果
+func (req *SecRequest) ConnKey() string {
+	return req.RequestId
+}
+
+func (res *SecResult) ConnKey() string {
+	return res.RequestId
+}
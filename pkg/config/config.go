@@ -67,6 +67,9 @@ func LoadRemoteConfig() (err error) {
 func Sub(key string, value interface{}) error {
 	Logger.Log("配置文件前缀为：", key)
 	sub := viper.Sub(key)
+	if sub == nil {
+		return fmt.Errorf("config key %s not found", key)
+	}
 	sub.AutomaticEnv()
 	sub.SetEnvPrefix(key)
 	return sub.Unmarshal(value)
@@ -0,0 +1,27 @@
+package config
+
+// InitSecKillConfig 加载sk-app与sk-core共用的远程配置，并按trace配置重新创建tracer
+func InitSecKillConfig() {
+	if err := LoadRemoteConfig(); err != nil {
+		Logger.Log("Fail to load remote config", err)
+	}
+
+	if err := Sub("redis", &Redis); err != nil {
+		Logger.Log("Fail to parse redis", err)
+	}
+	if err := Sub("zookeeper", &Zk); err != nil {
+		Logger.Log("Fail to parse zookeeper", err)
+	}
+	if err := Sub("service", &SecKill); err != nil {
+		Logger.Log("Fail to parse service", err)
+	}
+	if err := Sub("proxy", &Proxy); err != nil {
+		Logger.Log("Fail to parse proxy", err)
+	}
+	if err := Sub("trace", &TraceConfig); err != nil {
+		Logger.Log("Fail to parse trace", err)
+	}
+	zipkinUrl := "http://" + TraceConfig.Host + ":" + TraceConfig.Port + TraceConfig.Url
+	Logger.Log("zipkin url", zipkinUrl)
+	initTracer(zipkinUrl)
+}
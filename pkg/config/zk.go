@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/samuel/go-zookeeper/zk"
+	"log"
+	"time"
+)
+
+// InitZk 连接zookeeper并加载秒杀商品信息，商品变更时自动刷新
+func InitZk() {
+	if Zk.SecProductKey == "" {
+		Zk.SecProductKey = "/product"
+	}
+	conn, _, err := zk.Connect(Zk.Hosts, time.Second*5)
+	if err != nil {
+		log.Printf("connect zk err : %v", err)
+		return
+	}
+	Zk.ZkConn = conn
+	go watchSecProductKey(Zk.SecProductKey)
+}
+
+func watchSecProductKey(key string) {
+	for {
+		v, _, ch, err := Zk.ZkConn.GetW(key)
+		if err != nil {
+			log.Printf("watch zk key %s err : %v", key, err)
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		var secProductInfo []*SecProductInfoConf
+		if err := json.Unmarshal(v, &secProductInfo); err != nil {
+			log.Printf("unmarshal sec product info err : %v", err)
+		} else {
+			updateSecProductInfo(secProductInfo)
+		}
+		<-ch
+	}
+}
+
+func updateSecProductInfo(secProductInfo []*SecProductInfoConf) {
+	tmp := make(map[int]*SecProductInfoConf, len(secProductInfo))
+	for _, v := range secProductInfo {
+		tmp[v.ProductId] = v
+	}
+	SecKill.RWSecProductLock.Lock()
+	SecKill.SecProductInfoMap = tmp
+	SecKill.RWSecProductLock.Unlock()
+	log.Printf("load sec product info success, count : %d", len(tmp))
+}
@@ -102,6 +102,7 @@ func (p ActivityServiceImpl) syncToZk(activity *model.Activity) error {
 	secProductInfo.StartTime = activity.StartTime
 	secProductInfo.Status = activity.Status
 	secProductInfo.Total = activity.Total
+	secProductInfo.Left = activity.Total
 	secProductInfo.BuyRate = activity.BuyRate
 	secProductInfoList = append(secProductInfoList, secProductInfo)
 	data, err := json.Marshal(secProductInfoList)
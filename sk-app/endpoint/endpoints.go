@@ -0,0 +1,90 @@
+package endpoint
+
+import (
+	conf "SecondKill/pkg/config"
+	"SecondKill/sk-app/service"
+	"context"
+	"github.com/go-kit/kit/endpoint"
+)
+
+type SkAppEndpoints struct {
+	SecKillEndpoint     endpoint.Endpoint
+	SecInfoEndpoint     endpoint.Endpoint
+	SecInfoListEndpoint endpoint.Endpoint
+	HealthCheckEndpoint endpoint.Endpoint
+}
+
+type SecInfoRequest struct {
+	ProductId int
+}
+
+type SecResponse struct {
+	Result interface{} `json:"result"`
+	Code   int         `json:"code"`
+	Error  string      `json:"error"`
+}
+
+func MakeSecKillEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*conf.SecRequest)
+		result, code, err := svc.SecKill(req)
+		var errString = ""
+		if err != nil {
+			errString = err.Error()
+		}
+		return SecResponse{
+			Result: result,
+			Code:   code,
+			Error:  errString,
+		}, nil
+	}
+}
+
+func MakeSecInfoEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*SecInfoRequest)
+		result, code, err := svc.SecInfo(req.ProductId)
+		var errString = ""
+		if err != nil {
+			errString = err.Error()
+		}
+		return SecResponse{
+			Result: result,
+			Code:   code,
+			Error:  errString,
+		}, nil
+	}
+}
+
+func MakeSecInfoListEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		result, err := svc.SecInfoList()
+		var errString = ""
+		if err != nil {
+			errString = err.Error()
+		}
+		return SecResponse{
+			Result: result,
+			Code:   conf.SecResultSuccess,
+			Error:  errString,
+		}, nil
+	}
+}
+
+// HealthRequest 健康检查请求结构
+type HealthRequest struct{}
+
+// HealthResponse 健康检查响应结构
+type HealthResponse struct {
+	Status bool `json:"status"`
+}
+
+// MakeHealthCheckEndpoint 创建健康检查Endpoint
+func MakeHealthCheckEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		status := svc.HealthCheck()
+		return HealthResponse{
+			Status: status,
+		}, nil
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	conf "SecondKill/pkg/config"
+	"SecondKill/sk-app/setup"
+)
+
+func main() {
+	conf.InitSecKillConfig()
+	conf.InitZk()
+	setup.InitRedis()
+	setup.InitServer()
+}
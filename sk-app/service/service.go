@@ -0,0 +1,201 @@
+package service
+
+import (
+	conf "SecondKill/pkg/config"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	ProductStatusNormal  = 0 //商品状态正常
+	ProductStatusDisable = 1 //商品已禁用
+)
+
+var (
+	ErrNotFoundProduct     = errors.New("product not found")
+	ErrInvalidRequest      = errors.New("invalid request")
+	ErrUserCheckAuthFailed = errors.New("user auth check failed")
+	ErrUserServiceBusy     = errors.New("server busy, please retry")
+	ErrProcessTimeout      = errors.New("process timeout")
+	ErrClientClosed        = errors.New("client closed")
+	ErrSecKillFailed       = errors.New("sec kill failed")
+)
+
+type Service interface {
+	// HealthCheck check service health status
+	HealthCheck() bool
+	// 获取商品的秒杀信息
+	SecInfo(productId int) (map[string]interface{}, int, error)
+	// 获取全部商品的秒杀信息
+	SecInfoList() ([]map[string]interface{}, error)
+	// 提交秒杀请求，等待sk-core的处理结果
+	SecKill(req *conf.SecRequest) (map[string]interface{}, int, error)
+}
+
+type SkAppService struct {
+}
+
+func NewSkAppService() Service {
+	return &SkAppService{}
+}
+
+func (s *SkAppService) HealthCheck() bool {
+	return true
+}
+
+func (s *SkAppService) SecInfo(productId int) (map[string]interface{}, int, error) {
+	conf.SecKill.RWSecProductLock.RLock()
+	defer conf.SecKill.RWSecProductLock.RUnlock()
+
+	product, ok := conf.SecKill.SecProductInfoMap[productId]
+	if !ok {
+		return nil, conf.SecResultInvalidParam, ErrNotFoundProduct
+	}
+	return secInfo(product)
+}
+
+func (s *SkAppService) SecInfoList() ([]map[string]interface{}, error) {
+	conf.SecKill.RWSecProductLock.RLock()
+	defer conf.SecKill.RWSecProductLock.RUnlock()
+
+	var data []map[string]interface{}
+	for _, product := range conf.SecKill.SecProductInfoMap {
+		item, _, _ := secInfo(product)
+		data = append(data, item)
+	}
+	return data, nil
+}
+
+// secInfo 根据活动时间、状态和剩余库存判断商品是否可以秒杀
+func secInfo(product *conf.SecProductInfoConf) (map[string]interface{}, int, error) {
+	code := conf.SecResultSuccess
+	now := time.Now().Unix()
+	switch {
+	case now < product.StartTime:
+		code = conf.SecResultNotStart
+	case now > product.EndTime || product.Status != ProductStatusNormal:
+		code = conf.SecResultAlreadyEnd
+	case product.Left <= 0:
+		code = conf.SecResultSoldOut
+	}
+	return map[string]interface{}{
+		"product_id": product.ProductId,
+		"start_time": product.StartTime,
+		"end_time":   product.EndTime,
+		"status":     product.Status,
+		"total":      product.Total,
+		"left":       product.Left,
+		"code":       code,
+	}, code, nil
+}
+
+func (s *SkAppService) SecKill(req *conf.SecRequest) (map[string]interface{}, int, error) {
+	if err := userCheck(req); err != nil {
+		return nil, conf.SecResultInvalidParam, err
+	}
+	data, code, err := s.SecInfo(req.ProductId)
+	if err != nil || code != conf.SecResultSuccess {
+		return data, code, err
+	}
+
+	requestId, err := newRequestId()
+	if err != nil {
+		return nil, conf.SecResultRetry, ErrUserServiceBusy
+	}
+	req.RequestId = requestId
+	req.ResultChan = make(chan *conf.SecResult, 1)
+	key := req.ConnKey()
+	conf.SecKill.UserConnMapLock.Lock()
+	conf.SecKill.UserConnMap[key] = req.ResultChan
+	conf.SecKill.UserConnMapLock.Unlock()
+	defer func() {
+		conf.SecKill.UserConnMapLock.Lock()
+		delete(conf.SecKill.UserConnMap, key)
+		conf.SecKill.UserConnMapLock.Unlock()
+	}()
+
+	sendTimer := time.NewTimer(time.Duration(conf.SecKill.SendToHandleChanTimeout) * time.Millisecond)
+	defer sendTimer.Stop()
+	select {
+	case conf.SecKill.SecReqChan <- req:
+	case <-sendTimer.C:
+		return nil, conf.SecResultRetry, ErrUserServiceBusy
+	}
+
+	waitTimer := time.NewTimer(time.Duration(conf.SecKill.AppWaitResultTimeout) * time.Second)
+	defer waitTimer.Stop()
+	select {
+	case <-waitTimer.C:
+		return nil, conf.SecResultRetry, ErrProcessTimeout
+	case <-req.CloseNotify:
+		return nil, conf.SecResultRetry, ErrClientClosed
+	case result := <-req.ResultChan:
+		if result.Code == conf.SecResultSoldOut {
+			markSoldOut(result.ProductId)
+		}
+		if result.Code != conf.SecResultSuccess {
+			return nil, result.Code, ErrSecKillFailed
+		}
+		return map[string]interface{}{
+			"product_id": result.ProductId,
+			"token":      result.Token,
+			"user_id":    result.UserId,
+		}, result.Code, nil
+	}
+}
+
+// userCheck 校验用户签名、来源以及黑名单
+func userCheck(req *conf.SecRequest) error {
+	if req.UserId <= 0 || req.ProductId <= 0 {
+		return ErrInvalidRequest
+	}
+	if conf.SecKill.CookieSecretKey != "" {
+		authData := fmt.Sprintf("%d:%s", req.UserId, conf.SecKill.CookieSecretKey)
+		authSign := fmt.Sprintf("%x", md5.Sum([]byte(authData)))
+		if authSign != req.UserAuthSign {
+			return ErrUserCheckAuthFailed
+		}
+	}
+	if len(conf.SecKill.ReferWhiteList) > 0 {
+		found := false
+		for _, refer := range conf.SecKill.ReferWhiteList {
+			if refer == req.ClientRefence {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Printf("user %d is reject by refer : %s", req.UserId, req.ClientRefence)
+			return ErrUserCheckAuthFailed
+		}
+	}
+
+	conf.SecKill.RWBlackLock.RLock()
+	defer conf.SecKill.RWBlackLock.RUnlock()
+	if conf.SecKill.IDBlackMap[req.UserId] || conf.SecKill.IPBlackMap[req.ClientAddr] {
+		return ErrUserServiceBusy
+	}
+	return nil
+}
+
+func markSoldOut(productId int) {
+	conf.SecKill.RWSecProductLock.Lock()
+	defer conf.SecKill.RWSecProductLock.Unlock()
+	if product, ok := conf.SecKill.SecProductInfoMap[productId]; ok {
+		product.Left = 0
+	}
+}
+
+// newRequestId 为每个秒杀请求生成随机ID，用于匹配sk-core返回的结果
+func newRequestId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
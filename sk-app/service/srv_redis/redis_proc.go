@@ -0,0 +1,64 @@
+package srv_redis
+
+import (
+	conf "SecondKill/pkg/config"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// RunProcess 启动写入Proxy2layer队列和读取Layer2proxy队列的协程
+func RunProcess() {
+	for i := 0; i < conf.SecKill.AppWriteToHandleGoroutineNum; i++ {
+		go WriteHandle()
+	}
+	for i := 0; i < conf.SecKill.AppReadFromHandleGoroutineNum; i++ {
+		go ReadHandle()
+	}
+}
+
+// WriteHandle 将秒杀请求写入redis，交由sk-core处理
+func WriteHandle() {
+	for req := range conf.SecKill.SecReqChan {
+		data, err := json.Marshal(req)
+		if err != nil {
+			log.Printf("json.Marshal req failed. Error : %v, req : %v", err, req)
+			continue
+		}
+		err = conf.Redis.RedisConn.LPush(conf.Redis.Proxy2layerQueueName, string(data)).Err()
+		if err != nil {
+			log.Printf("lpush req failed. Error : %v, req : %v", err, req)
+		}
+	}
+}
+
+// ReadHandle 读取sk-core的处理结果并转交给等待中的请求
+func ReadHandle() {
+	for {
+		data, err := conf.Redis.RedisConn.BRPop(time.Second, conf.Redis.Layer2proxyQueueName).Result()
+		if err != nil {
+			continue
+		}
+		var result conf.SecResult
+		if err = json.Unmarshal([]byte(data[1]), &result); err != nil {
+			log.Printf("json.Unmarshal result failed. Error : %v, data : %v", err, data[1])
+			continue
+		}
+
+		conf.SecKill.UserConnMapLock.Lock()
+		resultChan, ok := conf.SecKill.UserConnMap[result.ConnKey()]
+		conf.SecKill.UserConnMapLock.Unlock()
+		if !ok {
+			log.Printf("user not found : %v", result.ConnKey())
+			continue
+		}
+
+		timer := time.NewTimer(time.Duration(conf.SecKill.SendToWriteChanTimeout) * time.Millisecond)
+		select {
+		case resultChan <- &result:
+		case <-timer.C:
+			log.Printf("send result to user timeout : %v", result.ConnKey())
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,74 @@
+package setup
+
+import (
+	"SecondKill/pkg/bootstrap"
+	"SecondKill/pkg/common"
+	conf "SecondKill/pkg/config"
+	register "SecondKill/pkg/discover"
+	"SecondKill/sk-app/endpoint"
+	"SecondKill/sk-app/service"
+	"SecondKill/sk-app/transport"
+	"context"
+	"flag"
+	"fmt"
+	kitzipkin "github.com/go-kit/kit/tracing/zipkin"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// InitServer 创建sk-app的endpoint与http服务，并注册到consul
+func InitServer() {
+	var (
+		servicePort = flag.String("service.port", bootstrap.HttpConfig.Port, "service port")
+	)
+	flag.Parse()
+
+	srv := service.NewSkAppService()
+
+	secKillEndpoint := endpoint.MakeSecKillEndpoint(srv)
+	secKillEndpoint = kitzipkin.TraceEndpoint(conf.ZipkinTracer, "sec-kill")(secKillEndpoint)
+
+	secInfoEndpoint := endpoint.MakeSecInfoEndpoint(srv)
+	secInfoEndpoint = kitzipkin.TraceEndpoint(conf.ZipkinTracer, "sec-info")(secInfoEndpoint)
+
+	secInfoListEndpoint := endpoint.MakeSecInfoListEndpoint(srv)
+	secInfoListEndpoint = kitzipkin.TraceEndpoint(conf.ZipkinTracer, "sec-info-list")(secInfoListEndpoint)
+
+	//创建健康检查的Endpoint
+	healthEndpoint := endpoint.MakeHealthCheckEndpoint(srv)
+	healthEndpoint = kitzipkin.TraceEndpoint(conf.ZipkinTracer, "health-endpoint")(healthEndpoint)
+
+	endpts := endpoint.SkAppEndpoints{
+		SecKillEndpoint:     secKillEndpoint,
+		SecInfoEndpoint:     secInfoEndpoint,
+		SecInfoListEndpoint: secInfoListEndpoint,
+		HealthCheckEndpoint: healthEndpoint,
+	}
+	ctx := context.Background()
+	// 受信代理配置有误时拒绝启动，避免错误地信任或忽略 X-Forwarded-For
+	clientIpResolver, err := common.NewClientIpResolver(conf.Proxy.TrustedProxies)
+	if err != nil {
+		conf.Logger.Log("Fail to parse trusted proxies", err)
+		os.Exit(1)
+	}
+	r := transport.MakeHttpHandler(ctx, endpts, clientIpResolver, conf.ZipkinTracer, conf.Logger)
+
+	errChan := make(chan error)
+	go func() {
+		fmt.Println("http server start at port:" + *servicePort)
+		register.Register()
+		errChan <- http.ListenAndServe(":"+*servicePort, r)
+	}()
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		errChan <- fmt.Errorf("%s", <-c)
+	}()
+	err = <-errChan
+	//服务退出取消注册
+	register.DeRegister()
+	fmt.Println(err)
+}
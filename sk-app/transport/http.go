@@ -0,0 +1,139 @@
+package transport
+
+import (
+	"SecondKill/pkg/common"
+	conf "SecondKill/pkg/config"
+	"SecondKill/sk-app/endpoint"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/zipkin"
+	"github.com/go-kit/kit/transport"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	gozipkin "github.com/openzipkin/zipkin-go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrorBadRequest = errors.New("invalid request parameter")
+)
+
+func MakeHttpHandler(
+	ctx context.Context,
+	endpoints endpoint.SkAppEndpoints,
+	clientIpResolver *common.ClientIpResolver,
+	zipkinTracer *gozipkin.Tracer, logger log.Logger) http.Handler {
+	r := mux.NewRouter()
+	zipkinServer := zipkin.HTTPServerTrace(zipkinTracer, zipkin.Name("http-transport"))
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		kithttp.ServerErrorEncoder(encodeError),
+		zipkinServer,
+	}
+	r.Path("/metrics").Handler(promhttp.Handler())
+	r.Methods("POST").Path("/sec/kill").Handler(kithttp.NewServer(
+		endpoints.SecKillEndpoint,
+		makeDecodeSecKillRequest(clientIpResolver),
+		encodeJsonResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/sec/info").Handler(kithttp.NewServer(
+		endpoints.SecInfoEndpoint,
+		decodeSecInfoRequest,
+		encodeJsonResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/sec/list").Handler(kithttp.NewServer(
+		endpoints.SecInfoListEndpoint,
+		decodeSecInfoListRequest,
+		encodeJsonResponse,
+		options...,
+	))
+	// create health check handler
+	r.Methods("GET").Path("/health").Handler(kithttp.NewServer(
+		endpoints.HealthCheckEndpoint,
+		decodeHealthCheckRequest,
+		encodeJsonResponse,
+		options...,
+	))
+	return r
+}
+
+// makeDecodeSecKillRequest 只有来自受信代理的请求才读取 X-Forwarded-For 中的客户端 IP，避免伪造 IP 绕过黑名单
+func makeDecodeSecKillRequest(clientIpResolver *common.ClientIpResolver) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return decodeSecKillRequest(r, clientIpResolver.RequestClientIp(r))
+	}
+}
+
+func decodeSecKillRequest(r *http.Request, clientAddr string) (interface{}, error) {
+	productId, err := strconv.Atoi(r.FormValue("product_id"))
+	if err != nil {
+		return nil, ErrorBadRequest
+	}
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		return nil, ErrorBadRequest
+	}
+	secTime, _ := strconv.ParseInt(r.FormValue("time"), 10, 64)
+	closeNotify := make(chan bool, 1)
+	go func() {
+		<-r.Context().Done()
+		closeNotify <- true
+	}()
+	return &conf.SecRequest{
+		ProductId:     productId,
+		Source:        r.FormValue("src"),
+		AuthCode:      r.FormValue("auth_code"),
+		SecTime:       secTime,
+		Nance:         r.FormValue("nance"),
+		UserId:        userId,
+		UserAuthSign:  r.FormValue("user_auth_sign"),
+		AccessTime:    time.Now().Unix(),
+		ClientAddr:    clientAddr,
+		ClientRefence: r.Referer(),
+		CloseNotify:   closeNotify,
+	}, nil
+}
+
+func decodeSecInfoRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	productId, err := strconv.Atoi(r.URL.Query().Get("product_id"))
+	if err != nil {
+		return nil, ErrorBadRequest
+	}
+	return &endpoint.SecInfoRequest{
+		ProductId: productId,
+	}, nil
+}
+
+func decodeSecInfoListRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+func decodeHealthCheckRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return &endpoint.HealthRequest{}, nil
+}
+
+func encodeJsonResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	case ErrorBadRequest:
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	conf "SecondKill/pkg/config"
+	"SecondKill/sk-core/setup"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	conf.InitSecKillConfig()
+	conf.InitZk()
+	setup.InitRedis()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	fmt.Println(<-c)
+}
@@ -0,0 +1,181 @@
+package srv_redis
+
+import (
+	conf "SecondKill/pkg/config"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	ProductStatusNormal = 0
+
+	// 商品剩余库存前缀，按商品ID和活动开始时间区分，重新创建的活动使用新的库存
+	secProductLeftKeyPrefix = "sec_product_left:"
+	// 用户购买记录前缀，按商品ID和活动开始时间区分，field为用户ID
+	secUserHistoryKeyPrefix = "sec_user_history:"
+	// 商品每秒售出数量前缀，按商品ID和秒区分，所有sk-core实例共享
+	secProductSoldKeyPrefix = "sec_product_sold:"
+	// 活动结束后库存和购买记录再保留一天，便于对账
+	secActivityKeyRetention = 24 * 3600
+)
+
+// secKillScript 原子地校验库存、单人购买限制、每秒售出上限并扣减库存
+var secKillScript = fmt.Sprintf(`
+redis.call('SET', KEYS[1], ARGV[3], 'NX', 'EX', ARGV[4])
+local left = tonumber(redis.call('GET', KEYS[1]))
+if left <= 0 then
+	return %d
+end
+local limit = tonumber(ARGV[2])
+if limit > 0 then
+	local bought = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+	if bought >= limit then
+		return %d
+	end
+end
+local soldMaxLimit = tonumber(ARGV[5])
+if soldMaxLimit > 0 then
+	local sold = tonumber(redis.call('GET', KEYS[3]) or '0')
+	if sold >= soldMaxLimit then
+		return %d
+	end
+	redis.call('INCR', KEYS[3])
+	redis.call('EXPIRE', KEYS[3], 2)
+end
+redis.call('DECR', KEYS[1])
+redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return %d
+`, conf.SecResultSoldOut, conf.SecResultAlreadyBuy, conf.SecResultRetry, conf.SecResultSuccess)
+
+var (
+	read2HandleChan  chan *conf.SecRequest
+	handle2WriteChan chan *conf.SecResult
+)
+
+// RunProcess 按配置启动读取、处理和写回三组协程
+func RunProcess() {
+	read2HandleChan = make(chan *conf.SecRequest, conf.SecKill.Read2HandleChanSize)
+	handle2WriteChan = make(chan *conf.SecResult, conf.SecKill.Handle2WriteChanSize)
+	for i := 0; i < conf.SecKill.CoreReadRedisGoroutineNum; i++ {
+		go HandleReader()
+	}
+	for i := 0; i < conf.SecKill.CoreHandleGoroutineNum; i++ {
+		go HandleUser()
+	}
+	for i := 0; i < conf.SecKill.CoreWriteRedisGoroutineNum; i++ {
+		go HandleWrite()
+	}
+}
+
+// HandleReader 从Proxy2layer队列读取sk-app写入的秒杀请求
+func HandleReader() {
+	for {
+		data, err := conf.Redis.RedisConn.BRPop(time.Second, conf.Redis.Proxy2layerQueueName).Result()
+		if err != nil {
+			continue
+		}
+		var req conf.SecRequest
+		if err = json.Unmarshal([]byte(data[1]), &req); err != nil {
+			log.Printf("json.Unmarshal req failed. Error : %v, data : %v", err, data[1])
+			continue
+		}
+		// sk-app已经放弃等待的请求不再处理
+		if time.Now().Unix()-req.AccessTime >= int64(conf.SecKill.MaxRequestWaitTimeout) {
+			log.Printf("req[%v] is expire", req)
+			continue
+		}
+
+		timer := time.NewTimer(time.Duration(conf.SecKill.CoreWaitResultTimeout) * time.Millisecond)
+		select {
+		case read2HandleChan <- &req:
+		case <-timer.C:
+			log.Printf("send to handle chan timeout, req : %v", req)
+		}
+		timer.Stop()
+	}
+}
+
+// HandleUser 处理秒杀请求并生成结果
+func HandleUser() {
+	for req := range read2HandleChan {
+		res := HandleSecKill(req)
+		timer := time.NewTimer(time.Duration(conf.SecKill.SendToWriteChanTimeout) * time.Millisecond)
+		select {
+		case handle2WriteChan <- res:
+		case <-timer.C:
+			log.Printf("send to write chan timeout, res : %v", res)
+		}
+		timer.Stop()
+	}
+}
+
+// HandleWrite 将秒杀结果写入Layer2proxy队列
+func HandleWrite() {
+	for res := range handle2WriteChan {
+		data, err := json.Marshal(res)
+		if err != nil {
+			log.Printf("json.Marshal res failed. Error : %v, res : %v", err, res)
+			continue
+		}
+		err = conf.Redis.RedisConn.LPush(conf.Redis.Layer2proxyQueueName, string(data)).Err()
+		if err != nil {
+			log.Printf("lpush res failed. Error : %v, res : %v", err, res)
+		}
+	}
+}
+
+// HandleSecKill 校验活动状态与售出速度，并在redis中扣减库存
+func HandleSecKill(req *conf.SecRequest) *conf.SecResult {
+	res := &conf.SecResult{
+		ProductId: req.ProductId,
+		UserId:    req.UserId,
+		RequestId: req.RequestId,
+	}
+
+	conf.SecKill.RWSecProductLock.RLock()
+	product, ok := conf.SecKill.SecProductInfoMap[req.ProductId]
+	conf.SecKill.RWSecProductLock.RUnlock()
+	if !ok {
+		res.Code = conf.SecResultInvalidParam
+		return res
+	}
+
+	now := time.Now().Unix()
+	switch {
+	case now < product.StartTime:
+		res.Code = conf.SecResultNotStart
+		return res
+	case now > product.EndTime || product.Status != ProductStatusNormal:
+		res.Code = conf.SecResultAlreadyEnd
+		return res
+	}
+
+	activity := fmt.Sprintf("%d:%d", product.ProductId, product.StartTime)
+	code, err := conf.Redis.RedisConn.Eval(secKillScript,
+		[]string{
+			secProductLeftKeyPrefix + activity,
+			secUserHistoryKeyPrefix + activity,
+			fmt.Sprintf("%s%d:%d", secProductSoldKeyPrefix, product.ProductId, now),
+		},
+		req.UserId, product.OnePersonBuyLimit, product.Left,
+		product.EndTime-now+secActivityKeyRetention, product.SoldMaxLimit).Int()
+	if err != nil {
+		log.Printf("sec kill script failed. Error : %v, req : %v", err, req)
+		res.Code = conf.SecResultRetry
+		return res
+	}
+	res.Code = code
+	if code != conf.SecResultSuccess {
+		return res
+	}
+
+	res.TokenTime = now
+	tokenData := fmt.Sprintf("userId=%d&productId=%d&timestamp=%d&security=%s",
+		req.UserId, req.ProductId, now, conf.SecKill.TokenPassWd)
+	res.Token = fmt.Sprintf("%x", md5.Sum([]byte(tokenData)))
+	return res
+}
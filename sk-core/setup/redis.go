@@ -0,0 +1,23 @@
+package setup
+
+import (
+	conf "SecondKill/pkg/config"
+	"SecondKill/sk-core/service/srv_redis"
+	"github.com/go-redis/redis"
+	"log"
+)
+
+// InitRedis 连接redis并启动秒杀请求的处理协程
+func InitRedis() {
+	client := redis.NewClient(&redis.Options{
+		Addr:     conf.Redis.Host,
+		Password: conf.Redis.Password,
+		DB:       conf.Redis.Db,
+	})
+	// 没有redis无法处理秒杀请求，连接失败时退出
+	if _, err := client.Ping().Result(); err != nil {
+		log.Fatalf("connect redis err : %v", err)
+	}
+	conf.Redis.RedisConn = client
+	srv_redis.RunProcess()
+}
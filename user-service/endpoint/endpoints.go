@@ -0,0 +1,64 @@
+package endpoint
+
+import (
+	"SecondKill/user-service/service"
+	"context"
+	"github.com/go-kit/kit/endpoint"
+)
+
+type UserEndpoints struct {
+	UserEndpoint        endpoint.Endpoint
+	HealthCheckEndpoint endpoint.Endpoint
+}
+
+type UserRequest struct {
+	Username string
+	Password string
+}
+
+type UserResponse struct {
+	Result      bool     `json:"result"`
+	UserId      int64    `json:"user_id"`
+	Authorities []string `json:"authorities"`
+	Error       string   `json:"error"`
+}
+
+// MakeUserEndpoint 校验用户名与密码，用户名或密码错误时在响应中返回错误信息
+func MakeUserEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(*UserRequest)
+		user, err := svc.Check(ctx, req.Username, req.Password)
+		if err == service.ErrInvalidUser {
+			return UserResponse{
+				Result: false,
+				Error:  err.Error(),
+			}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return UserResponse{
+			Result:      true,
+			UserId:      user.UserId,
+			Authorities: user.Authorities,
+		}, nil
+	}
+}
+
+// HealthRequest 健康检查请求结构
+type HealthRequest struct{}
+
+// HealthResponse 健康检查响应结构
+type HealthResponse struct {
+	Status bool `json:"status"`
+}
+
+// MakeHealthCheckEndpoint 创建健康检查Endpoint
+func MakeHealthCheckEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		status := svc.HealthCheck()
+		return HealthResponse{
+			Status: status,
+		}, nil
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"SecondKill/pb"
+	"SecondKill/pkg/bootstrap"
+	"SecondKill/pkg/config"
+	register "SecondKill/pkg/discover"
+	"SecondKill/pkg/mysql"
+	localconfig "SecondKill/user-service/config"
+	"SecondKill/user-service/endpoint"
+	"SecondKill/user-service/service"
+	"SecondKill/user-service/transport"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	kitzipkin "github.com/go-kit/kit/tracing/zipkin"
+	"google.golang.org/grpc"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	var (
+		servicePort = flag.String("service.port", bootstrap.HttpConfig.Port, "service port")
+		grpcAddr    = flag.String("grpc", bootstrap.RpcConfig.Port, "gRPC listen address.")
+		// 创建用户后退出，密码从标准输入读取，避免出现在命令行参数中
+		createUser  = flag.String("create-user", "", "create a user with this username, read the password from stdin and exit")
+		authorities = flag.String("authorities", "", "comma separated authorities of the created user")
+		// 将历史数据中的明文密码转换为 bcrypt 哈希后退出
+		migratePasswords = flag.Bool("migrate-passwords", false, "hash all plaintext passwords and exit")
+	)
+	flag.Parse()
+
+	mysql.InitMysql(config.MysqlConfig.Host, config.MysqlConfig.Port, config.MysqlConfig.User,
+		config.MysqlConfig.Pwd, config.MysqlConfig.Db)
+
+	srv := service.NewUserService()
+	if *createUser != "" {
+		provisionUser(srv, *createUser, *authorities)
+		return
+	}
+	if *migratePasswords {
+		migrated, err := srv.MigratePasswords()
+		if err != nil {
+			localconfig.Logger.Log("Fail to migrate passwords", err, "migrated", migrated)
+			os.Exit(1)
+		}
+		localconfig.Logger.Log("migrated passwords", migrated)
+		return
+	}
+
+	userEndpoint := endpoint.MakeUserEndpoint(srv)
+	userEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "user-endpoint")(userEndpoint)
+
+	//创建健康检查的Endpoint
+	healthEndpoint := endpoint.MakeHealthCheckEndpoint(srv)
+	healthEndpoint = kitzipkin.TraceEndpoint(localconfig.ZipkinTracer, "health-endpoint")(healthEndpoint)
+
+	endpts := endpoint.UserEndpoints{
+		UserEndpoint:        userEndpoint,
+		HealthCheckEndpoint: healthEndpoint,
+	}
+	ctx := context.Background()
+	errChan := make(chan error)
+	r := transport.MakeHttpHandler(ctx, endpts, localconfig.ZipkinTracer, localconfig.Logger)
+
+	// http server
+	go func() {
+		fmt.Println("http server start at port:" + *servicePort)
+		register.Register()
+		errChan <- http.ListenAndServe(":"+*servicePort, r)
+	}()
+
+	// grpc
+	go func() {
+		fmt.Println("grpc Server start at port:" + *grpcAddr)
+		listener, err := net.Listen("tcp", ":"+*grpcAddr)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		serverTracer := kitzipkin.GRPCServerTrace(localconfig.ZipkinTracer, kitzipkin.Name("grpc-transport"))
+		handler := transport.NewGRPCServer(ctx, endpts, serverTracer)
+		gRPCServer := grpc.NewServer()
+		pb.RegisterUserServiceServer(gRPCServer, handler)
+		errChan <- gRPCServer.Serve(listener)
+	}()
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		errChan <- fmt.Errorf("%s", <-c)
+	}()
+	err := <-errChan
+	//服务退出取消注册
+	register.DeRegister()
+	fmt.Println(err)
+}
+
+// provisionUser 从标准输入读取密码并创建用户
+func provisionUser(srv *service.UserService, username string, authorities string) {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		localconfig.Logger.Log("Fail to read password", err)
+		os.Exit(1)
+	}
+	var authorityList []string
+	for _, authority := range strings.Split(authorities, ",") {
+		if authority = strings.TrimSpace(authority); authority != "" {
+			authorityList = append(authorityList, authority)
+		}
+	}
+	if err = srv.CreateUser(username, strings.TrimRight(password, "\r\n"), authorityList); err != nil {
+		localconfig.Logger.Log("Fail to create user", err)
+		os.Exit(1)
+	}
+	localconfig.Logger.Log("created user", username)
+}
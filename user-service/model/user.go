@@ -0,0 +1,142 @@
+package model
+
+import (
+	"SecondKill/pkg/mysql"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/gohouse/gorose/v2"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"strings"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
+
+type User struct {
+	UserId   int64
+	Username string
+	// 密码的 bcrypt 哈希
+	Password string
+	// 具备权限
+	Authorities []string
+}
+
+// IsPasswordMatch 哈希密码使用 bcrypt 校验，历史数据中的明文密码使用常量时间比较
+func (user *User) IsPasswordMatch(password string) bool {
+	if user.Password == "" || password == "" {
+		return false
+	}
+	if user.IsPasswordHashed() {
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+}
+
+// IsPasswordHashed 判断密码是否已经以 bcrypt 哈希存储
+func (user *User) IsPasswordHashed() bool {
+	return strings.HasPrefix(user.Password, "$2a$") ||
+		strings.HasPrefix(user.Password, "$2b$") ||
+		strings.HasPrefix(user.Password, "$2y$")
+}
+
+// HashPassword 使用 bcrypt 计算密码的哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+type UserModel struct {
+}
+
+func NewUserModel() *UserModel {
+	return &UserModel{}
+}
+
+func (p *UserModel) getTableName() string {
+	return "user"
+}
+
+func (p *UserModel) GetUserByUsername(username string) (*User, error) {
+	conn := mysql.DB()
+	result, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
+		"username": username,
+	}).First()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return toUser(result), nil
+}
+
+func toUser(result gorose.Data) *User {
+	var authorities []string
+	if authoritiesString, ok := result["authorities"].(string); ok {
+		_ = json.Unmarshal([]byte(authoritiesString), &authorities)
+	}
+	return &User{
+		UserId:      result["user_id"].(int64),
+		Username:    result["username"].(string),
+		Password:    result["password"].(string),
+		Authorities: authorities,
+	}
+}
+
+// CreateUser 保存用户，密码先转换为 bcrypt 哈希
+func (p *UserModel) CreateUser(user *User) error {
+	passwordHash, err := HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = passwordHash
+	conn := mysql.DB()
+	authoritiesString, _ := json.Marshal(user.Authorities)
+	_, err = conn.Table(p.getTableName()).Data(map[string]interface{}{
+		"username":    user.Username,
+		"password":    user.Password,
+		"authorities": string(authoritiesString),
+	}).Insert()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdatePassword 更新用户的密码哈希
+func (p *UserModel) UpdatePassword(userId int64, passwordHash string) error {
+	conn := mysql.DB()
+	_, err := conn.Table(p.getTableName()).Where(map[string]interface{}{
+		"user_id": userId,
+	}).Data(map[string]interface{}{
+		"password": passwordHash,
+	}).Update()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListUsers 按 user_id 分页读取用户
+func (p *UserModel) ListUsers(offset int, limit int) ([]*User, error) {
+	conn := mysql.DB()
+	result, err := conn.Table(p.getTableName()).Order("user_id").Offset(offset).Limit(limit).Get()
+	if err != nil {
+		log.Printf("Error : %v", err)
+		return nil, err
+	}
+	users := make([]*User, 0, len(result))
+	for _, data := range result {
+		users = append(users, toUser(data))
+	}
+	return users, nil
+}
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestUserIsPasswordMatch(t *testing.T) {
+	passwordHash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword() err = %v", err)
+	}
+	tests := []struct {
+		name       string
+		stored     string
+		password   string
+		want       bool
+		wantHashed bool
+	}{
+		{name: "hashed password", stored: passwordHash, password: "secret", want: true, wantHashed: true},
+		{name: "wrong password", stored: passwordHash, password: "wrong", wantHashed: true},
+		{name: "legacy plaintext password", stored: "secret", password: "secret", want: true},
+		{name: "wrong legacy password", stored: "secret", password: "wrong"},
+		{name: "empty password never matches", stored: "", password: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Username: "alice", Password: tt.stored}
+			if match := user.IsPasswordMatch(tt.password); match != tt.want {
+				t.Fatalf("IsPasswordMatch() = %v, want %v", match, tt.want)
+			}
+			if hashed := user.IsPasswordHashed(); hashed != tt.wantHashed {
+				t.Fatalf("IsPasswordHashed() = %v, want %v", hashed, tt.wantHashed)
+			}
+		})
+	}
+}
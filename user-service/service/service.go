@@ -0,0 +1,122 @@
+package service
+
+import (
+	"SecondKill/user-service/model"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// 迁移密码时每次读取的用户数量
+const migratePageSize = 100
+
+var (
+	ErrInvalidUser = errors.New("invalid username or password")
+)
+
+type Service interface {
+	// Check 校验用户名与密码，成功时返回用户信息
+	Check(ctx context.Context, username, password string) (*model.User, error)
+	// HealthCheck check service health status
+	HealthCheck() bool
+}
+
+type UserService struct {
+	userModel *model.UserModel
+}
+
+func NewUserService() *UserService {
+	return &UserService{
+		userModel: model.NewUserModel(),
+	}
+}
+
+var (
+	dummyPasswordOnce sync.Once
+	dummyPassword     *model.User
+)
+
+func (s *UserService) Check(ctx context.Context, username, password string) (*model.User, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidUser
+	}
+	user, err := s.userModel.GetUserByUsername(username)
+	if err == model.ErrUserNotFound {
+		// 用户不存在时同样计算一次哈希，避免通过响应时间探测用户名
+		dummyPasswordOnce.Do(func() {
+			hash, _ := model.HashPassword("dummy-password")
+			dummyPassword = &model.User{Password: hash}
+		})
+		dummyPassword.IsPasswordMatch(password)
+		return nil, ErrInvalidUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsPasswordMatch(password) {
+		return nil, ErrInvalidUser
+	}
+	s.upgradePassword(user, password)
+	return user, nil
+}
+
+// upgradePassword 历史数据中的明文密码在校验通过后转换为哈希存储
+func (s *UserService) upgradePassword(user *model.User, password string) {
+	if user.IsPasswordHashed() {
+		return
+	}
+	passwordHash, err := model.HashPassword(password)
+	if err != nil {
+		log.Printf("hash password err : %v", err)
+		return
+	}
+	if err = s.userModel.UpdatePassword(user.UserId, passwordHash); err == nil {
+		user.Password = passwordHash
+	}
+}
+
+// CreateUser 创建用户，密码以 bcrypt 哈希存储
+func (s *UserService) CreateUser(username, password string, authorities []string) error {
+	if username == "" || password == "" {
+		return ErrInvalidUser
+	}
+	return s.userModel.CreateUser(&model.User{
+		Username:    username,
+		Password:    password,
+		Authorities: authorities,
+	})
+}
+
+// MigratePasswords 将全部明文密码转换为 bcrypt 哈希，返回转换的用户数
+func (s *UserService) MigratePasswords() (int, error) {
+	migrated := 0
+	for offset := 0; ; offset += migratePageSize {
+		users, err := s.userModel.ListUsers(offset, migratePageSize)
+		if err != nil {
+			return migrated, err
+		}
+		for _, user := range users {
+			if user.Password == "" || user.IsPasswordHashed() {
+				continue
+			}
+			passwordHash, err := model.HashPassword(user.Password)
+			if err != nil {
+				return migrated, err
+			}
+			if err = s.userModel.UpdatePassword(user.UserId, passwordHash); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+		if len(users) < migratePageSize {
+			return migrated, nil
+		}
+	}
+}
+
+// HealthCheck implement Service method
+// 用于检查服务的健康状态，这里仅仅返回true
+func (s *UserService) HealthCheck() bool {
+	return true
+}
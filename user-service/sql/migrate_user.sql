@@ -0,0 +1,7 @@
+-- 已有的 user 表升级到存储 bcrypt 哈希和权限的版本，只需执行一次
+ALTER TABLE `user`
+  MODIFY COLUMN `password` varchar(255) NOT NULL,
+  ADD COLUMN `authorities` varchar(1024) NOT NULL DEFAULT '[]';
+
+-- 明文密码在用户下次登录成功时自动转换为 bcrypt 哈希，
+-- 也可以执行 user-service -migrate-passwords 一次性转换全部明文密码
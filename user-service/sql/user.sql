@@ -0,0 +1,11 @@
+-- 用户表，新部署直接执行本文件，用户通过 user-service -create-user 创建
+CREATE TABLE IF NOT EXISTS `user` (
+  `user_id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `username` varchar(64) NOT NULL,
+  -- bcrypt 哈希
+  `password` varchar(255) NOT NULL,
+  -- JSON 数组
+  `authorities` varchar(1024) NOT NULL DEFAULT '[]',
+  PRIMARY KEY (`user_id`),
+  UNIQUE KEY `uk_username` (`username`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"SecondKill/pb"
+	"SecondKill/user-service/endpoint"
+	"context"
+	"github.com/go-kit/kit/transport/grpc"
+)
+
+type grpcServer struct {
+	checkServer grpc.Handler
+}
+
+func (s *grpcServer) Check(ctx context.Context, request *pb.UserRequest) (*pb.UserResponse, error) {
+	_, resp, err := s.checkServer.ServeGRPC(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*pb.UserResponse), nil
+}
+
+func NewGRPCServer(ctx context.Context, endpoints endpoint.UserEndpoints, serverTracer grpc.ServerOption) pb.UserServiceServer {
+	return &grpcServer{
+		checkServer: grpc.NewServer(
+			endpoints.UserEndpoint,
+			DecodeGRPCUserRequest,
+			EncodeGRPCUserResponse,
+			serverTracer,
+		),
+	}
+}
+
+func DecodeGRPCUserRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.UserRequest)
+	return &endpoint.UserRequest{
+		Username: req.Username,
+		Password: req.Password,
+	}, nil
+}
+
+func EncodeGRPCUserResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(endpoint.UserResponse)
+	return &pb.UserResponse{
+		Result:      resp.Result,
+		UserId:      resp.UserId,
+		Err:         resp.Error,
+		Authorities: resp.Authorities,
+	}, nil
+}